@@ -21,6 +21,7 @@ type SchedulerClient interface {
 	ScheduleImageBuild(ctx context.Context, in *ScheduleImageBuildRequest, opts ...grpc.CallOption) (*ScheduleImageBuildResponse, error)
 	ScheduleWorkload(ctx context.Context, in *ScheduleWorkloadRequest, opts ...grpc.CallOption) (*ScheduleWorkloadResponse, error)
 	UnScheduleJob(ctx context.Context, in *UnScheduleJobRequest, opts ...grpc.CallOption) (*UnScheduleJobResponse, error)
+	GetJobStatus(ctx context.Context, in *GetJobStatusRequest, opts ...grpc.CallOption) (*GetJobStatusResponse, error)
 }
 
 type schedulerClient struct {
@@ -58,6 +59,15 @@ func (c *schedulerClient) UnScheduleJob(ctx context.Context, in *UnScheduleJobRe
 	return out, nil
 }
 
+func (c *schedulerClient) GetJobStatus(ctx context.Context, in *GetJobStatusRequest, opts ...grpc.CallOption) (*GetJobStatusResponse, error) {
+	out := new(GetJobStatusResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.Scheduler/GetJobStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SchedulerServer is the server API for Scheduler service.
 // All implementations must embed UnimplementedSchedulerServer
 // for forward compatibility
@@ -65,6 +75,7 @@ type SchedulerServer interface {
 	ScheduleImageBuild(context.Context, *ScheduleImageBuildRequest) (*ScheduleImageBuildResponse, error)
 	ScheduleWorkload(context.Context, *ScheduleWorkloadRequest) (*ScheduleWorkloadResponse, error)
 	UnScheduleJob(context.Context, *UnScheduleJobRequest) (*UnScheduleJobResponse, error)
+	GetJobStatus(context.Context, *GetJobStatusRequest) (*GetJobStatusResponse, error)
 	mustEmbedUnimplementedSchedulerServer()
 }
 
@@ -81,6 +92,9 @@ func (UnimplementedSchedulerServer) ScheduleWorkload(context.Context, *ScheduleW
 func (UnimplementedSchedulerServer) UnScheduleJob(context.Context, *UnScheduleJobRequest) (*UnScheduleJobResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UnScheduleJob not implemented")
 }
+func (UnimplementedSchedulerServer) GetJobStatus(context.Context, *GetJobStatusRequest) (*GetJobStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJobStatus not implemented")
+}
 func (UnimplementedSchedulerServer) mustEmbedUnimplementedSchedulerServer() {}
 
 // UnsafeSchedulerServer may be embedded to opt out of forward compatibility for this service.
@@ -148,6 +162,24 @@ func _Scheduler_UnScheduleJob_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Scheduler_GetJobStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SchedulerServer).GetJobStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.Scheduler/GetJobStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SchedulerServer).GetJobStatus(ctx, req.(*GetJobStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Scheduler_ServiceDesc is the grpc.ServiceDesc for Scheduler service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -167,6 +199,10 @@ var Scheduler_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UnScheduleJob",
 			Handler:    _Scheduler_UnScheduleJob_Handler,
 		},
+		{
+			MethodName: "GetJobStatus",
+			Handler:    _Scheduler_GetJobStatus_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pb/sloweater.proto",
@@ -5,13 +5,36 @@ import (
 	"github.com/Scarlet-Fairy/manager/pkg/service"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
+	"time"
 )
 
 type ManagerEndpoint struct {
 	DeployEndpoint     endpoint.Endpoint
+	UpdateEnvsEndpoint endpoint.Endpoint
+	ScaleEndpoint      endpoint.Endpoint
 	DestroyEndpoint    endpoint.Endpoint
 	GetDeployEndpoint  endpoint.Endpoint
 	ListDeployEndpoint endpoint.Endpoint
+
+	ListDeadLettersEndpoint  endpoint.Endpoint
+	ReplayDeadLetterEndpoint endpoint.Endpoint
+
+	AddDomainEndpoint    endpoint.Endpoint
+	RemoveDomainEndpoint endpoint.Endpoint
+	ListDomainsEndpoint  endpoint.Endpoint
+
+	CheckPermissionEndpoint endpoint.Endpoint
+
+	CreateProjectEndpoint       endpoint.Endpoint
+	GetProjectEndpoint          endpoint.Endpoint
+	ListProjectsEndpoint        endpoint.Endpoint
+	DeleteProjectEndpoint       endpoint.Endpoint
+	AddProjectMemberEndpoint    endpoint.Endpoint
+	RemoveProjectMemberEndpoint endpoint.Endpoint
+	SetProjectQuotaEndpoint     endpoint.Endpoint
+	GetQuotaUsageEndpoint       endpoint.Endpoint
+
+	ListAuditEventsEndpoint endpoint.Endpoint
 }
 
 func NewEndpoint(s service.Service, logger log.Logger) ManagerEndpoint {
@@ -22,6 +45,20 @@ func NewEndpoint(s service.Service, logger log.Logger) ManagerEndpoint {
 		deployEndpoint = UnwrapErrorMiddleware()(deployEndpoint)
 	}
 
+	var updateEnvsEndpoint endpoint.Endpoint
+	{
+		updateEnvsEndpoint = makeUpdateEnvsEndpoint(s)
+		updateEnvsEndpoint = LoggingMiddleware(log.With(logger, "method", "UpdateEnvs"))(updateEnvsEndpoint)
+		updateEnvsEndpoint = UnwrapErrorMiddleware()(updateEnvsEndpoint)
+	}
+
+	var scaleEndpoint endpoint.Endpoint
+	{
+		scaleEndpoint = makeScaleEndpoint(s)
+		scaleEndpoint = LoggingMiddleware(log.With(logger, "method", "Scale"))(scaleEndpoint)
+		scaleEndpoint = UnwrapErrorMiddleware()(scaleEndpoint)
+	}
+
 	var destroyEndpoint endpoint.Endpoint
 	{
 		destroyEndpoint = makeDestroyEndpoint(s)
@@ -43,26 +80,176 @@ func NewEndpoint(s service.Service, logger log.Logger) ManagerEndpoint {
 		listDeploysEndpoint = UnwrapErrorMiddleware()(listDeploysEndpoint)
 	}
 
+	var listDeadLettersEndpoint endpoint.Endpoint
+	{
+		listDeadLettersEndpoint = makeListDeadLettersEndpoint(s)
+		listDeadLettersEndpoint = LoggingMiddleware(log.With(logger, "method", "ListDeadLetters"))(listDeadLettersEndpoint)
+		listDeadLettersEndpoint = UnwrapErrorMiddleware()(listDeadLettersEndpoint)
+	}
+
+	var replayDeadLetterEndpoint endpoint.Endpoint
+	{
+		replayDeadLetterEndpoint = makeReplayDeadLetterEndpoint(s)
+		replayDeadLetterEndpoint = LoggingMiddleware(log.With(logger, "method", "ReplayDeadLetter"))(replayDeadLetterEndpoint)
+		replayDeadLetterEndpoint = UnwrapErrorMiddleware()(replayDeadLetterEndpoint)
+	}
+
+	var addDomainEndpoint endpoint.Endpoint
+	{
+		addDomainEndpoint = makeAddDomainEndpoint(s)
+		addDomainEndpoint = LoggingMiddleware(log.With(logger, "method", "AddDomain"))(addDomainEndpoint)
+		addDomainEndpoint = UnwrapErrorMiddleware()(addDomainEndpoint)
+	}
+
+	var removeDomainEndpoint endpoint.Endpoint
+	{
+		removeDomainEndpoint = makeRemoveDomainEndpoint(s)
+		removeDomainEndpoint = LoggingMiddleware(log.With(logger, "method", "RemoveDomain"))(removeDomainEndpoint)
+		removeDomainEndpoint = UnwrapErrorMiddleware()(removeDomainEndpoint)
+	}
+
+	var listDomainsEndpoint endpoint.Endpoint
+	{
+		listDomainsEndpoint = makeListDomainsEndpoint(s)
+		listDomainsEndpoint = LoggingMiddleware(log.With(logger, "method", "ListDomains"))(listDomainsEndpoint)
+		listDomainsEndpoint = UnwrapErrorMiddleware()(listDomainsEndpoint)
+	}
+
+	var checkPermissionEndpoint endpoint.Endpoint
+	{
+		checkPermissionEndpoint = makeCheckPermissionEndpoint(s)
+		checkPermissionEndpoint = LoggingMiddleware(log.With(logger, "method", "CheckPermission"))(checkPermissionEndpoint)
+		checkPermissionEndpoint = UnwrapErrorMiddleware()(checkPermissionEndpoint)
+	}
+
+	var createProjectEndpoint endpoint.Endpoint
+	{
+		createProjectEndpoint = makeCreateProjectEndpoint(s)
+		createProjectEndpoint = LoggingMiddleware(log.With(logger, "method", "CreateProject"))(createProjectEndpoint)
+		createProjectEndpoint = UnwrapErrorMiddleware()(createProjectEndpoint)
+	}
+
+	var getProjectEndpoint endpoint.Endpoint
+	{
+		getProjectEndpoint = makeGetProjectEndpoint(s)
+		getProjectEndpoint = LoggingMiddleware(log.With(logger, "method", "GetProject"))(getProjectEndpoint)
+		getProjectEndpoint = UnwrapErrorMiddleware()(getProjectEndpoint)
+	}
+
+	var listProjectsEndpoint endpoint.Endpoint
+	{
+		listProjectsEndpoint = makeListProjectsEndpoint(s)
+		listProjectsEndpoint = LoggingMiddleware(log.With(logger, "method", "ListProjects"))(listProjectsEndpoint)
+		listProjectsEndpoint = UnwrapErrorMiddleware()(listProjectsEndpoint)
+	}
+
+	var deleteProjectEndpoint endpoint.Endpoint
+	{
+		deleteProjectEndpoint = makeDeleteProjectEndpoint(s)
+		deleteProjectEndpoint = LoggingMiddleware(log.With(logger, "method", "DeleteProject"))(deleteProjectEndpoint)
+		deleteProjectEndpoint = UnwrapErrorMiddleware()(deleteProjectEndpoint)
+	}
+
+	var addProjectMemberEndpoint endpoint.Endpoint
+	{
+		addProjectMemberEndpoint = makeAddProjectMemberEndpoint(s)
+		addProjectMemberEndpoint = LoggingMiddleware(log.With(logger, "method", "AddProjectMember"))(addProjectMemberEndpoint)
+		addProjectMemberEndpoint = UnwrapErrorMiddleware()(addProjectMemberEndpoint)
+	}
+
+	var removeProjectMemberEndpoint endpoint.Endpoint
+	{
+		removeProjectMemberEndpoint = makeRemoveProjectMemberEndpoint(s)
+		removeProjectMemberEndpoint = LoggingMiddleware(log.With(logger, "method", "RemoveProjectMember"))(removeProjectMemberEndpoint)
+		removeProjectMemberEndpoint = UnwrapErrorMiddleware()(removeProjectMemberEndpoint)
+	}
+
+	var setProjectQuotaEndpoint endpoint.Endpoint
+	{
+		setProjectQuotaEndpoint = makeSetProjectQuotaEndpoint(s)
+		setProjectQuotaEndpoint = LoggingMiddleware(log.With(logger, "method", "SetProjectQuota"))(setProjectQuotaEndpoint)
+		setProjectQuotaEndpoint = UnwrapErrorMiddleware()(setProjectQuotaEndpoint)
+	}
+
+	var getQuotaUsageEndpoint endpoint.Endpoint
+	{
+		getQuotaUsageEndpoint = makeGetQuotaUsageEndpoint(s)
+		getQuotaUsageEndpoint = LoggingMiddleware(log.With(logger, "method", "GetQuotaUsage"))(getQuotaUsageEndpoint)
+		getQuotaUsageEndpoint = UnwrapErrorMiddleware()(getQuotaUsageEndpoint)
+	}
+
+	var listAuditEventsEndpoint endpoint.Endpoint
+	{
+		listAuditEventsEndpoint = makeListAuditEventsEndpoint(s)
+		listAuditEventsEndpoint = LoggingMiddleware(log.With(logger, "method", "ListAuditEvents"))(listAuditEventsEndpoint)
+		listAuditEventsEndpoint = UnwrapErrorMiddleware()(listAuditEventsEndpoint)
+	}
+
 	return ManagerEndpoint{
 		DeployEndpoint:     deployEndpoint,
+		UpdateEnvsEndpoint: updateEnvsEndpoint,
+		ScaleEndpoint:      scaleEndpoint,
 		DestroyEndpoint:    destroyEndpoint,
 		GetDeployEndpoint:  getDeployEndpoint,
 		ListDeployEndpoint: listDeploysEndpoint,
+
+		ListDeadLettersEndpoint:  listDeadLettersEndpoint,
+		ReplayDeadLetterEndpoint: replayDeadLetterEndpoint,
+
+		AddDomainEndpoint:    addDomainEndpoint,
+		RemoveDomainEndpoint: removeDomainEndpoint,
+		ListDomainsEndpoint:  listDomainsEndpoint,
+
+		CheckPermissionEndpoint: checkPermissionEndpoint,
+
+		CreateProjectEndpoint:       createProjectEndpoint,
+		GetProjectEndpoint:          getProjectEndpoint,
+		ListProjectsEndpoint:        listProjectsEndpoint,
+		DeleteProjectEndpoint:       deleteProjectEndpoint,
+		AddProjectMemberEndpoint:    addProjectMemberEndpoint,
+		RemoveProjectMemberEndpoint: removeProjectMemberEndpoint,
+		SetProjectQuotaEndpoint:     setProjectQuotaEndpoint,
+		GetQuotaUsageEndpoint:       getQuotaUsageEndpoint,
+		ListAuditEventsEndpoint:     listAuditEventsEndpoint,
 	}
 }
 
 // compile time assertions for our response types implementing endpoint.Failer
 var (
 	_ endpoint.Failer = DeployResponse{}
+	_ endpoint.Failer = UpdateEnvsResponse{}
+	_ endpoint.Failer = ScaleResponse{}
 	_ endpoint.Failer = DestroyResponse{}
 	_ endpoint.Failer = GetDeployResponse{}
 	_ endpoint.Failer = ListDeploysResponse{}
+	_ endpoint.Failer = ListDeadLettersResponse{}
+	_ endpoint.Failer = ReplayDeadLetterResponse{}
+	_ endpoint.Failer = AddDomainResponse{}
+	_ endpoint.Failer = RemoveDomainResponse{}
+	_ endpoint.Failer = ListDomainsResponse{}
+	_ endpoint.Failer = CheckPermissionResponse{}
+	_ endpoint.Failer = CreateProjectResponse{}
+	_ endpoint.Failer = GetProjectResponse{}
+	_ endpoint.Failer = ListProjectsResponse{}
+	_ endpoint.Failer = DeleteProjectResponse{}
+	_ endpoint.Failer = AddProjectMemberResponse{}
+	_ endpoint.Failer = RemoveProjectMemberResponse{}
+	_ endpoint.Failer = SetProjectQuotaResponse{}
+	_ endpoint.Failer = GetQuotaUsageResponse{}
+	_ endpoint.Failer = ListAuditEventsResponse{}
 )
 
 type DeployRequest struct {
-	GitRepo string
-	Name    string
-	Envs    map[string]string
+	ProjectId      string
+	GitRepo        string
+	Name           string
+	Envs           map[string]string
+	Labels         map[string]string
+	HealthCheck    *service.HealthCheck
+	Replicas       int
+	Resources      service.Resources
+	Priority       int
+	IdempotencyKey string
 }
 
 type DeployResponse struct {
@@ -77,7 +264,7 @@ func (r DeployResponse) Failed() error {
 func makeDeployEndpoint(s service.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(*DeployRequest)
-		id, err := s.Deploy(ctx, req.GitRepo, req.Name, req.Envs)
+		id, err := s.Deploy(ctx, req.ProjectId, req.GitRepo, req.Name, req.Envs, req.Labels, req.HealthCheck, req.Replicas, req.Resources, req.Priority, req.IdempotencyKey)
 
 		return &DeployResponse{
 			DeployId: id,
@@ -86,8 +273,66 @@ func makeDeployEndpoint(s service.Service) endpoint.Endpoint {
 	}
 }
 
+type UpdateEnvsRequest struct {
+	Id              string
+	Envs            map[string]string
+	ExpectedVersion int64
+	IdempotencyKey  string
+}
+
+type UpdateEnvsResponse struct {
+	Deploy *service.Deploy
+	Err    error `json:"-"`
+}
+
+func (r UpdateEnvsResponse) Failed() error {
+	return r.Err
+}
+
+func makeUpdateEnvsEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*UpdateEnvsRequest)
+		deploy, err := s.UpdateEnvs(ctx, req.Id, req.Envs, req.ExpectedVersion, req.IdempotencyKey)
+
+		return &UpdateEnvsResponse{
+			Deploy: deploy,
+			Err:    err,
+		}, nil
+	}
+}
+
+type ScaleRequest struct {
+	Id              string
+	Replicas        int
+	ExpectedVersion int64
+	IdempotencyKey  string
+}
+
+type ScaleResponse struct {
+	Deploy *service.Deploy
+	Err    error `json:"-"`
+}
+
+func (r ScaleResponse) Failed() error {
+	return r.Err
+}
+
+func makeScaleEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*ScaleRequest)
+		deploy, err := s.Scale(ctx, req.Id, req.Replicas, req.ExpectedVersion, req.IdempotencyKey)
+
+		return &ScaleResponse{
+			Deploy: deploy,
+			Err:    err,
+		}, nil
+	}
+}
+
 type DestroyRequest struct {
-	Id string
+	Id              string
+	ExpectedVersion int64
+	IdempotencyKey  string
 }
 
 type DestroyResponse struct {
@@ -101,7 +346,7 @@ func (r DestroyResponse) Failed() error {
 func makeDestroyEndpoint(s service.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(*DestroyRequest)
-		err := s.Destroy(ctx, req.Id)
+		err := s.Destroy(ctx, req.Id, req.ExpectedVersion, req.IdempotencyKey)
 
 		return &DestroyResponse{
 			Err: err,
@@ -156,3 +401,388 @@ func makeListDeploysEndpoint(s service.Service) endpoint.Endpoint {
 		}, nil
 	}
 }
+
+type ListDeadLettersRequest struct {
+	DeployId string
+}
+
+type ListDeadLettersResponse struct {
+	DeadLetters []*service.DeadLetter
+	Err         error `json:"-"`
+}
+
+func (r ListDeadLettersResponse) Failed() error {
+	return r.Err
+}
+
+func makeListDeadLettersEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*ListDeadLettersRequest)
+		letters, err := s.ListDeadLetters(ctx, req.DeployId)
+
+		return &ListDeadLettersResponse{
+			DeadLetters: letters,
+			Err:         err,
+		}, nil
+	}
+}
+
+type ReplayDeadLetterRequest struct {
+	Id string
+}
+
+type ReplayDeadLetterResponse struct {
+	Err error `json:"-"`
+}
+
+func (r ReplayDeadLetterResponse) Failed() error {
+	return r.Err
+}
+
+func makeReplayDeadLetterEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*ReplayDeadLetterRequest)
+		err := s.ReplayDeadLetter(ctx, req.Id)
+
+		return &ReplayDeadLetterResponse{
+			Err: err,
+		}, nil
+	}
+}
+
+type AddDomainRequest struct {
+	DeployId string
+	Domain   string
+}
+
+type AddDomainResponse struct {
+	Domain *service.Domain
+	Err    error `json:"-"`
+}
+
+func (r AddDomainResponse) Failed() error {
+	return r.Err
+}
+
+func makeAddDomainEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*AddDomainRequest)
+		domain, err := s.AddDomain(ctx, req.DeployId, req.Domain)
+
+		return &AddDomainResponse{
+			Domain: domain,
+			Err:    err,
+		}, nil
+	}
+}
+
+type RemoveDomainRequest struct {
+	DeployId string
+	Domain   string
+}
+
+type RemoveDomainResponse struct {
+	Err error `json:"-"`
+}
+
+func (r RemoveDomainResponse) Failed() error {
+	return r.Err
+}
+
+func makeRemoveDomainEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*RemoveDomainRequest)
+		err := s.RemoveDomain(ctx, req.DeployId, req.Domain)
+
+		return &RemoveDomainResponse{
+			Err: err,
+		}, nil
+	}
+}
+
+type ListDomainsRequest struct {
+	DeployId string
+}
+
+type ListDomainsResponse struct {
+	Domains []*service.Domain
+	Err     error `json:"-"`
+}
+
+func (r ListDomainsResponse) Failed() error {
+	return r.Err
+}
+
+func makeListDomainsEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*ListDomainsRequest)
+		domains, err := s.ListDomains(ctx, req.DeployId)
+
+		return &ListDomainsResponse{
+			Domains: domains,
+			Err:     err,
+		}, nil
+	}
+}
+
+type CheckPermissionRequest struct {
+	Permission string
+	ProjectId  string
+	DeployId   string
+	Labels     map[string]string
+}
+
+type CheckPermissionResponse struct {
+	Check *service.PermissionCheck
+	Err   error `json:"-"`
+}
+
+func (r CheckPermissionResponse) Failed() error {
+	return r.Err
+}
+
+func makeCheckPermissionEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*CheckPermissionRequest)
+		check, err := s.CheckPermission(ctx, req.Permission, req.ProjectId, req.DeployId, req.Labels)
+
+		return &CheckPermissionResponse{
+			Check: check,
+			Err:   err,
+		}, nil
+	}
+}
+
+type CreateProjectRequest struct {
+	Id          string
+	Description string
+}
+
+type CreateProjectResponse struct {
+	Project *service.Project
+	Err     error `json:"-"`
+}
+
+func (r CreateProjectResponse) Failed() error {
+	return r.Err
+}
+
+func makeCreateProjectEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*CreateProjectRequest)
+		project, err := s.CreateProject(ctx, req.Id, req.Description)
+
+		return &CreateProjectResponse{
+			Project: project,
+			Err:     err,
+		}, nil
+	}
+}
+
+type GetProjectRequest struct {
+	Id string
+}
+
+type GetProjectResponse struct {
+	Project *service.Project
+	Err     error `json:"-"`
+}
+
+func (r GetProjectResponse) Failed() error {
+	return r.Err
+}
+
+func makeGetProjectEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*GetProjectRequest)
+		project, err := s.GetProject(ctx, req.Id)
+
+		return &GetProjectResponse{
+			Project: project,
+			Err:     err,
+		}, nil
+	}
+}
+
+type ListProjectsRequest struct{}
+
+type ListProjectsResponse struct {
+	Projects []*service.Project
+	Err      error `json:"-"`
+}
+
+func (r ListProjectsResponse) Failed() error {
+	return r.Err
+}
+
+func makeListProjectsEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		projects, err := s.ListProjects(ctx)
+
+		return &ListProjectsResponse{
+			Projects: projects,
+			Err:      err,
+		}, nil
+	}
+}
+
+type DeleteProjectRequest struct {
+	Id string
+}
+
+type DeleteProjectResponse struct {
+	Err error `json:"-"`
+}
+
+func (r DeleteProjectResponse) Failed() error {
+	return r.Err
+}
+
+func makeDeleteProjectEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*DeleteProjectRequest)
+		err := s.DeleteProject(ctx, req.Id)
+
+		return &DeleteProjectResponse{
+			Err: err,
+		}, nil
+	}
+}
+
+type AddProjectMemberRequest struct {
+	ProjectId string
+	Subject   string
+}
+
+type AddProjectMemberResponse struct {
+	Project *service.Project
+	Err     error `json:"-"`
+}
+
+func (r AddProjectMemberResponse) Failed() error {
+	return r.Err
+}
+
+func makeAddProjectMemberEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*AddProjectMemberRequest)
+		project, err := s.AddProjectMember(ctx, req.ProjectId, req.Subject)
+
+		return &AddProjectMemberResponse{
+			Project: project,
+			Err:     err,
+		}, nil
+	}
+}
+
+type RemoveProjectMemberRequest struct {
+	ProjectId string
+	Subject   string
+}
+
+type RemoveProjectMemberResponse struct {
+	Project *service.Project
+	Err     error `json:"-"`
+}
+
+func (r RemoveProjectMemberResponse) Failed() error {
+	return r.Err
+}
+
+func makeRemoveProjectMemberEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*RemoveProjectMemberRequest)
+		project, err := s.RemoveProjectMember(ctx, req.ProjectId, req.Subject)
+
+		return &RemoveProjectMemberResponse{
+			Project: project,
+			Err:     err,
+		}, nil
+	}
+}
+
+type SetProjectQuotaRequest struct {
+	ProjectId string
+	Quota     *service.Quota
+}
+
+type SetProjectQuotaResponse struct {
+	Project *service.Project
+	Err     error `json:"-"`
+}
+
+func (r SetProjectQuotaResponse) Failed() error {
+	return r.Err
+}
+
+func makeSetProjectQuotaEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*SetProjectQuotaRequest)
+		project, err := s.SetProjectQuota(ctx, req.ProjectId, req.Quota)
+
+		return &SetProjectQuotaResponse{
+			Project: project,
+			Err:     err,
+		}, nil
+	}
+}
+
+type GetQuotaUsageRequest struct {
+	ProjectId string
+}
+
+type GetQuotaUsageResponse struct {
+	Quota *service.ProjectQuota
+	Err   error `json:"-"`
+}
+
+func (r GetQuotaUsageResponse) Failed() error {
+	return r.Err
+}
+
+func makeGetQuotaUsageEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*GetQuotaUsageRequest)
+		quota, err := s.GetQuotaUsage(ctx, req.ProjectId)
+
+		return &GetQuotaUsageResponse{
+			Quota: quota,
+			Err:   err,
+		}, nil
+	}
+}
+
+type ListAuditEventsRequest struct {
+	DeployId string
+	Actor    string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+type ListAuditEventsResponse struct {
+	Events []*service.AuditEvent
+	Err    error `json:"-"`
+}
+
+func (r ListAuditEventsResponse) Failed() error {
+	return r.Err
+}
+
+func makeListAuditEventsEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(*ListAuditEventsRequest)
+		events, err := s.ListAuditEvents(ctx, service.AuditFilter{
+			DeployId: req.DeployId,
+			Actor:    req.Actor,
+			From:     req.From,
+			To:       req.To,
+			Limit:    req.Limit,
+		})
+
+		return &ListAuditEventsResponse{
+			Events: events,
+			Err:    err,
+		}, nil
+	}
+}
@@ -18,6 +18,10 @@ type Loggers struct {
 	MessageComponentLogger    log.Logger
 	RepositoryComponentLogger log.Logger
 	SchedulerComponentLogger  log.Logger
+	WorkerComponentLogger     log.Logger
+	DriftComponentLogger      log.Logger
+	DomainComponentLogger     log.Logger
+	AdmissionComponentLogger  log.Logger
 }
 
 func NewLogger() Loggers {
@@ -38,5 +42,9 @@ func NewLogger() Loggers {
 		MessageComponentLogger:    log.With(logger, "component", "message"),
 		RepositoryComponentLogger: log.With(logger, "component", "repository"),
 		SchedulerComponentLogger:  log.With(logger, "component", "scheduler"),
+		WorkerComponentLogger:     log.With(logger, "component", "worker"),
+		DriftComponentLogger:      log.With(logger, "component", "drift"),
+		DomainComponentLogger:     log.With(logger, "component", "domain"),
+		AdmissionComponentLogger:  log.With(logger, "component", "admission"),
 	}
 }
@@ -0,0 +1,457 @@
+package amqp
+
+import (
+	"fmt"
+	"github.com/Scarlet-Fairy/manager/pkg/message"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+	"time"
+)
+
+const (
+	BuildImageExchanger  = "build_image"
+	DeadLetterExchanger  = "build_image.dead_letter"
+	DeadLetterQueue      = "build_image.dead_letter"
+	RetryExchangerPrefix = "build_image.retry"
+	MaxRedeliveries      = 5
+	// firstRetryDelay is the delay of the first redelivery of a failing event, it doubles at every attempt
+	firstRetryDelay       = time.Second
+	redeliveryCountHeader = "x-redelivery-count"
+	deadReasonHeader      = "x-dead-reason"
+	deployIdHeader        = "x-deploy-id"
+)
+
+// broker holds the behaviour shared by the message topologies
+type broker struct {
+	connection *Connection
+	logger     log.Logger
+}
+
+func declareExchanges(ch *amqp.Channel) error {
+	if err := ch.ExchangeDeclare(
+		BuildImageExchanger,
+		"direct",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to declare %s exchange", BuildImageExchanger))
+	}
+
+	if err := ch.ExchangeDeclare(
+		DeadLetterExchanger,
+		"fanout",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to declare %s exchange", DeadLetterExchanger))
+	}
+
+	if _, err := ch.QueueDeclare(
+		DeadLetterQueue,
+		true,
+		false,
+		false,
+		false,
+		nil,
+	); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to declare %s queue", DeadLetterQueue))
+	}
+
+	if err := ch.QueueBind(
+		DeadLetterQueue,
+		"",
+		DeadLetterExchanger,
+		false,
+		nil,
+	); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to bind %s queue", DeadLetterQueue))
+	}
+
+	for attempt := 1; attempt <= MaxRedeliveries; attempt++ {
+		if err := declareRetry(ch, attempt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retryExchanger is the exchange, and the queue, where the events wait before their redelivery of the given attempt
+func retryExchanger(attempt int) string {
+	return fmt.Sprintf("%s.%d", RetryExchangerPrefix, attempt)
+}
+
+func retryDelay(attempt int) time.Duration {
+	return firstRetryDelay << (attempt - 1)
+}
+
+// declareRetry declares the delay of an attempt: the events published to its fanout exchange wait in its queue
+// until their ttl expires, then they are dead lettered through the default exchange with their original
+// routing key, which is the name of the queue they are requeued to
+func declareRetry(ch *amqp.Channel, attempt int) error {
+	name := retryExchanger(attempt)
+
+	if err := ch.ExchangeDeclare(
+		name,
+		"fanout",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to declare %s exchange", name))
+	}
+
+	if _, err := ch.QueueDeclare(
+		name,
+		true,
+		false,
+		false,
+		false,
+		amqp.Table{
+			"x-message-ttl":          int32(retryDelay(attempt) / time.Millisecond),
+			"x-dead-letter-exchange": "",
+		},
+	); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to declare %s queue", name))
+	}
+
+	if err := ch.QueueBind(
+		name,
+		"",
+		name,
+		false,
+		nil,
+	); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to bind %s queue", name))
+	}
+
+	return nil
+}
+
+func (b *broker) init() error {
+	ch, _ := b.connection.Channel()
+	if err := declareExchanges(ch); err != nil {
+		return err
+	}
+
+	b.connection.OnReconnect(declareExchanges)
+
+	return nil
+}
+
+type subscription struct {
+	deliveries  <-chan amqp.Delivery
+	closed      chan *amqp.Error
+	reconnected <-chan struct{}
+}
+
+func (b *broker) consume(queue string, consumer string, declare func(ch *amqp.Channel) error) (*subscription, error) {
+	ch, reconnected := b.connection.Channel()
+	sub := &subscription{
+		reconnected: reconnected,
+	}
+
+	if err := declare(ch); err != nil {
+		return sub, err
+	}
+
+	deliveries, err := ch.Consume(
+		queue,
+		consumer,
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return sub, err
+	}
+	sub.deliveries = deliveries
+	sub.closed = ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	return sub, nil
+}
+
+// subscribe passes every delivery of the queue to handle until stop is closed, the consumer is cancelled
+// or handle reports that the consumption is over. After a lost connection the consumer is subscribed again.
+func (b *broker) subscribe(queue string, consumer string, declare func(ch *amqp.Channel) error, stop <-chan struct{}, handle func(e amqp.Delivery) bool) error {
+	sub, err := b.consume(queue, consumer, declare)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			for e := range sub.deliveries {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				if !handle(e) {
+					return
+				}
+			}
+
+			// The deliveries are closed either by a cancellation or by a lost channel
+			select {
+			case <-sub.closed:
+			default:
+				return
+			}
+
+			for {
+				select {
+				case <-stop:
+					return
+				case <-b.connection.Done():
+					return
+				case <-sub.reconnected:
+				}
+
+				sub, err = b.consume(queue, consumer, declare)
+				if err == nil {
+					break
+				}
+
+				level.Warn(b.logger).Log(
+					"msg", "failed to resubscribe",
+					"queue", queue,
+					"err", err,
+				)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// deployIdOf returns the deploy of an event, which is the routing key unless the event has been requeued
+func deployIdOf(e amqp.Delivery) string {
+	if deployId, ok := e.Headers[deployIdHeader].(string); ok {
+		return deployId
+	}
+
+	return e.RoutingKey
+}
+
+// handleBuildEvent acknowledges the event only after it has been handled successfully.
+// Malformed events fail the build of their deploy and are dead lettered, while failing ones are requeued
+// until MaxRedeliveries.
+// Events refused during a shutdown are left unacknowledged: the broker delivers them again once the
+// channel is closed, without counting a redelivery.
+func (b *broker) handleBuildEvent(queue string, e amqp.Delivery, handler service.BuildEventHandler) bool {
+	id := deployIdOf(e)
+
+	buildStep, parseErr := message.ParseBuildStep(id, e.Body)
+	if parseErr != nil {
+		if id == "" {
+			b.reject(id, e, parseErr)
+			return false
+		}
+
+		buildStep = message.MalformedBuildStep(parseErr)
+	}
+
+	done, err := handler(contextOf(e), id, buildStep)
+	if err != nil {
+		if errors.Is(err, service.ErrShuttingDown) {
+			return false
+		}
+
+		switch {
+		case errors.Is(err, service.ErrInvalidEvent) && parseErr != nil:
+			b.reject(id, e, parseErr)
+		case errors.Is(err, service.ErrInvalidEvent) || redeliveryCount(e) >= MaxRedeliveries:
+			b.reject(id, e, err)
+		default:
+			b.requeue(queue, id, e)
+		}
+
+		return false
+	}
+
+	if err := e.Ack(false); err != nil {
+		level.Warn(b.logger).Log(
+			"msg", "failed to ack event",
+			"id", id,
+			"err", err,
+		)
+	}
+
+	return done
+}
+
+func redeliveryCount(e amqp.Delivery) int {
+	if count, ok := e.Headers[redeliveryCountHeader].(int32); ok {
+		return int(count)
+	}
+
+	return 0
+}
+
+// requeue publishes the event again to the queue with an incremented redelivery count, since the broker does
+// not keep track of the redeliveries of classic queues. The event waits for the delay of its attempt first.
+func (b *broker) requeue(queue string, id string, e amqp.Delivery) {
+	attempt := redeliveryCount(e) + 1
+
+	headers := amqp.Table{}
+	for key, value := range e.Headers {
+		headers[key] = value
+	}
+	headers[redeliveryCountHeader] = int32(attempt)
+	headers[deployIdHeader] = id
+
+	ch, _ := b.connection.Channel()
+	if err := ch.Publish(
+		retryExchanger(attempt),
+		queue,
+		false,
+		false,
+		amqp.Publishing{
+			Headers:      headers,
+			ContentType:  e.ContentType,
+			DeliveryMode: amqp.Persistent,
+			Body:         e.Body,
+		},
+	); err != nil {
+		_ = e.Nack(false, true)
+		return
+	}
+
+	_ = e.Ack(false)
+}
+
+// reject routes the event to the dead letter exchange, recording the reason of the failure
+func (b *broker) reject(id string, e amqp.Delivery, reason error) {
+	level.Warn(b.logger).Log(
+		"msg", "dead lettering build event",
+		"id", id,
+		"err", reason,
+	)
+
+	headers := amqp.Table{
+		deployIdHeader:        id,
+		deadReasonHeader:      reason.Error(),
+		redeliveryCountHeader: int32(redeliveryCount(e)),
+	}
+	copyTraceHeaders(headers, e)
+
+	ch, _ := b.connection.Channel()
+	if err := ch.Publish(
+		DeadLetterExchanger,
+		id,
+		false,
+		false,
+		amqp.Publishing{
+			Headers:      headers,
+			ContentType:  e.ContentType,
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    time.Now(),
+			Body:         e.Body,
+		},
+	); err != nil {
+		// the broker dead letters it on its own, without the reason
+		_ = e.Nack(false, false)
+		return
+	}
+
+	_ = e.Ack(false)
+}
+
+func (b *broker) ConsumeDeadLetters(handler service.DeadLetterHandler) error {
+	return b.subscribe(DeadLetterQueue, "", declareExchanges, nil, func(e amqp.Delivery) bool {
+		if err := handler(contextOf(e), parseDeadLetter(e)); err != nil {
+			// requeued right away it would be delivered again in a loop until the shutdown is over
+			if !errors.Is(err, service.ErrShuttingDown) {
+				_ = e.Nack(false, true)
+			}
+			return true
+		}
+
+		_ = e.Ack(false)
+		return true
+	})
+}
+
+// ReplayDeadLetter publishes again a dead letter with the routing key of its workload. The publishing is mandatory
+// and confirmed, so that a letter which can't be routed to the queue of its workload is reported rather than lost.
+func (b *broker) ReplayDeadLetter(letter *service.DeadLetter) error {
+	ch, err := b.connection.OpenChannel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		return err
+	}
+	// the broker returns an unroutable publishing before confirming it
+	returns := ch.NotifyReturn(make(chan amqp.Return, 1))
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+	if err := ch.Publish(
+		BuildImageExchanger,
+		letter.WorkloadId,
+		true,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         letter.Body,
+		},
+	); err != nil {
+		return err
+	}
+
+	confirmation, ok := <-confirms
+	if !ok {
+		return amqp.ErrClosed
+	}
+
+	select {
+	case <-returns:
+		return errors.Wrap(service.ErrNotWatched, letter.WorkloadId)
+	default:
+	}
+
+	if !confirmation.Ack {
+		return errors.New("dead letter not confirmed by the broker")
+	}
+
+	return nil
+}
+
+func parseDeadLetter(e amqp.Delivery) *service.DeadLetter {
+	letter := &service.DeadLetter{
+		WorkloadId: deployIdOf(e),
+		Body:       e.Body,
+		Attempts:   redeliveryCount(e),
+		DeadAt:     e.Timestamp,
+	}
+
+	if reason, ok := e.Headers[deadReasonHeader].(string); ok {
+		letter.Reason = reason
+	} else {
+		letter.Reason = "rejected by the broker"
+	}
+
+	if letter.DeadAt.IsZero() {
+		letter.DeadAt = time.Now()
+	}
+
+	return letter
+}
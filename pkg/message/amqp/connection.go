@@ -0,0 +1,213 @@
+package amqp
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/streadway/amqp"
+	"sync"
+	"time"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Connection supervises a connection and a channel to RabbitMQ, dialing them again with backoff when
+// they get closed. Hooks registered with OnReconnect are run on every new channel, before
+// the consumers waiting on Reconnected are woken up.
+type Connection struct {
+	url    string
+	logger log.Logger
+
+	mu          sync.RWMutex
+	conn        *amqp.Connection
+	channel     *amqp.Channel
+	reconnected chan struct{}
+	hooks       []func(ch *amqp.Channel) error
+	// lost is set by the supervisor from the loss of the connection or of its channel until they are restored
+	lost bool
+
+	done chan struct{}
+	once sync.Once
+}
+
+func Dial(url string, logger log.Logger) (*Connection, error) {
+	c := &Connection{
+		url:         url,
+		logger:      logger,
+		reconnected: make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	conn, ch, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	c.conn, c.channel = conn, ch
+
+	go c.supervise()
+
+	return c, nil
+}
+
+func (c *Connection) dial() (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, ch, nil
+}
+
+// Channel returns the current channel along with a chan which is closed when it gets replaced
+func (c *Connection) Channel() (*amqp.Channel, <-chan struct{}) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.channel, c.reconnected
+}
+
+// OnReconnect registers a hook which restores the state of the broker on every new channel
+func (c *Connection) OnReconnect(hook func(ch *amqp.Channel) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hooks = append(c.hooks, hook)
+}
+
+// OpenChannel opens a channel of its own on the current connection, which is not restored after a reconnection
+func (c *Connection) OpenChannel() (*amqp.Channel, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.conn.Channel()
+}
+
+// Check returns an error while the connection or its channel are down, until the supervisor restores them.
+// It relies on the state tracked by the supervisor, so that the probes do not open channels on the broker.
+func (c *Connection) Check() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.lost || c.conn.IsClosed() {
+		return amqp.ErrClosed
+	}
+
+	return nil
+}
+
+func (c *Connection) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Connection) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.conn.Close(); err != nil && err != amqp.ErrClosed {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Connection) supervise() {
+	for {
+		c.mu.RLock()
+		connClosed := c.conn.NotifyClose(make(chan *amqp.Error, 1))
+		channelClosed := c.channel.NotifyClose(make(chan *amqp.Error, 1))
+		c.mu.RUnlock()
+
+		var reason *amqp.Error
+		select {
+		case <-c.done:
+			return
+		case reason = <-connClosed:
+		case reason = <-channelClosed:
+		}
+
+		c.mu.Lock()
+		c.lost = true
+		c.mu.Unlock()
+
+		level.Warn(c.logger).Log(
+			"msg", "amqp connection lost",
+			"err", reason,
+		)
+
+		if !c.reconnect() {
+			return
+		}
+	}
+}
+
+func (c *Connection) reconnect() bool {
+	delay := minReconnectDelay
+
+	for {
+		select {
+		case <-c.done:
+			return false
+		case <-time.After(delay):
+		}
+
+		conn, ch, err := c.dial()
+		if err == nil {
+			err = c.restore(ch)
+			if err != nil {
+				_ = conn.Close()
+			}
+		}
+		if err != nil {
+			level.Warn(c.logger).Log(
+				"msg", "amqp reconnection failed",
+				"retryIn", delay,
+				"err", err,
+			)
+
+			if delay *= 2; delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		_ = c.conn.Close()
+		c.conn, c.channel = conn, ch
+		c.lost = false
+		close(c.reconnected)
+		c.reconnected = make(chan struct{})
+		c.mu.Unlock()
+
+		level.Info(c.logger).Log(
+			"msg", "amqp connection restored",
+		)
+
+		return true
+	}
+}
+
+func (c *Connection) restore(ch *amqp.Channel) error {
+	c.mu.RLock()
+	hooks := c.hooks
+	c.mu.RUnlock()
+
+	for _, hook := range hooks {
+		if err := hook(ch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
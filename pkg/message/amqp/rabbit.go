@@ -1,27 +1,28 @@
 package amqp
 
 import (
-	"encoding/json"
-	"fmt"
 	middlewares "github.com/Scarlet-Fairy/manager/pkg/message"
 	"github.com/Scarlet-Fairy/manager/pkg/service"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/streadway/amqp"
+	"sync"
 )
 
-const (
-	BuildImageExchanger = "build_image"
-)
-
+// rabbitMessage declares a queue for every deploy, bound to the build_image exchange by the deploy id
 type rabbitMessage struct {
-	channel *amqp.Channel
+	*broker
+	handler service.BuildEventHandler
 }
 
-func New(ch *amqp.Channel, logger log.Logger) service.Message {
+func New(connection *Connection, logger log.Logger) service.Message {
 	var instance service.Message
 	instance = &rabbitMessage{
-		channel: ch,
+		broker: &broker{
+			connection: connection,
+			logger:     logger,
+		},
 	}
 	instance = middlewares.LoggingMiddleware(logger)(instance)
 
@@ -29,35 +30,25 @@ func New(ch *amqp.Channel, logger log.Logger) service.Message {
 }
 
 func (m *rabbitMessage) Init() error {
-	if err := m.channel.ExchangeDeclare(
-		BuildImageExchanger,
-		"direct",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	); err != nil {
-		return errors.Wrap(err, fmt.Sprintf("Failed to declare %s exchange", BuildImageExchanger))
-	}
-
-	return nil
+	return m.init()
 }
 
-func (m *rabbitMessage) declareQueues(id string) error {
-	_, err := m.channel.QueueDeclare(
+func declareQueues(ch *amqp.Channel, id string) error {
+	_, err := ch.QueueDeclare(
 		id,
 		true,
 		false,
 		false,
 		false,
-		nil,
+		amqp.Table{
+			"x-dead-letter-exchange": DeadLetterExchanger,
+		},
 	)
 	if err != nil {
 		return err
 	}
 
-	if err := m.channel.QueueBind(
+	if err := ch.QueueBind(
 		id,
 		id,
 		BuildImageExchanger,
@@ -70,64 +61,31 @@ func (m *rabbitMessage) declareQueues(id string) error {
 	return nil
 }
 
-func (m *rabbitMessage) ConsumeBuildEvents(id string) (<-chan *service.BuildStep, func() error, error) {
-	if err := m.declareQueues(id); err != nil {
-		return nil, nil, err
-	}
-
-	events, err := m.channel.Consume(
-		id,
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	buildStepEvents := make(chan *service.BuildStep)
-	go func() {
-		for e := range events {
-			var msg message
-			if err := json.Unmarshal(e.Body, &msg); err != nil {
-				buildStepEvents <- &service.BuildStep{
-					Step:  service.StepUnknown,
-					Error: "Failed to parse message from cobold",
-				}
-
-				return
-			}
+func (m *rabbitMessage) ConsumeBuildEvents(handler service.BuildEventHandler) error {
+	m.handler = handler
 
-			step := msg.ParseTopic()
-			if !step.IsValid() {
-				buildStepEvents <- &service.BuildStep{
-					Step:  service.StepUnknown,
-					Error: "Failed to parse Topic name",
-				}
+	return nil
+}
 
-				return
-			}
+func (m *rabbitMessage) WatchBuild(id string) (func() error, error) {
+	if m.handler == nil {
+		return nil, errors.New("build events are not being consumed")
+	}
 
-			if msg.Error != "" {
-				buildStepEvents <- &service.BuildStep{
-					Step:  step,
-					Error: msg.Error,
-				}
+	stop := make(chan struct{})
+	var once sync.Once
 
-				return
-			}
+	clear := func() error {
+		once.Do(func() {
+			close(stop)
+		})
 
-			buildStepEvents <- &service.BuildStep{
-				Step: step,
-			}
+		ch, _ := m.connection.Channel()
+		if err := ch.Cancel(id, false); err != nil {
+			return err
 		}
-	}()
 
-	return buildStepEvents, func() error {
-		_, err := m.channel.QueueDelete(
+		_, err := ch.QueueDelete(
 			id,
 			false,
 			false,
@@ -138,5 +96,28 @@ func (m *rabbitMessage) ConsumeBuildEvents(id string) (<-chan *service.BuildStep
 		}
 
 		return nil
-	}, nil
+	}
+
+	declare := func(ch *amqp.Channel) error {
+		return declareQueues(ch, id)
+	}
+
+	if err := m.subscribe(id, id, declare, stop, func(e amqp.Delivery) bool {
+		done := m.handleBuildEvent(id, e, m.handler)
+		if done {
+			if err := clear(); err != nil {
+				level.Warn(m.logger).Log(
+					"msg", "failed to delete queue",
+					"queue", id,
+					"err", err,
+				)
+			}
+		}
+
+		return !done
+	}); err != nil {
+		return nil, err
+	}
+
+	return clear, nil
 }
@@ -0,0 +1,119 @@
+package amqp
+
+import (
+	middlewares "github.com/Scarlet-Fairy/manager/pkg/message"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/streadway/amqp"
+)
+
+const (
+	SharedBuildEventsQueue = "build_image.events"
+	// SharedPrefetch bounds the events delivered to a replica and not yet acknowledged, so that the events are
+	// spread among the replicas and a crashed replica has only a few of them redelivered at once
+	SharedPrefetch = 10
+)
+
+// sharedRabbitMessage consumes the build events of every deploy from a single work queue, bound to the
+// build_image exchange once for every watched deploy. Many replicas of the manager can consume
+// it competitively, since every event carries its deploy id in the routing key.
+type sharedRabbitMessage struct {
+	*broker
+}
+
+func NewShared(connection *Connection, logger log.Logger) service.Message {
+	var instance service.Message
+	instance = &sharedRabbitMessage{
+		broker: &broker{
+			connection: connection,
+			logger:     logger,
+		},
+	}
+	instance = middlewares.LoggingMiddleware(logger)(instance)
+
+	return instance
+}
+
+func (m *sharedRabbitMessage) Init() error {
+	if err := m.init(); err != nil {
+		return err
+	}
+
+	ch, _ := m.connection.Channel()
+	if err := declareSharedQueue(ch); err != nil {
+		return err
+	}
+
+	m.connection.OnReconnect(declareSharedQueue)
+
+	return nil
+}
+
+func declareSharedQueue(ch *amqp.Channel) error {
+	_, err := ch.QueueDeclare(
+		SharedBuildEventsQueue,
+		true,
+		false,
+		false,
+		false,
+		amqp.Table{
+			"x-dead-letter-exchange": DeadLetterExchanger,
+		},
+	)
+
+	return err
+}
+
+func (m *sharedRabbitMessage) ConsumeBuildEvents(handler service.BuildEventHandler) error {
+	declare := func(ch *amqp.Channel) error {
+		if err := declareSharedQueue(ch); err != nil {
+			return err
+		}
+
+		// the prefetch applies to the consumers started afterwards on the channel, even after a reconnection
+		return ch.Qos(SharedPrefetch, 0, false)
+	}
+
+	return m.subscribe(SharedBuildEventsQueue, "", declare, nil, func(e amqp.Delivery) bool {
+		if done := m.handleBuildEvent(SharedBuildEventsQueue, e, handler); done {
+			if err := m.unbind(deployIdOf(e)); err != nil {
+				level.Warn(m.logger).Log(
+					"msg", "failed to unbind deploy",
+					"id", deployIdOf(e),
+					"err", err,
+				)
+			}
+		}
+
+		return true
+	})
+}
+
+func (m *sharedRabbitMessage) WatchBuild(id string) (func() error, error) {
+	ch, _ := m.connection.Channel()
+	if err := ch.QueueBind(
+		SharedBuildEventsQueue,
+		id,
+		BuildImageExchanger,
+		false,
+		nil,
+	); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return m.unbind(id)
+	}, nil
+}
+
+func (m *sharedRabbitMessage) unbind(id string) error {
+	ch, _ := m.connection.Channel()
+
+	return ch.QueueUnbind(
+		SharedBuildEventsQueue,
+		id,
+		BuildImageExchanger,
+		nil,
+	)
+}
@@ -0,0 +1,43 @@
+package amqp
+
+import (
+	"context"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+)
+
+// headerCarrier reads and writes the trace context in the headers of a message,
+// cobold propagates the trace of the build in them, e.g. as traceparent
+type headerCarrier amqp.Table
+
+func (c headerCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+func (c headerCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// contextOf returns a context carrying the trace of a message, if any
+func contextOf(e amqp.Delivery) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier(e.Headers))
+}
+
+// copyTraceHeaders copies the trace context of a message into the headers of the message republished from it
+func copyTraceHeaders(headers amqp.Table, e amqp.Delivery) {
+	for _, field := range otel.GetTextMapPropagator().Fields() {
+		if value, ok := e.Headers[field]; ok {
+			headers[field] = value
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package message
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// EventVersion is the version of the build event envelope understood by the manager,
+// described by build_event.schema.json
+const EventVersion = 1
+
+const (
+	EventStatusSucceeded = "succeeded"
+	EventStatusFailed    = "failed"
+)
+
+// Event is the message published by cobold for every step of a build.
+// Legacy events carry only Topic and Error, versioned ones the whole envelope.
+type Event struct {
+	Version     int               `json:"version,omitempty"`
+	EventId     string            `json:"event_id,omitempty"`
+	DeployId    string            `json:"deploy_id,omitempty"`
+	Step        string            `json:"step,omitempty"`
+	Status      string            `json:"status,omitempty"`
+	OccurredAt  time.Time         `json:"occurred_at,omitempty"`
+	PublishedAt time.Time         `json:"published_at,omitempty"`
+	Attempt     int               `json:"attempt,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+
+	Topic string `json:"topic,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func (m Event) IsLegacy() bool {
+	return m.Version == 0
+}
+
+func (m Event) ParseTopic() service.Step {
+	return parseStep(m.Topic)
+}
+
+func parseStep(name string) service.Step {
+	switch name {
+	case "clone":
+		return service.StepClone
+	case "build":
+		return service.StepBuild
+	case "push":
+		return service.StepPush
+	default:
+		return service.StepUnknown
+	}
+}
+
+// ParseBuildStep converts the body of a message of cobold, routed with the given workload id, to a build step
+func ParseBuildStep(workloadId string, body []byte) (*service.BuildStep, error) {
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, errors.Wrap(err, "Failed to parse message from cobold")
+	}
+
+	if event.IsLegacy() {
+		return parseLegacyEvent(workloadId, event, body)
+	}
+
+	return parseVersionedEvent(workloadId, event)
+}
+
+// MalformedBuildStep stands for an event which could not be parsed, so that the build fails along with it
+func MalformedBuildStep(err error) *service.BuildStep {
+	return &service.BuildStep{
+		Step:  service.StepUnknown,
+		Error: err.Error(),
+	}
+}
+
+func parseLegacyEvent(workloadId string, event Event, body []byte) (*service.BuildStep, error) {
+	step := event.ParseTopic()
+	if !step.IsValid() {
+		return nil, errors.Errorf("Failed to parse Topic name %q", event.Topic)
+	}
+
+	return &service.BuildStep{
+		Step:    step,
+		EventId: legacyEventId(workloadId, step, body),
+		Error:   event.Error,
+	}, nil
+}
+
+// legacyEventId derives the id of a legacy event from its content, so that its redeliveries are recorded once.
+// A build publishes every step once, while the steps are recorded anew by every build.
+func legacyEventId(workloadId string, step service.Step, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(workloadId))
+	hash.Write([]byte{0})
+	hash.Write([]byte(step.ToString()))
+	hash.Write([]byte{0})
+	hash.Write(body)
+
+	return "legacy-" + hex.EncodeToString(hash.Sum(nil))
+}
+
+func parseVersionedEvent(workloadId string, event Event) (*service.BuildStep, error) {
+	if err := validateEvent(workloadId, event); err != nil {
+		return nil, errors.Wrap(err, "Invalid build event")
+	}
+
+	buildStep := &service.BuildStep{
+		Step:       parseStep(event.Step),
+		EventId:    event.EventId,
+		Attempt:    event.Attempt,
+		OccurredAt: event.OccurredAt,
+		Details:    event.Details,
+	}
+	if event.Status == EventStatusFailed {
+		buildStep.Error = event.Error
+	}
+
+	return buildStep, nil
+}
+
+// validateEvent checks the envelope of an event routed with workloadId, whose deploy_id is the deploy of the workload
+func validateEvent(workloadId string, event Event) error {
+	if event.Version != EventVersion {
+		return errors.Errorf("unsupported version %d", event.Version)
+	}
+
+	if event.EventId == "" {
+		return errors.New("missing event_id")
+	}
+
+	if deployId := service.DeployIdOfWorkload(workloadId); event.DeployId != deployId {
+		return errors.Errorf("deploy_id %q does not match deploy %q", event.DeployId, deployId)
+	}
+
+	if step := parseStep(event.Step); !step.IsValid() {
+		return errors.Errorf("unknown step %q", event.Step)
+	}
+
+	switch event.Status {
+	case EventStatusSucceeded:
+	case EventStatusFailed:
+		if event.Error == "" {
+			return errors.New("missing error of failed step")
+		}
+	default:
+		return errors.Errorf("unknown status %q", event.Status)
+	}
+
+	if event.OccurredAt.IsZero() {
+		return errors.New("missing occurred_at")
+	}
+
+	if event.Attempt < 1 {
+		return errors.Errorf("invalid attempt %d", event.Attempt)
+	}
+
+	return nil
+}
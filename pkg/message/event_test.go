@@ -0,0 +1,90 @@
+package message
+
+import (
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"testing"
+)
+
+func versionedEvent(deployId string) []byte {
+	return []byte(`{
+		"version": 1,
+		"event_id": "0f8fad5b-d9cb-469f-a165-70867728950e",
+		"deploy_id": "` + deployId + `",
+		"step": "clone",
+		"status": "succeeded",
+		"occurred_at": "2021-09-08T10:00:00Z",
+		"attempt": 1
+	}`)
+}
+
+func TestParseBuildStepMatchesDeployOfWorkload(t *testing.T) {
+	for _, workloadId := range []string{
+		"my-project-6138c6d0c3b5a9d2e1b4f7a1",
+		// deploys created before projects are routed by their deploy id
+		"6138c6d0c3b5a9d2e1b4f7a1",
+	} {
+		buildStep, err := ParseBuildStep(workloadId, versionedEvent("6138c6d0c3b5a9d2e1b4f7a1"))
+		if err != nil {
+			t.Fatalf("ParseBuildStep(%s): %v", workloadId, err)
+		}
+		if buildStep.Step != service.StepClone {
+			t.Errorf("step %s, want clone", buildStep.Step.ToString())
+		}
+	}
+}
+
+func TestParseBuildStepRejectsOtherDeploy(t *testing.T) {
+	if _, err := ParseBuildStep("my-project-6138c6d0c3b5a9d2e1b4f7a1", versionedEvent("6138c6d0c3b5a9d2e1b4f7a2")); err == nil {
+		t.Error("event of another deploy accepted")
+	}
+
+	if _, err := ParseBuildStep("my-project-6138c6d0c3b5a9d2e1b4f7a1", versionedEvent("my-project-6138c6d0c3b5a9d2e1b4f7a1")); err == nil {
+		t.Error("event carrying the workload id as deploy_id accepted")
+	}
+}
+
+func TestParseBuildStepDerivesIdOfLegacyEvents(t *testing.T) {
+	const workloadId = "my-project-6138c6d0c3b5a9d2e1b4f7a1"
+
+	first, err := ParseBuildStep(workloadId, []byte(`{"topic":"clone"}`))
+	if err != nil {
+		t.Fatalf("ParseBuildStep: %v", err)
+	}
+	if first.EventId == "" {
+		t.Fatal("legacy event without id, its redeliveries would be recorded again")
+	}
+
+	redelivered, _ := ParseBuildStep(workloadId, []byte(`{"topic":"clone"}`))
+	if redelivered.EventId != first.EventId {
+		t.Errorf("redelivered event got id %q, want %q", redelivered.EventId, first.EventId)
+	}
+
+	for _, other := range []struct {
+		workloadId string
+		body       string
+	}{
+		{workloadId, `{"topic":"build"}`},
+		{workloadId, `{"topic":"clone","error":"repository not found"}`},
+		{"other-project-6138c6d0c3b5a9d2e1b4f7a2", `{"topic":"clone"}`},
+	} {
+		buildStep, err := ParseBuildStep(other.workloadId, []byte(other.body))
+		if err != nil {
+			t.Fatalf("ParseBuildStep(%s): %v", other.body, err)
+		}
+		if buildStep.EventId == first.EventId {
+			t.Errorf("event %s of %s shares the id of another event", other.body, other.workloadId)
+		}
+	}
+}
+
+func TestMalformedBuildStepFailsBuild(t *testing.T) {
+	_, err := ParseBuildStep("my-project-6138c6d0c3b5a9d2e1b4f7a1", []byte(`not json`))
+	if err == nil {
+		t.Fatal("malformed event parsed")
+	}
+
+	buildStep := MalformedBuildStep(err)
+	if buildStep.Step.IsValid() || buildStep.Error == "" {
+		t.Errorf("malformed event handled as step %s with error %q", buildStep.Step.ToString(), buildStep.Error)
+	}
+}
@@ -0,0 +1,90 @@
+package message
+
+import (
+	"context"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/metrics"
+	"time"
+)
+
+type messageInstrumenting struct {
+	next           service.Message
+	requestCount   metrics.Counter
+	errorCount     metrics.Counter
+	requestLatency metrics.Histogram
+}
+
+// InstrumentingMiddleware counts the calls and the errors of every method and observes their latency in seconds,
+// the metrics are labelled by method
+func InstrumentingMiddleware(requestCount metrics.Counter, errorCount metrics.Counter, requestLatency metrics.Histogram) Middleware {
+	return func(message service.Message) service.Message {
+		return &messageInstrumenting{
+			next:           message,
+			requestCount:   requestCount,
+			errorCount:     errorCount,
+			requestLatency: requestLatency,
+		}
+	}
+}
+
+func (m messageInstrumenting) observe(method string, begin time.Time, err error) {
+	m.requestCount.With("method", method).Add(1)
+	if err != nil {
+		m.errorCount.With("method", method).Add(1)
+	}
+	m.requestLatency.With("method", method).Observe(time.Since(begin).Seconds())
+}
+
+func (m messageInstrumenting) Init() (err error) {
+	defer func(begin time.Time) {
+		m.observe("Init", begin, err)
+	}(time.Now())
+
+	return m.next.Init()
+}
+
+// ConsumeBuildEvents also instruments the handling of every build event, as the HandleBuildEvent method
+func (m messageInstrumenting) ConsumeBuildEvents(handler service.BuildEventHandler) (err error) {
+	defer func(begin time.Time) {
+		m.observe("ConsumeBuildEvents", begin, err)
+	}(time.Now())
+
+	return m.next.ConsumeBuildEvents(func(ctx context.Context, workloadId string, event *service.BuildStep) (done bool, err error) {
+		defer func(begin time.Time) {
+			m.observe("HandleBuildEvent", begin, err)
+		}(time.Now())
+
+		return handler(ctx, workloadId, event)
+	})
+}
+
+func (m messageInstrumenting) WatchBuild(id string) (clear func() error, err error) {
+	defer func(begin time.Time) {
+		m.observe("WatchBuild", begin, err)
+	}(time.Now())
+
+	return m.next.WatchBuild(id)
+}
+
+// ConsumeDeadLetters also instruments the handling of every dead letter, as the HandleDeadLetter method
+func (m messageInstrumenting) ConsumeDeadLetters(handler service.DeadLetterHandler) (err error) {
+	defer func(begin time.Time) {
+		m.observe("ConsumeDeadLetters", begin, err)
+	}(time.Now())
+
+	return m.next.ConsumeDeadLetters(func(ctx context.Context, letter *service.DeadLetter) (err error) {
+		defer func(begin time.Time) {
+			m.observe("HandleDeadLetter", begin, err)
+		}(time.Now())
+
+		return handler(ctx, letter)
+	})
+}
+
+func (m messageInstrumenting) ReplayDeadLetter(letter *service.DeadLetter) (err error) {
+	defer func(begin time.Time) {
+		m.observe("ReplayDeadLetter", begin, err)
+	}(time.Now())
+
+	return m.next.ReplayDeadLetter(letter)
+}
@@ -32,14 +32,50 @@ func (m messageLogger) Init() (err error) {
 	return m.next.Init()
 }
 
-func (m messageLogger) ConsumeBuildEvents(id string) (events <-chan *service.BuildStep, clear func() error, err error) {
+func (m messageLogger) ConsumeBuildEvents(handler service.BuildEventHandler) (err error) {
 	defer func() {
 		m.logger.Log(
 			"method", "ConsumeBuildEvents",
+			"err", err,
+		)
+	}()
+
+	return m.next.ConsumeBuildEvents(handler)
+}
+
+func (m messageLogger) WatchBuild(id string) (clear func() error, err error) {
+	defer func() {
+		m.logger.Log(
+			"method", "WatchBuild",
 			"id", id,
 			"err", err,
 		)
 	}()
 
-	return m.next.ConsumeBuildEvents(id)
+	return m.next.WatchBuild(id)
+}
+
+func (m messageLogger) ConsumeDeadLetters(handler service.DeadLetterHandler) (err error) {
+	defer func() {
+		m.logger.Log(
+			"method", "ConsumeDeadLetters",
+			"err", err,
+		)
+	}()
+
+	return m.next.ConsumeDeadLetters(handler)
+}
+
+func (m messageLogger) ReplayDeadLetter(letter *service.DeadLetter) (err error) {
+	defer func() {
+		m.logger.Log(
+			"method", "ReplayDeadLetter",
+			"id", letter.Id,
+			"deployId", letter.DeployId,
+			"workloadId", letter.WorkloadId,
+			"err", err,
+		)
+	}()
+
+	return m.next.ReplayDeadLetter(letter)
 }
@@ -0,0 +1,306 @@
+package nats
+
+import (
+	"fmt"
+	middlewares "github.com/Scarlet-Fairy/manager/pkg/message"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	BuildImageStream   = "BUILD_IMAGE"
+	BuildImageSubject  = "build_image"
+	DeadLetterStream   = "BUILD_IMAGE_DEAD_LETTER"
+	DeadLetterSubject  = "build_image_dead_letter"
+	DeadLetterConsumer = "manager_dead_letter"
+	MaxRedeliveries    = 5
+	// firstRetryDelay is the delay of the first redelivery of a failing event, it doubles at every attempt up to
+	// maxRetryDelay, which must stay below the ack wait of the consumers, otherwise the server redelivers first
+	firstRetryDelay       = time.Second
+	maxRetryDelay         = 16 * time.Second
+	redeliveryCountHeader = "Redelivery-Count"
+	deadReasonHeader      = "Dead-Reason"
+	deployIdHeader        = "Deploy-Id"
+)
+
+// natsMessage receives the build events of a deploy on the subject build_image.<deploy id>
+// of a JetStream stream, through a durable consumer which lives as long as the build
+type natsMessage struct {
+	js     nats.JetStreamContext
+	logger log.Logger
+
+	handler service.BuildEventHandler
+}
+
+func New(js nats.JetStreamContext, logger log.Logger) service.Message {
+	var instance service.Message
+	instance = &natsMessage{
+		js:     js,
+		logger: logger,
+	}
+	instance = middlewares.LoggingMiddleware(logger)(instance)
+
+	return instance
+}
+
+func (m *natsMessage) Init() error {
+	if err := m.addStream(&nats.StreamConfig{
+		Name:      BuildImageStream,
+		Subjects:  []string{BuildImageSubject + ".*"},
+		Retention: nats.WorkQueuePolicy,
+		Storage:   nats.FileStorage,
+	}); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to add %s stream", BuildImageStream))
+	}
+
+	if err := m.addStream(&nats.StreamConfig{
+		Name:      DeadLetterStream,
+		Subjects:  []string{DeadLetterSubject + ".*"},
+		Retention: nats.WorkQueuePolicy,
+		Storage:   nats.FileStorage,
+	}); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to add %s stream", DeadLetterStream))
+	}
+
+	return nil
+}
+
+func (m *natsMessage) addStream(config *nats.StreamConfig) error {
+	if _, err := m.js.AddStream(config); err != nil {
+		// Streams already created by another replica are left as they are
+		if _, infoErr := m.js.StreamInfo(config.Name); infoErr == nil {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func subject(id string) string {
+	return fmt.Sprintf("%s.%s", BuildImageSubject, id)
+}
+
+func consumer(id string) string {
+	return fmt.Sprintf("build_%s", id)
+}
+
+// isConsumerNotFound tells whether err is the answer of the JetStream API to a request about a missing consumer,
+// which the client reports only by its description
+func isConsumerNotFound(err error) bool {
+	return err != nil && err.Error() == "nats: consumer not found"
+}
+
+func (m *natsMessage) ConsumeBuildEvents(handler service.BuildEventHandler) error {
+	m.handler = handler
+
+	return nil
+}
+
+func (m *natsMessage) WatchBuild(id string) (func() error, error) {
+	if m.handler == nil {
+		return nil, errors.New("build events are not being consumed")
+	}
+
+	var (
+		sub  *nats.Subscription
+		once sync.Once
+	)
+	clear := func() error {
+		var err error
+		once.Do(func() {
+			if err = sub.Unsubscribe(); err != nil && err != nats.ErrBadSubscription {
+				return
+			}
+
+			if err = m.js.DeleteConsumer(BuildImageStream, consumer(id)); err != nil {
+				// the consumer may have been removed along with the subscription
+				if _, infoErr := m.js.ConsumerInfo(BuildImageStream, consumer(id)); isConsumerNotFound(infoErr) {
+					err = nil
+				}
+			}
+		})
+
+		return err
+	}
+
+	sub, err := m.js.Subscribe(subject(id), func(msg *nats.Msg) {
+		if done := m.handleBuildEvent(id, msg); done {
+			// the subscription can't be removed from its own callback
+			go func() {
+				if err := clear(); err != nil {
+					level.Warn(m.logger).Log(
+						"msg", "failed to delete consumer",
+						"id", id,
+						"err", err,
+					)
+				}
+			}()
+		}
+	},
+		nats.Durable(consumer(id)),
+		nats.DeliverAll(),
+		nats.ManualAck(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return clear, nil
+}
+
+// nakLater asks for the redelivery of an event once delay is over, like the retry queues of the amqp broker do,
+// so that a failing event is not redelivered in a tight loop. The event stays unacknowledged meanwhile.
+func nakLater(msg *nats.Msg, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		_ = msg.Nak()
+	})
+}
+
+func retryDelay(redeliveries int) time.Duration {
+	delay := firstRetryDelay
+	for i := 0; i < redeliveries && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+
+	return delay
+}
+
+// deliveries counts the previous deliveries of a message by the consumer, regardless of the header it carries
+func deliveries(msg *nats.Msg) int {
+	if metadata, err := msg.Metadata(); err == nil && metadata.NumDelivered > 0 {
+		return int(metadata.NumDelivered) - 1
+	}
+
+	return 0
+}
+
+func redeliveryCount(msg *nats.Msg) int {
+	if count, err := strconv.Atoi(msg.Header.Get(redeliveryCountHeader)); err == nil {
+		return count
+	}
+
+	return deliveries(msg)
+}
+
+// handleBuildEvent acknowledges the event only after it has been handled successfully.
+// Malformed events fail the build of their deploy and are dead lettered, while failing ones are redelivered
+// until MaxRedeliveries.
+// Events refused during a shutdown are left unacknowledged, they are redelivered once their ack wait expires.
+func (m *natsMessage) handleBuildEvent(id string, msg *nats.Msg) bool {
+	buildStep, parseErr := middlewares.ParseBuildStep(id, msg.Data)
+	if parseErr != nil {
+		buildStep = middlewares.MalformedBuildStep(parseErr)
+	}
+
+	done, err := m.handler(contextOf(msg), id, buildStep)
+	if err != nil {
+		if errors.Is(err, service.ErrShuttingDown) {
+			return false
+		}
+
+		switch {
+		case errors.Is(err, service.ErrInvalidEvent) && parseErr != nil:
+			m.reject(id, msg, parseErr)
+		case errors.Is(err, service.ErrInvalidEvent) || redeliveryCount(msg) >= MaxRedeliveries:
+			m.reject(id, msg, err)
+		default:
+			nakLater(msg, retryDelay(redeliveryCount(msg)))
+		}
+
+		return false
+	}
+
+	if err := msg.Ack(); err != nil {
+		level.Warn(m.logger).Log(
+			"msg", "failed to ack event",
+			"id", id,
+			"err", err,
+		)
+	}
+
+	return done
+}
+
+// reject moves the event to the dead letter stream, recording the reason of the failure
+func (m *natsMessage) reject(id string, msg *nats.Msg, reason error) {
+	level.Warn(m.logger).Log(
+		"msg", "dead lettering build event",
+		"id", id,
+		"err", reason,
+	)
+
+	deadLetter := &nats.Msg{
+		Subject: fmt.Sprintf("%s.%s", DeadLetterSubject, id),
+		Header:  nats.Header{},
+		Data:    msg.Data,
+	}
+	deadLetter.Header.Set(deployIdHeader, id)
+	deadLetter.Header.Set(deadReasonHeader, reason.Error())
+	deadLetter.Header.Set(redeliveryCountHeader, strconv.Itoa(redeliveryCount(msg)))
+	copyTraceHeaders(deadLetter.Header, msg)
+
+	if _, err := m.js.PublishMsg(deadLetter); err != nil {
+		nakLater(msg, retryDelay(redeliveryCount(msg)))
+		return
+	}
+
+	_ = msg.Term()
+}
+
+func (m *natsMessage) ConsumeDeadLetters(handler service.DeadLetterHandler) error {
+	_, err := m.js.Subscribe(DeadLetterSubject+".*", func(msg *nats.Msg) {
+		letter := &service.DeadLetter{
+			WorkloadId: msg.Header.Get(deployIdHeader),
+			Body:       msg.Data,
+			Reason:     msg.Header.Get(deadReasonHeader),
+			Attempts:   redeliveryCount(msg),
+			DeadAt:     time.Now(),
+		}
+		if metadata, err := msg.Metadata(); err == nil {
+			letter.DeadAt = metadata.Timestamp
+		}
+
+		if err := handler(contextOf(msg), letter); err != nil {
+			if !errors.Is(err, service.ErrShuttingDown) {
+				nakLater(msg, retryDelay(deliveries(msg)))
+			}
+			return
+		}
+
+		_ = msg.Ack()
+	},
+		nats.Durable(DeadLetterConsumer),
+		nats.DeliverAll(),
+		nats.ManualAck(),
+	)
+
+	return err
+}
+
+// ReplayDeadLetter publishes again a dead letter on the subject of its workload, provided that the consumer
+// of the build is still there: otherwise the letter would be kept by the stream without ever being consumed
+func (m *natsMessage) ReplayDeadLetter(letter *service.DeadLetter) error {
+	if _, err := m.js.ConsumerInfo(BuildImageStream, consumer(letter.WorkloadId)); err != nil {
+		if isConsumerNotFound(err) {
+			return errors.Wrap(service.ErrNotWatched, letter.WorkloadId)
+		}
+
+		return err
+	}
+
+	_, err := m.js.Publish(subject(letter.WorkloadId), letter.Body)
+
+	return err
+}
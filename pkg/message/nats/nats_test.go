@@ -0,0 +1,294 @@
+package nats
+
+import (
+	"context"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/log"
+	"github.com/nats-io/nats-server/v2/server"
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	workloadId = "project-6138c6d0c3b5a9d2e1b4f7a1"
+	waitFor    = 5 * time.Second
+)
+
+// runJetStream starts an embedded server with JetStream enabled, returning a context on it
+func runJetStream(t *testing.T) nats.JetStreamContext {
+	t.Helper()
+
+	opts := natsserver.DefaultTestOptions
+	opts.Port = server.RANDOM_PORT
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	s := natsserver.RunServer(&opts)
+	t.Cleanup(s.Shutdown)
+
+	conn, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("connecting to nats: %v", err)
+	}
+	t.Cleanup(conn.Close)
+
+	js, err := conn.JetStream()
+	if err != nil {
+		t.Fatalf("getting jetstream context: %v", err)
+	}
+
+	return js
+}
+
+func newMessage(t *testing.T, js nats.JetStreamContext) service.Message {
+	t.Helper()
+
+	m := New(js, log.NewNopLogger())
+	if err := m.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	return m
+}
+
+func publish(t *testing.T, js nats.JetStreamContext, body string) {
+	t.Helper()
+
+	if _, err := js.Publish(subject(workloadId), []byte(body)); err != nil {
+		t.Fatalf("publishing event: %v", err)
+	}
+}
+
+// eventually polls condition until it holds or waitFor is over
+func eventually(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(waitFor)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", description)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// recorder is a BuildEventHandler failing with the errors it is given, one per event, and recording the steps.
+// Like the service, it fails the events of invalid steps.
+type recorder struct {
+	mu     sync.Mutex
+	steps  []service.Step
+	errors []error
+}
+
+func (r *recorder) handle(_ context.Context, id string, event *service.BuildStep) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.steps = append(r.steps, event.Step)
+	if !event.Step.IsValid() {
+		return false, errors.Wrap(service.ErrInvalidEvent, "Build failed")
+	}
+	if len(r.errors) > 0 {
+		err := r.errors[0]
+		r.errors = r.errors[1:]
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return event.Step == service.StepPush, nil
+}
+
+func (r *recorder) handled() []service.Step {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]service.Step{}, r.steps...)
+}
+
+func pendingEvents(t *testing.T, js nats.JetStreamContext, stream string) uint64 {
+	t.Helper()
+
+	info, err := js.StreamInfo(stream)
+	if err != nil {
+		t.Fatalf("StreamInfo: %v", err)
+	}
+
+	return info.State.Msgs
+}
+
+func consumerExists(js nats.JetStreamContext) bool {
+	_, err := js.ConsumerInfo(BuildImageStream, consumer(workloadId))
+	return !isConsumerNotFound(err)
+}
+
+func TestWatchBuildConsumesEventsUntilDone(t *testing.T) {
+	js := runJetStream(t)
+	m := newMessage(t, js)
+
+	handler := &recorder{}
+	if err := m.ConsumeBuildEvents(handler.handle); err != nil {
+		t.Fatalf("ConsumeBuildEvents: %v", err)
+	}
+	if _, err := m.WatchBuild(workloadId); err != nil {
+		t.Fatalf("WatchBuild: %v", err)
+	}
+
+	publish(t, js, `{"topic":"clone"}`)
+	publish(t, js, `{"topic":"build"}`)
+	publish(t, js, `{"topic":"push"}`)
+
+	eventually(t, "the events to be handled", func() bool {
+		return len(handler.handled()) == 3
+	})
+	steps := handler.handled()
+	if steps[0] != service.StepClone || steps[1] != service.StepBuild || steps[2] != service.StepPush {
+		t.Errorf("handled steps %v, want clone, build, push", steps)
+	}
+
+	// the work queue drops the acknowledged events
+	eventually(t, "the events to be acknowledged", func() bool {
+		return pendingEvents(t, js, BuildImageStream) == 0
+	})
+	eventually(t, "the consumer of the finished build to be deleted", func() bool {
+		return !consumerExists(js)
+	})
+}
+
+func TestWatchBuildRedeliversFailedEvents(t *testing.T) {
+	js := runJetStream(t)
+	m := newMessage(t, js)
+
+	handler := &recorder{
+		errors: []error{errors.New("repository unavailable")},
+	}
+	if err := m.ConsumeBuildEvents(handler.handle); err != nil {
+		t.Fatalf("ConsumeBuildEvents: %v", err)
+	}
+	if _, err := m.WatchBuild(workloadId); err != nil {
+		t.Fatalf("WatchBuild: %v", err)
+	}
+
+	published := time.Now()
+	publish(t, js, `{"topic":"clone"}`)
+
+	eventually(t, "the nacked event to be redelivered", func() bool {
+		return len(handler.handled()) == 2
+	})
+	if elapsed := time.Since(published); elapsed < firstRetryDelay {
+		t.Errorf("event redelivered after %s, before its backoff of %s", elapsed, firstRetryDelay)
+	}
+	eventually(t, "the redelivered event to be acknowledged", func() bool {
+		return pendingEvents(t, js, BuildImageStream) == 0
+	})
+	if count := pendingEvents(t, js, DeadLetterStream); count != 0 {
+		t.Errorf("%d dead letters, want none", count)
+	}
+}
+
+func TestWatchBuildDeadLettersInvalidEvents(t *testing.T) {
+	js := runJetStream(t)
+	m := newMessage(t, js)
+
+	handler := &recorder{
+		errors: []error{errors.Wrap(service.ErrInvalidEvent, "Build failed")},
+	}
+	if err := m.ConsumeBuildEvents(handler.handle); err != nil {
+		t.Fatalf("ConsumeBuildEvents: %v", err)
+	}
+	if _, err := m.WatchBuild(workloadId); err != nil {
+		t.Fatalf("WatchBuild: %v", err)
+	}
+
+	letters := make(chan *service.DeadLetter, 2)
+	if err := m.ConsumeDeadLetters(func(_ context.Context, letter *service.DeadLetter) error {
+		letters <- letter
+		return nil
+	}); err != nil {
+		t.Fatalf("ConsumeDeadLetters: %v", err)
+	}
+
+	publish(t, js, `{"topic":"clone"}`)
+	publish(t, js, `not json`)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case letter := <-letters:
+			if letter.WorkloadId != workloadId {
+				t.Errorf("dead letter of %q, want %q", letter.WorkloadId, workloadId)
+			}
+			if letter.Reason == "" {
+				t.Error("dead letter without reason")
+			}
+		case <-time.After(waitFor):
+			t.Fatalf("timed out waiting for dead letter %d", i+1)
+		}
+	}
+
+	// the rejected events are terminated rather than redelivered
+	eventually(t, "the dead letters to be acknowledged", func() bool {
+		return pendingEvents(t, js, DeadLetterStream) == 0
+	})
+	// the malformed event reaches the handler as well, which fails the build
+	if steps := handler.handled(); len(steps) != 2 || steps[1] != service.StepUnknown {
+		t.Errorf("handled steps %v, want clone and the malformed one", steps)
+	}
+}
+
+func TestClearDeletesConsumer(t *testing.T) {
+	js := runJetStream(t)
+	m := newMessage(t, js)
+
+	if err := m.ConsumeBuildEvents((&recorder{}).handle); err != nil {
+		t.Fatalf("ConsumeBuildEvents: %v", err)
+	}
+	clear, err := m.WatchBuild(workloadId)
+	if err != nil {
+		t.Fatalf("WatchBuild: %v", err)
+	}
+	if !consumerExists(js) {
+		t.Fatal("consumer not created by WatchBuild")
+	}
+
+	if err := clear(); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if consumerExists(js) {
+		t.Error("consumer still exists after clear")
+	}
+
+	if err := clear(); err != nil {
+		t.Errorf("second clear: %v", err)
+	}
+}
+
+func TestReplayDeadLetterRequiresWatchedBuild(t *testing.T) {
+	js := runJetStream(t)
+	m := newMessage(t, js)
+
+	letter := &service.DeadLetter{
+		WorkloadId: workloadId,
+		Body:       []byte(`{"topic":"clone"}`),
+	}
+	if err := m.ReplayDeadLetter(letter); !errors.Is(err, service.ErrNotWatched) {
+		t.Fatalf("replay of an unwatched build returned %v, want ErrNotWatched", err)
+	}
+
+	handler := &recorder{}
+	if err := m.ConsumeBuildEvents(handler.handle); err != nil {
+		t.Fatalf("ConsumeBuildEvents: %v", err)
+	}
+	if _, err := m.WatchBuild(workloadId); err != nil {
+		t.Fatalf("WatchBuild: %v", err)
+	}
+
+	if err := m.ReplayDeadLetter(letter); err != nil {
+		t.Fatalf("ReplayDeadLetter: %v", err)
+	}
+	eventually(t, "the replayed event to be handled", func() bool {
+		return len(handler.handled()) == 1
+	})
+}
@@ -0,0 +1,24 @@
+package nats
+
+import (
+	"context"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"net/http"
+)
+
+// contextOf returns a context carrying the trace of a message, if any,
+// cobold propagates the trace of the build in the headers of its events
+func contextOf(msg *nats.Msg) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(http.Header(msg.Header)))
+}
+
+// copyTraceHeaders copies the trace context of a message into the headers of the message republished from it
+func copyTraceHeaders(header nats.Header, msg *nats.Msg) {
+	for _, field := range otel.GetTextMapPropagator().Fields() {
+		if value := msg.Header.Get(field); value != "" {
+			header.Set(field, value)
+		}
+	}
+}
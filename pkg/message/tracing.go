@@ -0,0 +1,76 @@
+package message
+
+import (
+	"context"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type messageTracing struct {
+	next   service.Message
+	tracer trace.Tracer
+}
+
+// TracingMiddleware records a span for the handling of every build event and dead letter,
+// as a child of the trace propagated in the headers of the message, if any
+func TracingMiddleware(tracer trace.Tracer) Middleware {
+	return func(message service.Message) service.Message {
+		return &messageTracing{
+			next:   message,
+			tracer: tracer,
+		}
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (m messageTracing) Init() error {
+	return m.next.Init()
+}
+
+func (m messageTracing) ConsumeBuildEvents(handler service.BuildEventHandler) error {
+	return m.next.ConsumeBuildEvents(func(ctx context.Context, workloadId string, event *service.BuildStep) (done bool, err error) {
+		ctx, span := m.tracer.Start(ctx, "message.HandleBuildEvent",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("workload.id", workloadId),
+				attribute.String("build.step", event.Step.ToString()),
+			),
+		)
+		defer func() {
+			endSpan(span, err)
+		}()
+
+		return handler(ctx, workloadId, event)
+	})
+}
+
+func (m messageTracing) WatchBuild(id string) (func() error, error) {
+	return m.next.WatchBuild(id)
+}
+
+func (m messageTracing) ConsumeDeadLetters(handler service.DeadLetterHandler) error {
+	return m.next.ConsumeDeadLetters(func(ctx context.Context, letter *service.DeadLetter) (err error) {
+		ctx, span := m.tracer.Start(ctx, "message.HandleDeadLetter",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(attribute.String("workload.id", letter.WorkloadId)),
+		)
+		defer func() {
+			endSpan(span, err)
+		}()
+
+		return handler(ctx, letter)
+	})
+}
+
+func (m messageTracing) ReplayDeadLetter(letter *service.DeadLetter) error {
+	return m.next.ReplayDeadLetter(letter)
+}
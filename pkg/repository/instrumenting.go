@@ -0,0 +1,411 @@
+package repository
+
+import (
+	"context"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type repositoryInstrumenting struct {
+	next           service.Repository
+	requestCount   metrics.Counter
+	errorCount     metrics.Counter
+	requestLatency metrics.Histogram
+}
+
+// InstrumentingMiddleware counts the calls and the errors of every method and observes their latency in seconds,
+// the metrics are labelled by method
+func InstrumentingMiddleware(requestCount metrics.Counter, errorCount metrics.Counter, requestLatency metrics.Histogram) Middleware {
+	return func(repository service.Repository) service.Repository {
+		return &repositoryInstrumenting{
+			next:           repository,
+			requestCount:   requestCount,
+			errorCount:     errorCount,
+			requestLatency: requestLatency,
+		}
+	}
+}
+
+func (r repositoryInstrumenting) observe(method string, begin time.Time, err error) {
+	r.requestCount.With("method", method).Add(1)
+	if err != nil {
+		r.errorCount.With("method", method).Add(1)
+	}
+	r.requestLatency.With("method", method).Observe(time.Since(begin).Seconds())
+}
+
+func (r repositoryInstrumenting) Init(ctx context.Context) (err error) {
+	defer func(begin time.Time) {
+		r.observe("Init", begin, err)
+	}(time.Now())
+
+	return r.next.Init(ctx)
+}
+
+func (r repositoryInstrumenting) CreateDeploy(ctx context.Context, deploy *service.Deploy) (id string, err error) {
+	defer func(begin time.Time) {
+		r.observe("CreateDeploy", begin, err)
+	}(time.Now())
+
+	return r.next.CreateDeploy(ctx, deploy)
+}
+
+func (r repositoryInstrumenting) GetDeploy(ctx context.Context, id string) (deploy *service.Deploy, err error) {
+	defer func(begin time.Time) {
+		r.observe("GetDeploy", begin, err)
+	}(time.Now())
+
+	return r.next.GetDeploy(ctx, id)
+}
+
+func (r repositoryInstrumenting) GetDeployByName(ctx context.Context, projectId string, name string) (deploy *service.Deploy, err error) {
+	defer func(begin time.Time) {
+		r.observe("GetDeployByName", begin, err)
+	}(time.Now())
+
+	return r.next.GetDeployByName(ctx, projectId, name)
+}
+
+func (r repositoryInstrumenting) ListDeploy(ctx context.Context, projects []string) (deploys []*service.Deploy, err error) {
+	defer func(begin time.Time) {
+		r.observe("ListDeploy", begin, err)
+	}(time.Now())
+
+	return r.next.ListDeploy(ctx, projects)
+}
+
+func (r repositoryInstrumenting) UpdateDeploy(ctx context.Context, deploy *service.Deploy, tasks []*service.Task) (err error) {
+	defer func(begin time.Time) {
+		r.observe("UpdateDeploy", begin, err)
+	}(time.Now())
+
+	return r.next.UpdateDeploy(ctx, deploy, tasks)
+}
+
+func (r repositoryInstrumenting) DeleteDeploy(ctx context.Context, id string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("DeleteDeploy", begin, err)
+	}(time.Now())
+
+	return r.next.DeleteDeploy(ctx, id)
+}
+
+func (r repositoryInstrumenting) InitBuild(ctx context.Context, id string, jobName, jobId, imageName string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("InitBuild", begin, err)
+	}(time.Now())
+
+	return r.next.InitBuild(ctx, id, jobName, jobId, imageName)
+}
+
+func (r repositoryInstrumenting) InitWorkload(ctx context.Context, id string, jobName, jobId string, envs map[string]string, url string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("InitWorkload", begin, err)
+	}(time.Now())
+
+	return r.next.InitWorkload(ctx, id, jobName, jobId, envs, url)
+}
+
+func (r repositoryInstrumenting) SetBuildStatus(ctx context.Context, id string, status service.Status) (err error) {
+	defer func(begin time.Time) {
+		r.observe("SetBuildStatus", begin, err)
+	}(time.Now())
+
+	return r.next.SetBuildStatus(ctx, id, status)
+}
+
+func (r repositoryInstrumenting) RecordBuildStep(ctx context.Context, id string, buildStep service.BuildStep) (err error) {
+	defer func(begin time.Time) {
+		r.observe("RecordBuildStep", begin, err)
+	}(time.Now())
+
+	return r.next.RecordBuildStep(ctx, id, buildStep)
+}
+
+func (r repositoryInstrumenting) SetWorkloadStatus(ctx context.Context, id string, status service.WorkloadStatus) (err error) {
+	defer func(begin time.Time) {
+		r.observe("SetWorkloadStatus", begin, err)
+	}(time.Now())
+
+	return r.next.SetWorkloadStatus(ctx, id, status)
+}
+
+func (r repositoryInstrumenting) SetWorkloadHealth(ctx context.Context, id string, health service.WorkloadHealth) (err error) {
+	defer func(begin time.Time) {
+		r.observe("SetWorkloadHealth", begin, err)
+	}(time.Now())
+
+	return r.next.SetWorkloadHealth(ctx, id, health)
+}
+
+func (r repositoryInstrumenting) ListQueuedBuilds(ctx context.Context) (deploys []*service.Deploy, err error) {
+	defer func(begin time.Time) {
+		r.observe("ListQueuedBuilds", begin, err)
+	}(time.Now())
+
+	return r.next.ListQueuedBuilds(ctx)
+}
+
+func (r repositoryInstrumenting) CountBuildsInProgress(ctx context.Context) (counts map[string]int, err error) {
+	defer func(begin time.Time) {
+		r.observe("CountBuildsInProgress", begin, err)
+	}(time.Now())
+
+	return r.next.CountBuildsInProgress(ctx)
+}
+
+func (r repositoryInstrumenting) TimeoutBuilds(ctx context.Context, startedBefore time.Time) (failed int64, err error) {
+	defer func(begin time.Time) {
+		r.observe("TimeoutBuilds", begin, err)
+	}(time.Now())
+
+	return r.next.TimeoutBuilds(ctx, startedBefore)
+}
+
+func (r repositoryInstrumenting) AdmitBuild(ctx context.Context, id string, task *service.Task) (err error) {
+	defer func(begin time.Time) {
+		r.observe("AdmitBuild", begin, err)
+	}(time.Now())
+
+	return r.next.AdmitBuild(ctx, id, task)
+}
+
+func (r repositoryInstrumenting) EnqueueTask(ctx context.Context, id string, task *service.Task) (err error) {
+	defer func(begin time.Time) {
+		r.observe("EnqueueTask", begin, err)
+	}(time.Now())
+
+	return r.next.EnqueueTask(ctx, id, task)
+}
+
+func (r repositoryInstrumenting) ClaimTask(ctx context.Context, lease time.Duration) (id string, task *service.Task, err error) {
+	defer func(begin time.Time) {
+		// no task to claim is the outcome of most of the polls, rather than an error
+		if errors.Is(err, service.ErrNotFound) {
+			r.observe("ClaimTask", begin, nil)
+			return
+		}
+
+		r.observe("ClaimTask", begin, err)
+	}(time.Now())
+
+	return r.next.ClaimTask(ctx, lease)
+}
+
+func (r repositoryInstrumenting) RetryTask(ctx context.Context, id string, taskId string, nextAttemptAt time.Time, lastError string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("RetryTask", begin, err)
+	}(time.Now())
+
+	return r.next.RetryTask(ctx, id, taskId, nextAttemptAt, lastError)
+}
+
+func (r repositoryInstrumenting) CompleteTask(ctx context.Context, id string, taskId string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("CompleteTask", begin, err)
+	}(time.Now())
+
+	return r.next.CompleteTask(ctx, id, taskId)
+}
+
+func (r repositoryInstrumenting) CreateAuditEvent(ctx context.Context, event *service.AuditEvent) (id string, err error) {
+	defer func(begin time.Time) {
+		r.observe("CreateAuditEvent", begin, err)
+	}(time.Now())
+
+	return r.next.CreateAuditEvent(ctx, event)
+}
+
+func (r repositoryInstrumenting) ListAuditEvents(ctx context.Context, filter service.AuditFilter, projects []string) (events []*service.AuditEvent, err error) {
+	defer func(begin time.Time) {
+		r.observe("ListAuditEvents", begin, err)
+	}(time.Now())
+
+	return r.next.ListAuditEvents(ctx, filter, projects)
+}
+
+func (r repositoryInstrumenting) CreateDeadLetter(ctx context.Context, letter *service.DeadLetter) (id string, err error) {
+	defer func(begin time.Time) {
+		r.observe("CreateDeadLetter", begin, err)
+	}(time.Now())
+
+	return r.next.CreateDeadLetter(ctx, letter)
+}
+
+func (r repositoryInstrumenting) GetDeadLetter(ctx context.Context, id string) (letter *service.DeadLetter, err error) {
+	defer func(begin time.Time) {
+		r.observe("GetDeadLetter", begin, err)
+	}(time.Now())
+
+	return r.next.GetDeadLetter(ctx, id)
+}
+
+func (r repositoryInstrumenting) ListDeadLetters(ctx context.Context, deployId string) (letters []*service.DeadLetter, err error) {
+	defer func(begin time.Time) {
+		r.observe("ListDeadLetters", begin, err)
+	}(time.Now())
+
+	return r.next.ListDeadLetters(ctx, deployId)
+}
+
+func (r repositoryInstrumenting) DeleteDeadLetter(ctx context.Context, id string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("DeleteDeadLetter", begin, err)
+	}(time.Now())
+
+	return r.next.DeleteDeadLetter(ctx, id)
+}
+
+func (r repositoryInstrumenting) CreateDomain(ctx context.Context, domain *service.Domain) (err error) {
+	defer func(begin time.Time) {
+		r.observe("CreateDomain", begin, err)
+	}(time.Now())
+
+	return r.next.CreateDomain(ctx, domain)
+}
+
+func (r repositoryInstrumenting) GetDomain(ctx context.Context, name string) (domain *service.Domain, err error) {
+	defer func(begin time.Time) {
+		r.observe("GetDomain", begin, err)
+	}(time.Now())
+
+	return r.next.GetDomain(ctx, name)
+}
+
+func (r repositoryInstrumenting) ListDomains(ctx context.Context, deployId string) (domains []*service.Domain, err error) {
+	defer func(begin time.Time) {
+		r.observe("ListDomains", begin, err)
+	}(time.Now())
+
+	return r.next.ListDomains(ctx, deployId)
+}
+
+func (r repositoryInstrumenting) VerifyDomain(ctx context.Context, name string, token string, verifiedAt time.Time) (err error) {
+	defer func(begin time.Time) {
+		r.observe("VerifyDomain", begin, err)
+	}(time.Now())
+
+	return r.next.VerifyDomain(ctx, name, token, verifiedAt)
+}
+
+func (r repositoryInstrumenting) DeleteDomain(ctx context.Context, name string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("DeleteDomain", begin, err)
+	}(time.Now())
+
+	return r.next.DeleteDomain(ctx, name)
+}
+
+func (r repositoryInstrumenting) DeleteDomains(ctx context.Context, deployId string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("DeleteDomains", begin, err)
+	}(time.Now())
+
+	return r.next.DeleteDomains(ctx, deployId)
+}
+
+func (r repositoryInstrumenting) CreateIdempotencyKey(ctx context.Context, key *service.IdempotencyKey) (err error) {
+	defer func(begin time.Time) {
+		r.observe("CreateIdempotencyKey", begin, err)
+	}(time.Now())
+
+	return r.next.CreateIdempotencyKey(ctx, key)
+}
+
+func (r repositoryInstrumenting) GetIdempotencyKey(ctx context.Context, key string, method string) (idempotencyKey *service.IdempotencyKey, err error) {
+	defer func(begin time.Time) {
+		r.observe("GetIdempotencyKey", begin, err)
+	}(time.Now())
+
+	return r.next.GetIdempotencyKey(ctx, key, method)
+}
+
+func (r repositoryInstrumenting) CompleteIdempotencyKey(ctx context.Context, key string, method string, result string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("CompleteIdempotencyKey", begin, err)
+	}(time.Now())
+
+	return r.next.CompleteIdempotencyKey(ctx, key, method, result)
+}
+
+func (r repositoryInstrumenting) DeleteIdempotencyKey(ctx context.Context, key string, method string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("DeleteIdempotencyKey", begin, err)
+	}(time.Now())
+
+	return r.next.DeleteIdempotencyKey(ctx, key, method)
+}
+
+func (r repositoryInstrumenting) CreateProject(ctx context.Context, project *service.Project) (err error) {
+	defer func(begin time.Time) {
+		r.observe("CreateProject", begin, err)
+	}(time.Now())
+
+	return r.next.CreateProject(ctx, project)
+}
+
+func (r repositoryInstrumenting) GetProject(ctx context.Context, id string) (project *service.Project, err error) {
+	defer func(begin time.Time) {
+		r.observe("GetProject", begin, err)
+	}(time.Now())
+
+	return r.next.GetProject(ctx, id)
+}
+
+func (r repositoryInstrumenting) ListProjects(ctx context.Context, member string) (projects []*service.Project, err error) {
+	defer func(begin time.Time) {
+		r.observe("ListProjects", begin, err)
+	}(time.Now())
+
+	return r.next.ListProjects(ctx, member)
+}
+
+func (r repositoryInstrumenting) DeleteProject(ctx context.Context, id string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("DeleteProject", begin, err)
+	}(time.Now())
+
+	return r.next.DeleteProject(ctx, id)
+}
+
+func (r repositoryInstrumenting) AddProjectMember(ctx context.Context, id string, subject string) (project *service.Project, err error) {
+	defer func(begin time.Time) {
+		r.observe("AddProjectMember", begin, err)
+	}(time.Now())
+
+	return r.next.AddProjectMember(ctx, id, subject)
+}
+
+func (r repositoryInstrumenting) RemoveProjectMember(ctx context.Context, id string, subject string) (project *service.Project, err error) {
+	defer func(begin time.Time) {
+		r.observe("RemoveProjectMember", begin, err)
+	}(time.Now())
+
+	return r.next.RemoveProjectMember(ctx, id, subject)
+}
+
+func (r repositoryInstrumenting) LockProject(ctx context.Context, id string, holder string, until time.Time) (err error) {
+	defer func(begin time.Time) {
+		r.observe("LockProject", begin, err)
+	}(time.Now())
+
+	return r.next.LockProject(ctx, id, holder, until)
+}
+
+func (r repositoryInstrumenting) UnlockProject(ctx context.Context, id string, holder string) (err error) {
+	defer func(begin time.Time) {
+		r.observe("UnlockProject", begin, err)
+	}(time.Now())
+
+	return r.next.UnlockProject(ctx, id, holder)
+}
+
+func (r repositoryInstrumenting) SetProjectQuota(ctx context.Context, id string, quota *service.Quota) (project *service.Project, err error) {
+	defer func(begin time.Time) {
+		r.observe("SetProjectQuota", begin, err)
+	}(time.Now())
+
+	return r.next.SetProjectQuota(ctx, id, quota)
+}
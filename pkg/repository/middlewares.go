@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/Scarlet-Fairy/manager/pkg/service"
 	"github.com/go-kit/kit/log"
+	"time"
 )
 
 type Middleware func(repository service.Repository) service.Repository
@@ -22,6 +23,17 @@ func LoggingMiddleware(logger log.Logger) Middleware {
 	}
 }
 
+func (r repositoryLogger) Init(ctx context.Context) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "Init",
+			"err", err,
+		)
+	}()
+
+	return r.next.Init(ctx)
+}
+
 func (r repositoryLogger) CreateDeploy(ctx context.Context, deploy *service.Deploy) (id string, err error) {
 	defer func() {
 		r.logger.Log(
@@ -48,41 +60,44 @@ func (r repositoryLogger) GetDeploy(ctx context.Context, id string) (deploy *ser
 	return r.next.GetDeploy(ctx, id)
 }
 
-func (r repositoryLogger) GetDeployByName(ctx context.Context, name string) (deploy *service.Deploy, err error) {
+func (r repositoryLogger) GetDeployByName(ctx context.Context, projectId string, name string) (deploy *service.Deploy, err error) {
 	defer func() {
 		r.logger.Log(
 			"method", "GetDeployByName",
+			"projectId", projectId,
 			"name", name,
 			"deploy", deploy,
 			"err", err,
 		)
 	}()
 
-	return r.next.GetDeployByName(ctx, name)
+	return r.next.GetDeployByName(ctx, projectId, name)
 }
 
-func (r repositoryLogger) ListDeploy(ctx context.Context) (deploys []*service.Deploy, err error) {
+func (r repositoryLogger) ListDeploy(ctx context.Context, projects []string) (deploys []*service.Deploy, err error) {
 	defer func() {
 		r.logger.Log(
 			"method", "ListDeploy",
+			"projects", projects,
 			"deploys", deploys,
 			"err", err,
 		)
 	}()
 
-	return r.next.ListDeploy(ctx)
+	return r.next.ListDeploy(ctx, projects)
 }
 
-func (r repositoryLogger) UpdateDeploy(ctx context.Context, deploy *service.Deploy) (err error) {
+func (r repositoryLogger) UpdateDeploy(ctx context.Context, deploy *service.Deploy, tasks []*service.Task) (err error) {
 	defer func() {
 		r.logger.Log(
 			"method", "UpdateDeploy",
 			"deploy", deploy,
+			"tasks", len(tasks),
 			"err", err,
 		)
 	}()
 
-	return r.next.UpdateDeploy(ctx, deploy)
+	return r.next.UpdateDeploy(ctx, deploy, tasks)
 }
 
 func (r repositoryLogger) DeleteDeploy(ctx context.Context, id string) (err error) {
@@ -153,3 +168,460 @@ func (r repositoryLogger) RecordBuildStep(ctx context.Context, id string, buildS
 
 	return r.next.RecordBuildStep(ctx, id, buildStep)
 }
+
+func (r repositoryLogger) SetWorkloadStatus(ctx context.Context, id string, status service.WorkloadStatus) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "SetWorkloadStatus",
+			"id", id,
+			"status", status.ToString(),
+			"err", err,
+		)
+	}()
+
+	return r.next.SetWorkloadStatus(ctx, id, status)
+}
+
+func (r repositoryLogger) SetWorkloadHealth(ctx context.Context, id string, health service.WorkloadHealth) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "SetWorkloadHealth",
+			"id", id,
+			"state", health.State.ToString(),
+			"restarts", health.Restarts,
+			"missing", health.Missing,
+			"err", err,
+		)
+	}()
+
+	return r.next.SetWorkloadHealth(ctx, id, health)
+}
+
+func (r repositoryLogger) ListQueuedBuilds(ctx context.Context) (deploys []*service.Deploy, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "ListQueuedBuilds",
+			"deploys", len(deploys),
+			"err", err,
+		)
+	}()
+
+	return r.next.ListQueuedBuilds(ctx)
+}
+
+func (r repositoryLogger) CountBuildsInProgress(ctx context.Context) (counts map[string]int, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "CountBuildsInProgress",
+			"projects", len(counts),
+			"err", err,
+		)
+	}()
+
+	return r.next.CountBuildsInProgress(ctx)
+}
+
+func (r repositoryLogger) TimeoutBuilds(ctx context.Context, startedBefore time.Time) (failed int64, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "TimeoutBuilds",
+			"startedBefore", startedBefore,
+			"failed", failed,
+			"err", err,
+		)
+	}()
+
+	return r.next.TimeoutBuilds(ctx, startedBefore)
+}
+
+func (r repositoryLogger) AdmitBuild(ctx context.Context, id string, task *service.Task) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "AdmitBuild",
+			"id", id,
+			"task", task,
+			"err", err,
+		)
+	}()
+
+	return r.next.AdmitBuild(ctx, id, task)
+}
+
+func (r repositoryLogger) EnqueueTask(ctx context.Context, id string, task *service.Task) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "EnqueueTask",
+			"id", id,
+			"task", task,
+			"err", err,
+		)
+	}()
+
+	return r.next.EnqueueTask(ctx, id, task)
+}
+
+func (r repositoryLogger) ClaimTask(ctx context.Context, lease time.Duration) (id string, task *service.Task, err error) {
+	defer func() {
+		if err == service.ErrNotFound {
+			return
+		}
+
+		r.logger.Log(
+			"method", "ClaimTask",
+			"lease", lease,
+			"id", id,
+			"task", task,
+			"err", err,
+		)
+	}()
+
+	return r.next.ClaimTask(ctx, lease)
+}
+
+func (r repositoryLogger) RetryTask(ctx context.Context, id string, taskId string, nextAttemptAt time.Time, lastError string) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "RetryTask",
+			"id", id,
+			"taskId", taskId,
+			"nextAttemptAt", nextAttemptAt,
+			"lastError", lastError,
+			"err", err,
+		)
+	}()
+
+	return r.next.RetryTask(ctx, id, taskId, nextAttemptAt, lastError)
+}
+
+func (r repositoryLogger) CompleteTask(ctx context.Context, id string, taskId string) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "CompleteTask",
+			"id", id,
+			"taskId", taskId,
+			"err", err,
+		)
+	}()
+
+	return r.next.CompleteTask(ctx, id, taskId)
+}
+
+func (r repositoryLogger) CreateAuditEvent(ctx context.Context, event *service.AuditEvent) (id string, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "CreateAuditEvent",
+			"auditMethod", event.Method,
+			"deployId", event.DeployId,
+			"actor", event.Actor,
+			"id", id,
+			"err", err,
+		)
+	}()
+
+	return r.next.CreateAuditEvent(ctx, event)
+}
+
+func (r repositoryLogger) ListAuditEvents(ctx context.Context, filter service.AuditFilter, projects []string) (events []*service.AuditEvent, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "ListAuditEvents",
+			"filter", filter,
+			"projects", projects,
+			"events", len(events),
+			"err", err,
+		)
+	}()
+
+	return r.next.ListAuditEvents(ctx, filter, projects)
+}
+
+func (r repositoryLogger) CreateDeadLetter(ctx context.Context, letter *service.DeadLetter) (id string, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "CreateDeadLetter",
+			"deployId", letter.DeployId,
+			"reason", letter.Reason,
+			"id", id,
+			"err", err,
+		)
+	}()
+
+	return r.next.CreateDeadLetter(ctx, letter)
+}
+
+func (r repositoryLogger) GetDeadLetter(ctx context.Context, id string) (letter *service.DeadLetter, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "GetDeadLetter",
+			"id", id,
+			"err", err,
+		)
+	}()
+
+	return r.next.GetDeadLetter(ctx, id)
+}
+
+func (r repositoryLogger) ListDeadLetters(ctx context.Context, deployId string) (letters []*service.DeadLetter, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "ListDeadLetters",
+			"deployId", deployId,
+			"err", err,
+		)
+	}()
+
+	return r.next.ListDeadLetters(ctx, deployId)
+}
+
+func (r repositoryLogger) DeleteDeadLetter(ctx context.Context, id string) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "DeleteDeadLetter",
+			"id", id,
+			"err", err,
+		)
+	}()
+
+	return r.next.DeleteDeadLetter(ctx, id)
+}
+
+func (r repositoryLogger) CreateDomain(ctx context.Context, domain *service.Domain) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "CreateDomain",
+			"name", domain.Name,
+			"deployId", domain.DeployId,
+			"err", err,
+		)
+	}()
+
+	return r.next.CreateDomain(ctx, domain)
+}
+
+func (r repositoryLogger) GetDomain(ctx context.Context, name string) (domain *service.Domain, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "GetDomain",
+			"name", name,
+			"err", err,
+		)
+	}()
+
+	return r.next.GetDomain(ctx, name)
+}
+
+func (r repositoryLogger) ListDomains(ctx context.Context, deployId string) (domains []*service.Domain, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "ListDomains",
+			"deployId", deployId,
+			"domains", len(domains),
+			"err", err,
+		)
+	}()
+
+	return r.next.ListDomains(ctx, deployId)
+}
+
+func (r repositoryLogger) VerifyDomain(ctx context.Context, name string, token string, verifiedAt time.Time) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "VerifyDomain",
+			"name", name,
+			"verifiedAt", verifiedAt,
+			"err", err,
+		)
+	}()
+
+	return r.next.VerifyDomain(ctx, name, token, verifiedAt)
+}
+
+func (r repositoryLogger) DeleteDomain(ctx context.Context, name string) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "DeleteDomain",
+			"name", name,
+			"err", err,
+		)
+	}()
+
+	return r.next.DeleteDomain(ctx, name)
+}
+
+func (r repositoryLogger) DeleteDomains(ctx context.Context, deployId string) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "DeleteDomains",
+			"deployId", deployId,
+			"err", err,
+		)
+	}()
+
+	return r.next.DeleteDomains(ctx, deployId)
+}
+
+func (r repositoryLogger) CreateIdempotencyKey(ctx context.Context, key *service.IdempotencyKey) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "CreateIdempotencyKey",
+			"key", key,
+			"err", err,
+		)
+	}()
+
+	return r.next.CreateIdempotencyKey(ctx, key)
+}
+
+func (r repositoryLogger) GetIdempotencyKey(ctx context.Context, key string, method string) (idempotencyKey *service.IdempotencyKey, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "GetIdempotencyKey",
+			"key", key,
+			"keyMethod", method,
+			"idempotencyKey", idempotencyKey,
+			"err", err,
+		)
+	}()
+
+	return r.next.GetIdempotencyKey(ctx, key, method)
+}
+
+func (r repositoryLogger) CompleteIdempotencyKey(ctx context.Context, key string, method string, result string) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "CompleteIdempotencyKey",
+			"key", key,
+			"keyMethod", method,
+			"result", result,
+			"err", err,
+		)
+	}()
+
+	return r.next.CompleteIdempotencyKey(ctx, key, method, result)
+}
+
+func (r repositoryLogger) DeleteIdempotencyKey(ctx context.Context, key string, method string) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "DeleteIdempotencyKey",
+			"key", key,
+			"keyMethod", method,
+			"err", err,
+		)
+	}()
+
+	return r.next.DeleteIdempotencyKey(ctx, key, method)
+}
+
+func (r repositoryLogger) CreateProject(ctx context.Context, project *service.Project) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "CreateProject",
+			"id", project.Id,
+			"err", err,
+		)
+	}()
+
+	return r.next.CreateProject(ctx, project)
+}
+
+func (r repositoryLogger) GetProject(ctx context.Context, id string) (project *service.Project, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "GetProject",
+			"id", id,
+			"err", err,
+		)
+	}()
+
+	return r.next.GetProject(ctx, id)
+}
+
+func (r repositoryLogger) ListProjects(ctx context.Context, member string) (projects []*service.Project, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "ListProjects",
+			"member", member,
+			"projects", len(projects),
+			"err", err,
+		)
+	}()
+
+	return r.next.ListProjects(ctx, member)
+}
+
+func (r repositoryLogger) DeleteProject(ctx context.Context, id string) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "DeleteProject",
+			"id", id,
+			"err", err,
+		)
+	}()
+
+	return r.next.DeleteProject(ctx, id)
+}
+
+func (r repositoryLogger) AddProjectMember(ctx context.Context, id string, subject string) (project *service.Project, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "AddProjectMember",
+			"id", id,
+			"subject", subject,
+			"err", err,
+		)
+	}()
+
+	return r.next.AddProjectMember(ctx, id, subject)
+}
+
+func (r repositoryLogger) RemoveProjectMember(ctx context.Context, id string, subject string) (project *service.Project, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "RemoveProjectMember",
+			"id", id,
+			"subject", subject,
+			"err", err,
+		)
+	}()
+
+	return r.next.RemoveProjectMember(ctx, id, subject)
+}
+
+func (r repositoryLogger) LockProject(ctx context.Context, id string, holder string, until time.Time) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "LockProject",
+			"id", id,
+			"until", until,
+			"err", err,
+		)
+	}()
+
+	return r.next.LockProject(ctx, id, holder, until)
+}
+
+func (r repositoryLogger) UnlockProject(ctx context.Context, id string, holder string) (err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "UnlockProject",
+			"id", id,
+			"err", err,
+		)
+	}()
+
+	return r.next.UnlockProject(ctx, id, holder)
+}
+
+func (r repositoryLogger) SetProjectQuota(ctx context.Context, id string, quota *service.Quota) (project *service.Project, err error) {
+	defer func() {
+		r.logger.Log(
+			"method", "SetProjectQuota",
+			"id", id,
+			"quota", quota,
+			"err", err,
+		)
+	}()
+
+	return r.next.SetProjectQuota(ctx, id, quota)
+}
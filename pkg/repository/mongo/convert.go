@@ -20,29 +20,53 @@ func businessToData(deploy *service.Deploy) *Deploy {
 
 	var steps []*BuildStep
 	for _, step := range deploy.Build.Steps {
-		steps = append(steps, &BuildStep{
-			Step:  int(step.Step),
-			Error: step.Error,
-		})
+		steps = append(steps, buildStepBusinessToData(step))
 	}
 	envs := mapEnvToArrEnv(deploy.Workload.Envs)
 
+	tasks := []*Task{}
+	for _, task := range deploy.Tasks {
+		tasks = append(tasks, taskBusinessToData(task))
+	}
+
 	return &Deploy{
-		Id:      id,
-		Name:    deploy.Name,
-		GitRepo: deploy.GitRepo,
+		Id:          id,
+		ProjectId:   deploy.ProjectId,
+		Name:        deploy.Name,
+		GitRepo:     deploy.GitRepo,
+		Labels:      deploy.Labels,
+		HealthCheck: healthCheckBusinessToData(deploy.HealthCheck),
 		Build: &Build{
-			JobId:   deploy.Build.JobId,
-			JobName: deploy.Build.JobName,
-			Status:  int(deploy.Build.Status),
-			Steps:   steps,
+			JobId:     deploy.Build.JobId,
+			JobName:   deploy.Build.JobName,
+			ImageName: deploy.Build.ImageName,
+			Status:    int(deploy.Build.Status),
+			Steps:     steps,
+			Priority:  deploy.Build.Priority,
+			QueuedAt:  deploy.Build.QueuedAt,
+			StartedAt: deploy.Build.StartedAt,
+
+			TraceContext: deploy.Build.TraceContext,
 		},
 		Workload: &Workload{
 			JobId:   deploy.Workload.JobId,
 			JobName: deploy.Workload.JobName,
 			Envs:    envs,
 			Url:     deploy.Workload.Url,
+			Status:  int(deploy.Workload.Status),
+			Health:  workloadHealthBusinessToData(deploy.Workload.Health),
+
+			Replicas: &deploy.Workload.Replicas,
+			Resources: &Resources{
+				CpuRequestMillis:   deploy.Workload.Resources.CpuRequestMillis,
+				CpuLimitMillis:     deploy.Workload.Resources.CpuLimitMillis,
+				MemoryRequestBytes: deploy.Workload.Resources.MemoryRequestBytes,
+				MemoryLimitBytes:   deploy.Workload.Resources.MemoryLimitBytes,
+			},
 		},
+		Deleted: deploy.Deleted,
+		Tasks:   tasks,
+		Version: deploy.Version,
 	}
 }
 
@@ -54,31 +78,75 @@ func dataToBusiness(deploy *Deploy) *service.Deploy {
 
 	var steps []*service.BuildStep
 	for _, step := range deploy.Build.Steps {
-		steps = append(steps, &service.BuildStep{
-			Step:  service.Step(step.Step),
-			Error: step.Error,
-		})
+		steps = append(steps, buildStepDataToBusiness(step))
 	}
 
 	envs := arrEnvToMapEnv(deploy.Workload.Envs)
 
+	var tasks []*service.Task
+	for _, task := range deploy.Tasks {
+		tasks = append(tasks, taskDataToBusiness(task))
+	}
+
 	return &service.Deploy{
-		Id:      id,
-		Name:    deploy.Name,
-		GitRepo: deploy.GitRepo,
+		Id:          id,
+		ProjectId:   deploy.ProjectId,
+		Name:        deploy.Name,
+		GitRepo:     deploy.GitRepo,
+		Labels:      deploy.Labels,
+		HealthCheck: healthCheckDataToBusiness(deploy.HealthCheck),
 		Build: &service.Build{
 			JobId:     deploy.Build.JobId,
 			JobName:   deploy.Build.JobName,
 			ImageName: deploy.Build.ImageName,
 			Status:    service.Status(deploy.Build.Status),
 			Steps:     steps,
+			Priority:  deploy.Build.Priority,
+			QueuedAt:  deploy.Build.QueuedAt,
+			StartedAt: deploy.Build.StartedAt,
+
+			TraceContext: deploy.Build.TraceContext,
 		},
 		Workload: &service.Workload{
 			JobId:   deploy.Workload.JobId,
 			JobName: deploy.Workload.JobName,
 			Envs:    envs,
 			Url:     deploy.Workload.Url,
+			Status:  service.WorkloadStatus(deploy.Workload.Status),
+			Health:  workloadHealthDataToBusiness(deploy.Workload.Health),
+
+			Replicas:  replicasDataToBusiness(deploy.Workload.Replicas),
+			Resources: resourcesDataToBusiness(deploy.Workload.Resources),
 		},
+		Deleted: deploy.Deleted,
+		Tasks:   tasks,
+		Version: deploy.Version,
+	}
+}
+
+func taskBusinessToData(task *service.Task) *Task {
+	return &Task{
+		Id:            task.Id,
+		Type:          int(task.Type),
+		Attempts:      task.Attempts,
+		NextAttemptAt: task.NextAttemptAt,
+		LastError:     task.LastError,
+		CreatedAt:     task.CreatedAt,
+
+		TraceContext: task.TraceContext,
+	}
+}
+
+func taskDataToBusiness(task *Task) *service.Task {
+	return &service.Task{
+		Id:            task.Id,
+		Type:          service.TaskType(task.Type),
+		Attempts:      task.Attempts,
+		NextAttemptAt: task.NextAttemptAt,
+		LastError:     task.LastError,
+		CreatedAt:     task.CreatedAt,
+
+		TraceContext: task.TraceContext,
 	}
 }
 
@@ -105,3 +173,252 @@ func arrEnvToMapEnv(envsToConvert []*Env) map[string]string {
 
 	return envs
 }
+
+func idempotencyKeyDataToBusiness(key *IdempotencyKey) *service.IdempotencyKey {
+	return &service.IdempotencyKey{
+		Key:         key.Key,
+		Method:      key.Method,
+		RequestHash: key.RequestHash,
+		Result:      key.Result,
+		Completed:   key.Completed,
+		ExpiresAt:   key.ExpiresAt,
+	}
+}
+
+func deadLetterBusinessToData(letter *service.DeadLetter) *DeadLetter {
+	id := primitive.NewObjectID()
+	if letter.Id != "" {
+		parsedId, err := primitive.ObjectIDFromHex(letter.Id)
+		if err != nil {
+			panic(err)
+		}
+
+		id = parsedId
+	}
+
+	return &DeadLetter{
+		Id:         id,
+		DeployId:   letter.DeployId,
+		WorkloadId: letter.WorkloadId,
+		Body:       letter.Body,
+		Reason:     letter.Reason,
+		Attempts:   letter.Attempts,
+		DeadAt:     letter.DeadAt,
+	}
+}
+
+func deadLetterDataToBusiness(letter *DeadLetter) *service.DeadLetter {
+	workloadId := letter.WorkloadId
+	if workloadId == "" {
+		workloadId = letter.DeployId
+	}
+
+	return &service.DeadLetter{
+		Id:         letter.Id.Hex(),
+		DeployId:   letter.DeployId,
+		WorkloadId: workloadId,
+		Body:       letter.Body,
+		Reason:     letter.Reason,
+		Attempts:   letter.Attempts,
+		DeadAt:     letter.DeadAt,
+	}
+}
+
+func buildStepBusinessToData(step *service.BuildStep) *BuildStep {
+	return &BuildStep{
+		Step:       int(step.Step),
+		Error:      step.Error,
+		EventId:    step.EventId,
+		Attempt:    step.Attempt,
+		OccurredAt: step.OccurredAt,
+		Details:    step.Details,
+	}
+}
+
+func buildStepDataToBusiness(step *BuildStep) *service.BuildStep {
+	return &service.BuildStep{
+		Step:       service.Step(step.Step),
+		Error:      step.Error,
+		EventId:    step.EventId,
+		Attempt:    step.Attempt,
+		OccurredAt: step.OccurredAt,
+		Details:    step.Details,
+	}
+}
+
+func workloadHealthBusinessToData(health service.WorkloadHealth) *WorkloadHealth {
+	if health.CheckedAt.IsZero() {
+		return nil
+	}
+
+	return &WorkloadHealth{
+		State:     int(health.State),
+		Restarts:  health.Restarts,
+		Missing:   health.Missing,
+		Message:   health.Message,
+		CheckedAt: health.CheckedAt,
+	}
+}
+
+func workloadHealthDataToBusiness(health *WorkloadHealth) service.WorkloadHealth {
+	if health == nil {
+		return service.WorkloadHealth{}
+	}
+
+	return service.WorkloadHealth{
+		State:     service.JobState(health.State),
+		Restarts:  health.Restarts,
+		Missing:   health.Missing,
+		Message:   health.Message,
+		CheckedAt: health.CheckedAt,
+	}
+}
+
+func healthCheckBusinessToData(healthCheck *service.HealthCheck) *HealthCheck {
+	if healthCheck == nil {
+		return nil
+	}
+
+	return &HealthCheck{
+		Path:           healthCheck.Path,
+		ExpectedStatus: healthCheck.ExpectedStatus,
+		Timeout:        healthCheck.Timeout,
+	}
+}
+
+func healthCheckDataToBusiness(healthCheck *HealthCheck) *service.HealthCheck {
+	if healthCheck == nil {
+		return nil
+	}
+
+	return &service.HealthCheck{
+		Path:           healthCheck.Path,
+		ExpectedStatus: healthCheck.ExpectedStatus,
+		Timeout:        healthCheck.Timeout,
+	}
+}
+
+// replicasDataToBusiness reads the workloads stored before the replicas were configurable, which always had one
+func replicasDataToBusiness(replicas *int) int {
+	if replicas == nil {
+		return service.DefaultReplicas
+	}
+
+	return *replicas
+}
+
+func resourcesDataToBusiness(resources *Resources) service.Resources {
+	if resources == nil {
+		return service.Resources{}
+	}
+
+	return service.Resources{
+		CpuRequestMillis:   resources.CpuRequestMillis,
+		CpuLimitMillis:     resources.CpuLimitMillis,
+		MemoryRequestBytes: resources.MemoryRequestBytes,
+		MemoryLimitBytes:   resources.MemoryLimitBytes,
+	}
+}
+
+func domainBusinessToData(domain *service.Domain) *Domain {
+	return &Domain{
+		Name:       domain.Name,
+		DeployId:   domain.DeployId,
+		Token:      domain.Token,
+		Verified:   domain.Verified,
+		CreatedAt:  domain.CreatedAt,
+		VerifiedAt: domain.VerifiedAt,
+	}
+}
+
+func domainDataToBusiness(domain *Domain) *service.Domain {
+	return &service.Domain{
+		Name:       domain.Name,
+		DeployId:   domain.DeployId,
+		Token:      domain.Token,
+		Verified:   domain.Verified,
+		CreatedAt:  domain.CreatedAt,
+		VerifiedAt: domain.VerifiedAt,
+	}
+}
+
+func projectBusinessToData(project *service.Project) *Project {
+	return &Project{
+		Id:          project.Id,
+		Description: project.Description,
+		Members:     project.Members,
+		Quota:       quotaBusinessToData(project.Quota),
+		CreatedAt:   project.CreatedAt,
+	}
+}
+
+func projectDataToBusiness(project *Project) *service.Project {
+	members := project.Members
+	if members == nil {
+		members = []string{}
+	}
+
+	return &service.Project{
+		Id:          project.Id,
+		Description: project.Description,
+		Members:     members,
+		Quota:       quotaDataToBusiness(project.Quota),
+		CreatedAt:   project.CreatedAt,
+	}
+}
+
+func quotaBusinessToData(quota *service.Quota) *Quota {
+	if quota == nil {
+		return nil
+	}
+
+	return &Quota{
+		MaxDeploys:          quota.MaxDeploys,
+		MaxConcurrentBuilds: quota.MaxConcurrentBuilds,
+		MaxReplicas:         quota.MaxReplicas,
+		MaxMemoryBytes:      quota.MaxMemoryBytes,
+	}
+}
+
+func quotaDataToBusiness(quota *Quota) *service.Quota {
+	if quota == nil {
+		return nil
+	}
+
+	return &service.Quota{
+		MaxDeploys:          quota.MaxDeploys,
+		MaxConcurrentBuilds: quota.MaxConcurrentBuilds,
+		MaxReplicas:         quota.MaxReplicas,
+		MaxMemoryBytes:      quota.MaxMemoryBytes,
+	}
+}
+
+func auditEventBusinessToData(event *service.AuditEvent) *AuditEvent {
+	return &AuditEvent{
+		Id:         primitive.NewObjectID(),
+		Actor:      event.Actor,
+		AuthMethod: event.AuthMethod,
+		Method:     event.Method,
+		DeployId:   event.DeployId,
+		ProjectId:  event.ProjectId,
+		Request:    event.Request,
+		Outcome:    event.Outcome,
+		Error:      event.Error,
+		OccurredAt: event.OccurredAt,
+	}
+}
+
+func auditEventDataToBusiness(event *AuditEvent) *service.AuditEvent {
+	return &service.AuditEvent{
+		Id:         event.Id.Hex(),
+		Actor:      event.Actor,
+		AuthMethod: event.AuthMethod,
+		Method:     event.Method,
+		DeployId:   event.DeployId,
+		ProjectId:  event.ProjectId,
+		Request:    event.Request,
+		Outcome:    event.Outcome,
+		Error:      event.Error,
+		OccurredAt: event.OccurredAt,
+	}
+}
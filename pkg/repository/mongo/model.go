@@ -1,6 +1,9 @@
 package mongo
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
 
 type Env struct {
 	Key   string `bson:"key"`
@@ -8,15 +11,45 @@ type Env struct {
 }
 
 type Workload struct {
-	JobId   string `bson:"job_id"`
-	JobName string `bson:"job_name"`
-	Envs    []*Env `bson:"envs"`
-	Url     string `bson:"url"`
+	JobId   string          `bson:"job_id"`
+	JobName string          `bson:"job_name"`
+	Envs    []*Env          `bson:"envs"`
+	Url     string          `bson:"url"`
+	Status  int             `bson:"status"`
+	Health  *WorkloadHealth `bson:"health,omitempty"`
+
+	Replicas  *int       `bson:"replicas,omitempty"`
+	Resources *Resources `bson:"resources,omitempty"`
+}
+
+type Resources struct {
+	CpuRequestMillis   int64 `bson:"cpu_request_millis"`
+	CpuLimitMillis     int64 `bson:"cpu_limit_millis"`
+	MemoryRequestBytes int64 `bson:"memory_request_bytes"`
+	MemoryLimitBytes   int64 `bson:"memory_limit_bytes"`
+}
+
+type WorkloadHealth struct {
+	State     int       `bson:"state"`
+	Restarts  int       `bson:"restarts"`
+	Missing   bool      `bson:"missing"`
+	Message   string    `bson:"message"`
+	CheckedAt time.Time `bson:"checked_at"`
+}
+
+type HealthCheck struct {
+	Path           string        `bson:"path"`
+	ExpectedStatus int           `bson:"expected_status"`
+	Timeout        time.Duration `bson:"timeout"`
 }
 
 type BuildStep struct {
-	Step  int    `bson:"step"`
-	Error string `bson:"error"`
+	Step       int               `bson:"step"`
+	Error      string            `bson:"error"`
+	EventId    string            `bson:"event_id,omitempty"`
+	Attempt    int               `bson:"attempt,omitempty"`
+	OccurredAt time.Time         `bson:"occurred_at,omitempty"`
+	Details    map[string]string `bson:"details,omitempty"`
 }
 
 type Build struct {
@@ -25,12 +58,93 @@ type Build struct {
 	ImageName string       `bson:"image_name"`
 	Status    int          `bson:"status"`
 	Steps     []*BuildStep `bson:"steps"`
+	Priority  int          `bson:"priority,omitempty"`
+	QueuedAt  time.Time    `bson:"queued_at,omitempty"`
+	StartedAt time.Time    `bson:"started_at,omitempty"`
+
+	TraceContext map[string]string `bson:"trace_context,omitempty"`
+}
+
+type Task struct {
+	Id            string    `bson:"id"`
+	Type          int       `bson:"type"`
+	Attempts      int       `bson:"attempts"`
+	NextAttemptAt time.Time `bson:"next_attempt_at"`
+	LastError     string    `bson:"last_error"`
+	CreatedAt     time.Time `bson:"created_at"`
+
+	TraceContext map[string]string `bson:"trace_context,omitempty"`
 }
 
 type Deploy struct {
+	Id          primitive.ObjectID `bson:"_id"`
+	ProjectId   string             `bson:"project_id,omitempty"`
+	Name        string             `bson:"name"`
+	GitRepo     string             `bson:"git_repo"`
+	Labels      map[string]string  `bson:"labels,omitempty"`
+	HealthCheck *HealthCheck       `bson:"health_check,omitempty"`
+	Build       *Build             `bson:"build"`
+	Workload    *Workload          `bson:"workload"`
+	Deleted     bool               `bson:"deleted"`
+	Tasks       []*Task            `bson:"tasks"`
+	Version     int64              `bson:"version"`
+}
+
+type IdempotencyKey struct {
+	Id          primitive.ObjectID `bson:"_id"`
+	Key         string             `bson:"key"`
+	Method      string             `bson:"method"`
+	RequestHash string             `bson:"request_hash"`
+	Result      string             `bson:"result"`
+	Completed   bool               `bson:"completed"`
+	ExpiresAt   time.Time          `bson:"expires_at"`
+}
+
+type DeadLetter struct {
 	Id       primitive.ObjectID `bson:"_id"`
-	Name     string             `bson:"name"`
-	GitRepo  string             `bson:"git_repo"`
-	Build    *Build             `bson:"build"`
-	Workload *Workload          `bson:"workload"`
+	DeployId string             `bson:"deploy_id"`
+	// WorkloadId is missing for the dead letters stored before projects, routed by their deploy id
+	WorkloadId string    `bson:"workload_id,omitempty"`
+	Body       []byte    `bson:"body"`
+	Reason     string    `bson:"reason"`
+	Attempts   int       `bson:"attempts"`
+	DeadAt     time.Time `bson:"dead_at"`
+}
+
+type Domain struct {
+	Name       string    `bson:"_id"`
+	DeployId   string    `bson:"deploy_id"`
+	Token      string    `bson:"token"`
+	Verified   bool      `bson:"verified"`
+	CreatedAt  time.Time `bson:"created_at"`
+	VerifiedAt time.Time `bson:"verified_at,omitempty"`
+}
+
+type Project struct {
+	Id          string    `bson:"_id"`
+	Description string    `bson:"description"`
+	Members     []string  `bson:"members"`
+	Quota       *Quota    `bson:"quota,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+}
+
+type Quota struct {
+	MaxDeploys          int   `bson:"max_deploys"`
+	MaxConcurrentBuilds int   `bson:"max_concurrent_builds"`
+	MaxReplicas         int   `bson:"max_replicas"`
+	MaxMemoryBytes      int64 `bson:"max_memory_bytes"`
+}
+
+// AuditEvent documents are only inserted, never updated nor deleted
+type AuditEvent struct {
+	Id         primitive.ObjectID `bson:"_id"`
+	Actor      string             `bson:"actor"`
+	AuthMethod string             `bson:"auth_method,omitempty"`
+	Method     string             `bson:"method"`
+	DeployId   string             `bson:"deploy_id,omitempty"`
+	ProjectId  string             `bson:"project_id,omitempty"`
+	Request    map[string]string  `bson:"request,omitempty"`
+	Outcome    string             `bson:"outcome"`
+	Error      string             `bson:"error,omitempty"`
+	OccurredAt time.Time          `bson:"occurred_at"`
 }
@@ -9,33 +9,152 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	DeployCollection         = "deploy"
+	DeadLetterCollection     = "dead_letter"
+	IdempotencyKeyCollection = "idempotency_key"
+	DomainCollection         = "domain"
+	ProjectCollection        = "project"
+	AuditEventCollection     = "audit_event"
 )
 
 type mongoRepository struct {
-	collection *mongo.Collection
+	collection               *mongo.Collection
+	deadLetterCollection     *mongo.Collection
+	idempotencyKeyCollection *mongo.Collection
+	domainCollection         *mongo.Collection
+	projectCollection        *mongo.Collection
+	auditEventCollection     *mongo.Collection
 }
 
-func New(collection *mongo.Collection, logger log.Logger) service.Repository {
+func New(database *mongo.Database, logger log.Logger) service.Repository {
 	var instance service.Repository
 	instance = &mongoRepository{
-		collection: collection,
+		collection:               database.Collection(DeployCollection),
+		deadLetterCollection:     database.Collection(DeadLetterCollection),
+		idempotencyKeyCollection: database.Collection(IdempotencyKeyCollection),
+		domainCollection:         database.Collection(DomainCollection),
+		projectCollection:        database.Collection(ProjectCollection),
+		auditEventCollection:     database.Collection(AuditEventCollection),
 	}
 	instance = middlewares.LoggingMiddleware(logger)(instance)
 
 	return instance
 }
 
+func (m *mongoRepository) Init(ctx context.Context) error {
+	if _, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.M{"tasks.0.next_attempt_at": 1},
+	}); err != nil {
+		return errors.Wrap(err, "Failed to create task index")
+	}
+
+	// Names are unique in a project among the deploys not destroyed yet. Deploys created before
+	// projects have no project_id and are left out, since their names may already be duplicated
+	if _, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"project_id": bson.M{"$exists": true},
+			"deleted":    false,
+		}),
+	}); err != nil {
+		return errors.Wrap(err, "Failed to create deploy name index")
+	}
+
+	if _, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "build.status", Value: 1}, {Key: "build.priority", Value: -1}, {Key: "build.queued_at", Value: 1}},
+	}); err != nil {
+		return errors.Wrap(err, "Failed to create build queue index")
+	}
+
+	if _, err := m.projectCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.M{"members": 1},
+	}); err != nil {
+		return errors.Wrap(err, "Failed to create project index")
+	}
+
+	if _, err := m.projectCollection.UpdateOne(ctx,
+		bson.M{"_id": service.DefaultProject},
+		bson.M{"$setOnInsert": projectBusinessToData(&service.Project{
+			Id:          service.DefaultProject,
+			Description: "Deploys created without a project",
+			Members:     []string{},
+			CreatedAt:   time.Now(),
+		})},
+		options.Update().SetUpsert(true),
+	); err != nil {
+		return errors.Wrap(err, "Failed to create default project")
+	}
+
+	if _, err := m.deadLetterCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.M{"deploy_id": 1},
+	}); err != nil {
+		return errors.Wrap(err, "Failed to create dead letter index")
+	}
+
+	if _, err := m.idempotencyKeyCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}, {Key: "method", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.M{"expires_at": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}); err != nil {
+		return errors.Wrap(err, "Failed to create idempotency key indexes")
+	}
+
+	// Domains are unique as they are stored by name, the index lists the domains of a deploy
+	if _, err := m.domainCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.M{"deploy_id": 1},
+	}); err != nil {
+		return errors.Wrap(err, "Failed to create domain index")
+	}
+
+	if _, err := m.auditEventCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "deploy_id", Value: 1}, {Key: "occurred_at", Value: -1}}},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "occurred_at", Value: -1}}},
+		{Keys: bson.M{"occurred_at": -1}},
+	}); err != nil {
+		return errors.Wrap(err, "Failed to create audit event indexes")
+	}
+
+	return nil
+}
+
 func (m *mongoRepository) CreateDeploy(ctx context.Context, deploy *service.Deploy) (string, error) {
-	res, err := m.collection.InsertOne(ctx, businessToData(deploy))
+	dataDeploy := businessToData(deploy)
+	dataDeploy.Version = 1
+
+	res, err := m.collection.InsertOne(ctx, dataDeploy)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return "", service.ErrAlreadyExists
+		}
+
 		return "", err
 	}
 
 	return res.InsertedID.(primitive.ObjectID).Hex(), nil
 }
 
-func (m *mongoRepository) GetDeploy(ctx context.Context, id string) (*service.Deploy, error) {
+// parseObjectId maps the ids which are not object ids to service.ErrNotFound, since no document can have them
+func parseObjectId(id string) (primitive.ObjectID, error) {
 	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, errors.Wrapf(service.ErrNotFound, "invalid id %q", id)
+	}
+
+	return objectId, nil
+}
+
+func (m *mongoRepository) GetDeploy(ctx context.Context, id string) (*service.Deploy, error) {
+	objectId, err := parseObjectId(id)
 	if err != nil {
 		return nil, err
 	}
@@ -47,6 +166,10 @@ func (m *mongoRepository) GetDeploy(ctx context.Context, id string) (*service.De
 		},
 	)
 	if err := res.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, service.ErrNotFound
+		}
+
 		return nil, err
 	}
 
@@ -58,11 +181,17 @@ func (m *mongoRepository) GetDeploy(ctx context.Context, id string) (*service.De
 	return dataToBusiness(deploy), nil
 }
 
-func (m *mongoRepository) GetDeployByName(ctx context.Context, name string) (*service.Deploy, error) {
-	res := m.collection.FindOne(ctx, bson.M{
-		"name": name,
-	})
+func (m *mongoRepository) GetDeployByName(ctx context.Context, projectId string, name string) (*service.Deploy, error) {
+	filter := projectFilter([]string{projectId})
+	filter["name"] = name
+	filter["deleted"] = bson.M{"$ne": true}
+
+	res := m.collection.FindOne(ctx, filter)
 	if err := res.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, service.ErrNotFound
+		}
+
 		return nil, err
 	}
 
@@ -74,10 +203,30 @@ func (m *mongoRepository) GetDeployByName(ctx context.Context, name string) (*se
 	return dataToBusiness(deploy), nil
 }
 
-func (m *mongoRepository) ListDeploy(ctx context.Context) ([]*service.Deploy, error) {
+// projectFilter matches the deploys of the given projects, including the ones created
+// before projects when DefaultProject is among them
+func projectFilter(projects []string) bson.M {
+	if projects == nil {
+		return bson.M{}
+	}
+
+	filter := bson.A{bson.M{"project_id": bson.M{"$in": projects}}}
+	for _, project := range projects {
+		if project == service.DefaultProject {
+			filter = append(filter, bson.M{"project_id": bson.M{"$exists": false}})
+		}
+	}
+
+	return bson.M{"$or": filter}
+}
+
+func (m *mongoRepository) ListDeploy(ctx context.Context, projects []string) ([]*service.Deploy, error) {
 	var deploys []*service.Deploy
 
-	cur, err := m.collection.Find(ctx, bson.M{})
+	filter := projectFilter(projects)
+	filter["deleted"] = bson.M{"$ne": true}
+
+	cur, err := m.collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -95,36 +244,93 @@ func (m *mongoRepository) ListDeploy(ctx context.Context) ([]*service.Deploy, er
 	return deploys, nil
 }
 
-func (m *mongoRepository) UpdateDeploy(ctx context.Context, deploy *service.Deploy) error {
-	objectId, err := primitive.ObjectIDFromHex(deploy.Id)
+// UpdateDeploy sets only the fields changed by the callers, only if the deploy has not been modified since
+// it has been read, otherwise it fails with service.ErrConflict. The builds, the workload state and the tasks
+// are changed by the worker without a new version, so they must not be overwritten with the ones read.
+func (m *mongoRepository) UpdateDeploy(ctx context.Context, deploy *service.Deploy, tasks []*service.Task) error {
+	objectId, err := parseObjectId(deploy.Id)
 	if err != nil {
 		return err
 	}
 
 	dataDeploy := businessToData(deploy)
+	dataDeploy.Version = deploy.Version + 1
+
+	dataTasks := make([]*Task, 0, len(tasks))
+	for _, task := range tasks {
+		dataTasks = append(dataTasks, taskBusinessToData(task))
+	}
 
 	res, err := m.collection.UpdateOne(
 		ctx,
 		bson.M{
-			"_id": objectId,
+			"_id":     objectId,
+			"version": versionFilter(deploy.Version),
 		},
 		bson.M{
-			"$set": dataDeploy,
+			"$set": bson.M{
+				"name":               dataDeploy.Name,
+				"labels":             dataDeploy.Labels,
+				"health_check":       dataDeploy.HealthCheck,
+				"deleted":            dataDeploy.Deleted,
+				"workload.envs":      dataDeploy.Workload.Envs,
+				"workload.replicas":  dataDeploy.Workload.Replicas,
+				"workload.resources": dataDeploy.Workload.Resources,
+				"version":            dataDeploy.Version,
+			},
+			"$push": bson.M{
+				"tasks": bson.M{
+					"$each": dataTasks,
+				},
+			},
 		},
 	)
 	if err != nil {
 		return err
 	}
 
-	if res.ModifiedCount == 0 {
-		return errors.New("document not found")
+	if res.MatchedCount == 0 {
+		return m.conflictOrNotFound(ctx, objectId)
 	}
 
+	deploy.Version = dataDeploy.Version
+
 	return nil
 }
 
+// versionFilter matches also documents stored before the introduction of versioning
+func versionFilter(version int64) interface{} {
+	if version == 0 {
+		return bson.M{
+			"$in": bson.A{int64(0), nil},
+		}
+	}
+
+	return version
+}
+
+func (m *mongoRepository) conflictOrNotFound(ctx context.Context, objectId primitive.ObjectID) error {
+	return m.existingOrNotFound(ctx, objectId, service.ErrConflict)
+}
+
+// existingOrNotFound tells apart a conditional update not applied because of its condition from one on a missing deploy
+func (m *mongoRepository) existingOrNotFound(ctx context.Context, objectId primitive.ObjectID, existingErr error) error {
+	count, err := m.collection.CountDocuments(ctx, bson.M{
+		"_id": objectId,
+	})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return service.ErrNotFound
+	}
+
+	return existingErr
+}
+
 func (m *mongoRepository) DeleteDeploy(ctx context.Context, id string) error {
-	objectId, err := primitive.ObjectIDFromHex(id)
+	objectId, err := parseObjectId(id)
 	if err != nil {
 		return err
 	}
@@ -137,14 +343,14 @@ func (m *mongoRepository) DeleteDeploy(ctx context.Context, id string) error {
 	}
 
 	if res.DeletedCount == 0 {
-		return errors.New("0 Doc has been deleted")
+		return service.ErrNotFound
 	}
 
 	return nil
 }
 
 func (m *mongoRepository) InitBuild(ctx context.Context, id string, jobName string, jobId string, imageName string) error {
-	objectId, err := primitive.ObjectIDFromHex(id)
+	objectId, err := parseObjectId(id)
 	if err != nil {
 		return err
 	}
@@ -161,6 +367,9 @@ func (m *mongoRepository) InitBuild(ctx context.Context, id string, jobName stri
 				"build.image_name": imageName,
 				"build.steps":      []bson.M{},
 			},
+			"$inc": bson.M{
+				"version": 1,
+			},
 		},
 	)
 	if err != nil {
@@ -168,14 +377,14 @@ func (m *mongoRepository) InitBuild(ctx context.Context, id string, jobName stri
 	}
 
 	if res.MatchedCount == 0 {
-		return errors.New("document not found")
+		return service.ErrNotFound
 	}
 
 	return nil
 }
 
 func (m *mongoRepository) InitWorkload(ctx context.Context, id string, jobName string, jobId string, envs map[string]string, url string) error {
-	objectId, err := primitive.ObjectIDFromHex(id)
+	objectId, err := parseObjectId(id)
 	if err != nil {
 		return err
 	}
@@ -194,6 +403,70 @@ func (m *mongoRepository) InitWorkload(ctx context.Context, id string, jobName s
 				"workload.envs":     dataEnv,
 				"workload.url":      url,
 			},
+			"$inc": bson.M{
+				"version": 1,
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+// SetWorkloadHealth doesn't increment the version of the deploy, since the health is observed
+// rather than requested and would make every client update conflict with the drift detector
+func (m *mongoRepository) SetWorkloadHealth(ctx context.Context, id string, health service.WorkloadHealth) error {
+	objectId, err := parseObjectId(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id": objectId,
+		},
+		bson.M{
+			"$set": bson.M{
+				"workload.health": workloadHealthBusinessToData(health),
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) SetWorkloadStatus(ctx context.Context, id string, status service.WorkloadStatus) error {
+	objectId, err := parseObjectId(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id": objectId,
+		},
+		bson.M{
+			"$set": bson.M{
+				"workload.status": int(status),
+			},
+			"$inc": bson.M{
+				"version": 1,
+			},
 		},
 	)
 	if err != nil {
@@ -201,14 +474,14 @@ func (m *mongoRepository) InitWorkload(ctx context.Context, id string, jobName s
 	}
 
 	if res.MatchedCount == 0 {
-		return errors.New("document not found")
+		return service.ErrNotFound
 	}
 
 	return nil
 }
 
 func (m *mongoRepository) SetBuildStatus(ctx context.Context, id string, status service.Status) error {
-	objectId, err := primitive.ObjectIDFromHex(id)
+	objectId, err := parseObjectId(id)
 	if err != nil {
 		return err
 	}
@@ -222,6 +495,9 @@ func (m *mongoRepository) SetBuildStatus(ctx context.Context, id string, status
 			"$set": bson.M{
 				"build.status": int(status),
 			},
+			"$inc": bson.M{
+				"version": 1,
+			},
 		},
 	)
 	if err != nil {
@@ -229,14 +505,191 @@ func (m *mongoRepository) SetBuildStatus(ctx context.Context, id string, status
 	}
 
 	if res.MatchedCount == 0 {
-		return errors.New("document not found")
+		return service.ErrNotFound
 	}
 
 	return nil
 }
 
 func (m *mongoRepository) RecordBuildStep(ctx context.Context, id string, buildStep service.BuildStep) error {
-	objectId, err := primitive.ObjectIDFromHex(id)
+	objectId, err := parseObjectId(id)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id": objectId,
+	}
+	// Events are recorded only once, the legacy ones carry an id derived from their content
+	if buildStep.EventId != "" {
+		filter["build.steps.event_id"] = bson.M{
+			"$ne": buildStep.EventId,
+		}
+	}
+
+	res, err := m.collection.UpdateOne(
+		ctx,
+		filter,
+		bson.M{
+			"$push": bson.M{
+				"build.steps": buildStepBusinessToData(&buildStep),
+			},
+			"$inc": bson.M{
+				"version": 1,
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		if buildStep.EventId != "" {
+			return m.existingOrNotFound(ctx, objectId, service.ErrDuplicateEvent)
+		}
+
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) ListQueuedBuilds(ctx context.Context) ([]*service.Deploy, error) {
+	var deploys []*service.Deploy
+
+	cur, err := m.collection.Find(
+		ctx,
+		bson.M{
+			"build.status": int(service.StatusQueued),
+			"deleted":      bson.M{"$ne": true},
+		},
+		options.Find().SetSort(bson.D{
+			{Key: "build.priority", Value: -1},
+			{Key: "build.queued_at", Value: 1},
+			{Key: "_id", Value: 1},
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for cur.Next(ctx) {
+		dataDeploy := &Deploy{}
+
+		if err := cur.Decode(dataDeploy); err != nil {
+			return nil, err
+		}
+
+		deploys = append(deploys, dataToBusiness(dataDeploy))
+	}
+
+	return deploys, nil
+}
+
+// AdmitBuild moves a queued build to loading along with the task scheduling it,
+// so that the build is started exactly once even when replicas admit it at the same time
+func (m *mongoRepository) AdmitBuild(ctx context.Context, id string, task *service.Task) error {
+	objectId, err := parseObjectId(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":          objectId,
+			"build.status": int(service.StatusQueued),
+			"deleted":      bson.M{"$ne": true},
+		},
+		bson.M{
+			"$set": bson.M{
+				"build.status":     int(service.StatusLoading),
+				"build.started_at": time.Now(),
+			},
+			"$push": bson.M{
+				"tasks": taskBusinessToData(task),
+			},
+			"$inc": bson.M{
+				"version": 1,
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) CountBuildsInProgress(ctx context.Context) (map[string]int, error) {
+	cur, err := m.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"build.status": int(service.StatusLoading),
+			"deleted":      bson.M{"$ne": true},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$project_id",
+			"count": bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for cur.Next(ctx) {
+		group := &struct {
+			ProjectId string `bson:"_id"`
+			Count     int    `bson:"count"`
+		}{}
+
+		if err := cur.Decode(group); err != nil {
+			return nil, err
+		}
+
+		// deploys created before projects belong to the default one
+		projectId := group.ProjectId
+		if projectId == "" {
+			projectId = service.DefaultProject
+		}
+		counts[projectId] += group.Count
+	}
+
+	return counts, nil
+}
+
+func (m *mongoRepository) TimeoutBuilds(ctx context.Context, startedBefore time.Time) (int64, error) {
+	res, err := m.collection.UpdateMany(
+		ctx,
+		bson.M{
+			"build.status": int(service.StatusLoading),
+			"build.started_at": bson.M{
+				"$lte": startedBefore,
+			},
+			"deleted": bson.M{"$ne": true},
+		},
+		bson.M{
+			"$set": bson.M{
+				"build.status": int(service.StatusError),
+			},
+			"$inc": bson.M{
+				"version": 1,
+			},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.ModifiedCount, nil
+}
+
+func (m *mongoRepository) EnqueueTask(ctx context.Context, id string, task *service.Task) error {
+	objectId, err := parseObjectId(id)
 	if err != nil {
 		return err
 	}
@@ -248,10 +701,7 @@ func (m *mongoRepository) RecordBuildStep(ctx context.Context, id string, buildS
 		},
 		bson.M{
 			"$push": bson.M{
-				"build.steps": bson.M{
-					"step":  int(buildStep.Step),
-					"error": buildStep.Error,
-				},
+				"tasks": taskBusinessToData(task),
 			},
 		},
 	)
@@ -260,8 +710,637 @@ func (m *mongoRepository) RecordBuildStep(ctx context.Context, id string, buildS
 	}
 
 	if res.MatchedCount == 0 {
-		return errors.New("document not found")
+		return service.ErrNotFound
 	}
 
 	return nil
 }
+
+// ClaimTask leases the first task of a deploy, so that tasks of the same deploy are executed in order
+func (m *mongoRepository) ClaimTask(ctx context.Context, lease time.Duration) (string, *service.Task, error) {
+	now := time.Now()
+
+	res := m.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"tasks.0.next_attempt_at": bson.M{
+				"$lte": now,
+			},
+		},
+		bson.M{
+			"$set": bson.M{
+				"tasks.0.next_attempt_at": now.Add(lease),
+			},
+			"$inc": bson.M{
+				"tasks.0.attempts": 1,
+			},
+		},
+		options.FindOneAndUpdate().
+			SetSort(bson.M{"tasks.0.next_attempt_at": 1}).
+			SetReturnDocument(options.After),
+	)
+	if err := res.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", nil, service.ErrNotFound
+		}
+
+		return "", nil, err
+	}
+
+	deploy := &Deploy{}
+	if err := res.Decode(deploy); err != nil {
+		return "", nil, err
+	}
+
+	return deploy.Id.Hex(), taskDataToBusiness(deploy.Tasks[0]), nil
+}
+
+func (m *mongoRepository) RetryTask(ctx context.Context, id string, taskId string, nextAttemptAt time.Time, lastError string) error {
+	objectId, err := parseObjectId(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":      objectId,
+			"tasks.id": taskId,
+		},
+		bson.M{
+			"$set": bson.M{
+				"tasks.$.next_attempt_at": nextAttemptAt,
+				"tasks.$.last_error":      lastError,
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) CompleteTask(ctx context.Context, id string, taskId string) error {
+	objectId, err := parseObjectId(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id": objectId,
+		},
+		bson.M{
+			"$pull": bson.M{
+				"tasks": bson.M{
+					"id": taskId,
+				},
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) CreateAuditEvent(ctx context.Context, event *service.AuditEvent) (string, error) {
+	res, err := m.auditEventCollection.InsertOne(ctx, auditEventBusinessToData(event))
+	if err != nil {
+		return "", err
+	}
+
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (m *mongoRepository) ListAuditEvents(ctx context.Context, filter service.AuditFilter, projects []string) ([]*service.AuditEvent, error) {
+	var events []*service.AuditEvent
+
+	query := bson.M{}
+	if projects != nil {
+		query["project_id"] = bson.M{"$in": projects}
+	}
+	if filter.DeployId != "" {
+		query["deploy_id"] = filter.DeployId
+	}
+	if filter.Actor != "" {
+		query["actor"] = filter.Actor
+	}
+	occurredAt := bson.M{}
+	if !filter.From.IsZero() {
+		occurredAt["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		occurredAt["$lt"] = filter.To
+	}
+	if len(occurredAt) > 0 {
+		query["occurred_at"] = occurredAt
+	}
+
+	cur, err := m.auditEventCollection.Find(
+		ctx,
+		query,
+		options.Find().SetSort(bson.D{{Key: "occurred_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(int64(filter.Limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for cur.Next(ctx) {
+		event := &AuditEvent{}
+
+		if err := cur.Decode(event); err != nil {
+			return nil, err
+		}
+
+		events = append(events, auditEventDataToBusiness(event))
+	}
+
+	return events, nil
+}
+
+func (m *mongoRepository) CreateDeadLetter(ctx context.Context, letter *service.DeadLetter) (string, error) {
+	res, err := m.deadLetterCollection.InsertOne(ctx, deadLetterBusinessToData(letter))
+	if err != nil {
+		return "", err
+	}
+
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (m *mongoRepository) GetDeadLetter(ctx context.Context, id string) (*service.DeadLetter, error) {
+	objectId, err := parseObjectId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := m.deadLetterCollection.FindOne(ctx, bson.M{
+		"_id": objectId,
+	})
+	if err := res.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, service.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	letter := &DeadLetter{}
+	if err := res.Decode(letter); err != nil {
+		return nil, err
+	}
+
+	return deadLetterDataToBusiness(letter), nil
+}
+
+func (m *mongoRepository) ListDeadLetters(ctx context.Context, deployId string) ([]*service.DeadLetter, error) {
+	var letters []*service.DeadLetter
+
+	filter := bson.M{}
+	if deployId != "" {
+		filter["deploy_id"] = deployId
+	}
+
+	cur, err := m.deadLetterCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"dead_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	for cur.Next(ctx) {
+		letter := &DeadLetter{}
+
+		if err := cur.Decode(letter); err != nil {
+			return nil, err
+		}
+
+		letters = append(letters, deadLetterDataToBusiness(letter))
+	}
+
+	return letters, nil
+}
+
+func (m *mongoRepository) DeleteDeadLetter(ctx context.Context, id string) error {
+	objectId, err := parseObjectId(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.deadLetterCollection.DeleteOne(ctx, bson.M{
+		"_id": objectId,
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) CreateDomain(ctx context.Context, domain *service.Domain) error {
+	data := domainBusinessToData(domain)
+
+	// An expired claim is replaced in place, while a live claim or a verified domain
+	// makes the upsert collide with the _id instead
+	if _, err := m.domainCollection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":      data.Name,
+			"verified": false,
+			"created_at": bson.M{
+				"$lte": time.Now().Add(-service.DomainClaimTTL),
+			},
+		},
+		bson.M{
+			"$set": bson.M{
+				"deploy_id":  data.DeployId,
+				"token":      data.Token,
+				"verified":   data.Verified,
+				"created_at": data.CreatedAt,
+			},
+			"$unset": bson.M{
+				"verified_at": "",
+			},
+		},
+		options.Update().SetUpsert(true),
+	); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return service.ErrAlreadyExists
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) GetDomain(ctx context.Context, name string) (*service.Domain, error) {
+	res := m.domainCollection.FindOne(ctx, bson.M{
+		"_id": name,
+	})
+	if err := res.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, service.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	domain := &Domain{}
+	if err := res.Decode(domain); err != nil {
+		return nil, err
+	}
+
+	return domainDataToBusiness(domain), nil
+}
+
+func (m *mongoRepository) ListDomains(ctx context.Context, deployId string) ([]*service.Domain, error) {
+	var domains []*service.Domain
+
+	filter := bson.M{}
+	if deployId != "" {
+		filter["deploy_id"] = deployId
+	}
+
+	cur, err := m.domainCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	for cur.Next(ctx) {
+		domain := &Domain{}
+
+		if err := cur.Decode(domain); err != nil {
+			return nil, err
+		}
+
+		domains = append(domains, domainDataToBusiness(domain))
+	}
+
+	return domains, nil
+}
+
+func (m *mongoRepository) VerifyDomain(ctx context.Context, name string, token string, verifiedAt time.Time) error {
+	res, err := m.domainCollection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":   name,
+			"token": token,
+		},
+		bson.M{
+			"$set": bson.M{
+				"verified":    true,
+				"verified_at": verifiedAt,
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) DeleteDomain(ctx context.Context, name string) error {
+	res, err := m.domainCollection.DeleteOne(ctx, bson.M{
+		"_id": name,
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) DeleteDomains(ctx context.Context, deployId string) error {
+	_, err := m.domainCollection.DeleteMany(ctx, bson.M{
+		"deploy_id": deployId,
+	})
+
+	return err
+}
+
+func (m *mongoRepository) CreateIdempotencyKey(ctx context.Context, key *service.IdempotencyKey) error {
+	// Expired keys may still be stored until the TTL monitor removes them, so they are replaced in place,
+	// which takes over the reservations whose lease is over as well.
+	// A live key makes the upsert collide with the unique index instead.
+	_, err := m.idempotencyKeyCollection.UpdateOne(
+		ctx,
+		bson.M{
+			"key":    key.Key,
+			"method": key.Method,
+			"expires_at": bson.M{
+				"$lte": time.Now(),
+			},
+		},
+		bson.M{
+			"$set": bson.M{
+				"request_hash": key.RequestHash,
+				"result":       key.Result,
+				"completed":    key.Completed,
+				"expires_at":   key.ExpiresAt,
+			},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return service.ErrAlreadyExists
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) GetIdempotencyKey(ctx context.Context, key string, method string) (*service.IdempotencyKey, error) {
+	res := m.idempotencyKeyCollection.FindOne(ctx, bson.M{
+		"key":    key,
+		"method": method,
+		"expires_at": bson.M{
+			"$gt": time.Now(),
+		},
+	})
+	if err := res.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, service.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	idempotencyKey := &IdempotencyKey{}
+	if err := res.Decode(idempotencyKey); err != nil {
+		return nil, err
+	}
+
+	return idempotencyKeyDataToBusiness(idempotencyKey), nil
+}
+
+// CompleteIdempotencyKey stores the result of the request, which is kept for service.IdempotencyKeyTTL
+// rather than for the lease of the reservation
+func (m *mongoRepository) CompleteIdempotencyKey(ctx context.Context, key string, method string, result string) error {
+	res, err := m.idempotencyKeyCollection.UpdateOne(
+		ctx,
+		bson.M{
+			"key":    key,
+			"method": method,
+		},
+		bson.M{
+			"$set": bson.M{
+				"result":     result,
+				"completed":  true,
+				"expires_at": time.Now().Add(service.IdempotencyKeyTTL),
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) DeleteIdempotencyKey(ctx context.Context, key string, method string) error {
+	_, err := m.idempotencyKeyCollection.DeleteOne(ctx, bson.M{
+		"key":    key,
+		"method": method,
+	})
+
+	return err
+}
+
+func (m *mongoRepository) CreateProject(ctx context.Context, project *service.Project) error {
+	if _, err := m.projectCollection.InsertOne(ctx, projectBusinessToData(project)); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return service.ErrAlreadyExists
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) GetProject(ctx context.Context, id string) (*service.Project, error) {
+	res := m.projectCollection.FindOne(ctx, bson.M{
+		"_id": id,
+	})
+	if err := res.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, service.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	project := &Project{}
+	if err := res.Decode(project); err != nil {
+		return nil, err
+	}
+
+	return projectDataToBusiness(project), nil
+}
+
+func (m *mongoRepository) ListProjects(ctx context.Context, member string) ([]*service.Project, error) {
+	projects := []*service.Project{}
+
+	filter := bson.M{}
+	if member != "" {
+		filter["$or"] = bson.A{bson.M{"members": member}, bson.M{"_id": service.DefaultProject}}
+	}
+
+	cur, err := m.projectCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	for cur.Next(ctx) {
+		project := &Project{}
+
+		if err := cur.Decode(project); err != nil {
+			return nil, err
+		}
+
+		projects = append(projects, projectDataToBusiness(project))
+	}
+
+	return projects, nil
+}
+
+func (m *mongoRepository) DeleteProject(ctx context.Context, id string) error {
+	res, err := m.projectCollection.DeleteOne(ctx, bson.M{
+		"_id": id,
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) AddProjectMember(ctx context.Context, id string, subject string) (*service.Project, error) {
+	return m.updateProject(ctx, id, bson.M{
+		"$addToSet": bson.M{"members": subject},
+	})
+}
+
+func (m *mongoRepository) RemoveProjectMember(ctx context.Context, id string, subject string) (*service.Project, error) {
+	return m.updateProject(ctx, id, bson.M{
+		"$pull": bson.M{"members": subject},
+	})
+}
+
+func (m *mongoRepository) SetProjectQuota(ctx context.Context, id string, quota *service.Quota) (*service.Project, error) {
+	if quota == nil {
+		return m.updateProject(ctx, id, bson.M{
+			"$unset": bson.M{"quota": ""},
+		})
+	}
+
+	return m.updateProject(ctx, id, bson.M{
+		"$set": bson.M{"quota": quotaBusinessToData(quota)},
+	})
+}
+
+// LockProject stores the holder of the lock in the project document itself, an expired lock is taken over
+func (m *mongoRepository) LockProject(ctx context.Context, id string, holder string, until time.Time) error {
+	res, err := m.projectCollection.UpdateOne(ctx,
+		bson.M{
+			"_id": id,
+			"$or": bson.A{
+				bson.M{"lock.until": bson.M{"$exists": false}},
+				bson.M{"lock.until": bson.M{"$lte": time.Now()}},
+			},
+		},
+		bson.M{
+			"$set": bson.M{"lock": bson.M{
+				"holder": holder,
+				"until":  until,
+			}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		count, err := m.projectCollection.CountDocuments(ctx, bson.M{"_id": id})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return service.ErrNotFound
+		}
+
+		return service.ErrConflict
+	}
+
+	return nil
+}
+
+func (m *mongoRepository) UnlockProject(ctx context.Context, id string, holder string) error {
+	_, err := m.projectCollection.UpdateOne(ctx,
+		bson.M{
+			"_id":         id,
+			"lock.holder": holder,
+		},
+		bson.M{
+			"$unset": bson.M{"lock": ""},
+		},
+	)
+
+	return err
+}
+
+func (m *mongoRepository) updateProject(ctx context.Context, id string, update bson.M) (*service.Project, error) {
+	res := m.projectCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+	if err := res.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, service.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	project := &Project{}
+	if err := res.Decode(project); err != nil {
+		return nil, err
+	}
+
+	return projectDataToBusiness(project), nil
+}
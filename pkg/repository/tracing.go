@@ -0,0 +1,455 @@
+package repository
+
+import (
+	"context"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+type repositoryTracing struct {
+	next   service.Repository
+	tracer trace.Tracer
+}
+
+// TracingMiddleware records a span for every call made within a trace, named after the method.
+// Calls outside of any trace, as the polls of the background workers, are not traced.
+func TracingMiddleware(tracer trace.Tracer) Middleware {
+	return func(repository service.Repository) service.Repository {
+		return &repositoryTracing{
+			next:   repository,
+			tracer: tracer,
+		}
+	}
+}
+
+func (r repositoryTracing) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	return r.tracer.Start(ctx, "repository."+method, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (r repositoryTracing) Init(ctx context.Context) (err error) {
+	ctx, span := r.start(ctx, "Init")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.Init(ctx)
+}
+
+func (r repositoryTracing) CreateDeploy(ctx context.Context, deploy *service.Deploy) (id string, err error) {
+	ctx, span := r.start(ctx, "CreateDeploy")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.CreateDeploy(ctx, deploy)
+}
+
+func (r repositoryTracing) GetDeploy(ctx context.Context, id string) (deploy *service.Deploy, err error) {
+	ctx, span := r.start(ctx, "GetDeploy")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.GetDeploy(ctx, id)
+}
+
+func (r repositoryTracing) GetDeployByName(ctx context.Context, projectId string, name string) (deploy *service.Deploy, err error) {
+	ctx, span := r.start(ctx, "GetDeployByName")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.GetDeployByName(ctx, projectId, name)
+}
+
+func (r repositoryTracing) ListDeploy(ctx context.Context, projects []string) (deploys []*service.Deploy, err error) {
+	ctx, span := r.start(ctx, "ListDeploy")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.ListDeploy(ctx, projects)
+}
+
+func (r repositoryTracing) UpdateDeploy(ctx context.Context, deploy *service.Deploy, tasks []*service.Task) (err error) {
+	ctx, span := r.start(ctx, "UpdateDeploy")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.UpdateDeploy(ctx, deploy, tasks)
+}
+
+func (r repositoryTracing) DeleteDeploy(ctx context.Context, id string) (err error) {
+	ctx, span := r.start(ctx, "DeleteDeploy")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.DeleteDeploy(ctx, id)
+}
+
+func (r repositoryTracing) InitBuild(ctx context.Context, id string, jobName, jobId, imageName string) (err error) {
+	ctx, span := r.start(ctx, "InitBuild")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.InitBuild(ctx, id, jobName, jobId, imageName)
+}
+
+func (r repositoryTracing) InitWorkload(ctx context.Context, id string, jobName, jobId string, envs map[string]string, url string) (err error) {
+	ctx, span := r.start(ctx, "InitWorkload")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.InitWorkload(ctx, id, jobName, jobId, envs, url)
+}
+
+func (r repositoryTracing) SetBuildStatus(ctx context.Context, id string, status service.Status) (err error) {
+	ctx, span := r.start(ctx, "SetBuildStatus")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.SetBuildStatus(ctx, id, status)
+}
+
+func (r repositoryTracing) RecordBuildStep(ctx context.Context, id string, buildStep service.BuildStep) (err error) {
+	ctx, span := r.start(ctx, "RecordBuildStep")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.RecordBuildStep(ctx, id, buildStep)
+}
+
+func (r repositoryTracing) SetWorkloadStatus(ctx context.Context, id string, status service.WorkloadStatus) (err error) {
+	ctx, span := r.start(ctx, "SetWorkloadStatus")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.SetWorkloadStatus(ctx, id, status)
+}
+
+func (r repositoryTracing) SetWorkloadHealth(ctx context.Context, id string, health service.WorkloadHealth) (err error) {
+	ctx, span := r.start(ctx, "SetWorkloadHealth")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.SetWorkloadHealth(ctx, id, health)
+}
+
+func (r repositoryTracing) ListQueuedBuilds(ctx context.Context) (deploys []*service.Deploy, err error) {
+	ctx, span := r.start(ctx, "ListQueuedBuilds")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.ListQueuedBuilds(ctx)
+}
+
+func (r repositoryTracing) CountBuildsInProgress(ctx context.Context) (counts map[string]int, err error) {
+	ctx, span := r.start(ctx, "CountBuildsInProgress")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.CountBuildsInProgress(ctx)
+}
+
+func (r repositoryTracing) TimeoutBuilds(ctx context.Context, startedBefore time.Time) (failed int64, err error) {
+	ctx, span := r.start(ctx, "TimeoutBuilds")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.TimeoutBuilds(ctx, startedBefore)
+}
+
+func (r repositoryTracing) AdmitBuild(ctx context.Context, id string, task *service.Task) (err error) {
+	ctx, span := r.start(ctx, "AdmitBuild")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.AdmitBuild(ctx, id, task)
+}
+
+func (r repositoryTracing) EnqueueTask(ctx context.Context, id string, task *service.Task) (err error) {
+	ctx, span := r.start(ctx, "EnqueueTask")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.EnqueueTask(ctx, id, task)
+}
+
+func (r repositoryTracing) ClaimTask(ctx context.Context, lease time.Duration) (id string, task *service.Task, err error) {
+	ctx, span := r.start(ctx, "ClaimTask")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.ClaimTask(ctx, lease)
+}
+
+func (r repositoryTracing) RetryTask(ctx context.Context, id string, taskId string, nextAttemptAt time.Time, lastError string) (err error) {
+	ctx, span := r.start(ctx, "RetryTask")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.RetryTask(ctx, id, taskId, nextAttemptAt, lastError)
+}
+
+func (r repositoryTracing) CompleteTask(ctx context.Context, id string, taskId string) (err error) {
+	ctx, span := r.start(ctx, "CompleteTask")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.CompleteTask(ctx, id, taskId)
+}
+
+func (r repositoryTracing) CreateAuditEvent(ctx context.Context, event *service.AuditEvent) (id string, err error) {
+	ctx, span := r.start(ctx, "CreateAuditEvent")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.CreateAuditEvent(ctx, event)
+}
+
+func (r repositoryTracing) ListAuditEvents(ctx context.Context, filter service.AuditFilter, projects []string) (events []*service.AuditEvent, err error) {
+	ctx, span := r.start(ctx, "ListAuditEvents")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.ListAuditEvents(ctx, filter, projects)
+}
+
+func (r repositoryTracing) CreateDeadLetter(ctx context.Context, letter *service.DeadLetter) (id string, err error) {
+	ctx, span := r.start(ctx, "CreateDeadLetter")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.CreateDeadLetter(ctx, letter)
+}
+
+func (r repositoryTracing) GetDeadLetter(ctx context.Context, id string) (letter *service.DeadLetter, err error) {
+	ctx, span := r.start(ctx, "GetDeadLetter")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.GetDeadLetter(ctx, id)
+}
+
+func (r repositoryTracing) ListDeadLetters(ctx context.Context, deployId string) (letters []*service.DeadLetter, err error) {
+	ctx, span := r.start(ctx, "ListDeadLetters")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.ListDeadLetters(ctx, deployId)
+}
+
+func (r repositoryTracing) DeleteDeadLetter(ctx context.Context, id string) (err error) {
+	ctx, span := r.start(ctx, "DeleteDeadLetter")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.DeleteDeadLetter(ctx, id)
+}
+
+func (r repositoryTracing) CreateDomain(ctx context.Context, domain *service.Domain) (err error) {
+	ctx, span := r.start(ctx, "CreateDomain")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.CreateDomain(ctx, domain)
+}
+
+func (r repositoryTracing) GetDomain(ctx context.Context, name string) (domain *service.Domain, err error) {
+	ctx, span := r.start(ctx, "GetDomain")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.GetDomain(ctx, name)
+}
+
+func (r repositoryTracing) ListDomains(ctx context.Context, deployId string) (domains []*service.Domain, err error) {
+	ctx, span := r.start(ctx, "ListDomains")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.ListDomains(ctx, deployId)
+}
+
+func (r repositoryTracing) VerifyDomain(ctx context.Context, name string, token string, verifiedAt time.Time) (err error) {
+	ctx, span := r.start(ctx, "VerifyDomain")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.VerifyDomain(ctx, name, token, verifiedAt)
+}
+
+func (r repositoryTracing) DeleteDomain(ctx context.Context, name string) (err error) {
+	ctx, span := r.start(ctx, "DeleteDomain")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.DeleteDomain(ctx, name)
+}
+
+func (r repositoryTracing) DeleteDomains(ctx context.Context, deployId string) (err error) {
+	ctx, span := r.start(ctx, "DeleteDomains")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.DeleteDomains(ctx, deployId)
+}
+
+func (r repositoryTracing) CreateIdempotencyKey(ctx context.Context, key *service.IdempotencyKey) (err error) {
+	ctx, span := r.start(ctx, "CreateIdempotencyKey")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.CreateIdempotencyKey(ctx, key)
+}
+
+func (r repositoryTracing) GetIdempotencyKey(ctx context.Context, key string, method string) (idempotencyKey *service.IdempotencyKey, err error) {
+	ctx, span := r.start(ctx, "GetIdempotencyKey")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.GetIdempotencyKey(ctx, key, method)
+}
+
+func (r repositoryTracing) CompleteIdempotencyKey(ctx context.Context, key string, method string, result string) (err error) {
+	ctx, span := r.start(ctx, "CompleteIdempotencyKey")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.CompleteIdempotencyKey(ctx, key, method, result)
+}
+
+func (r repositoryTracing) DeleteIdempotencyKey(ctx context.Context, key string, method string) (err error) {
+	ctx, span := r.start(ctx, "DeleteIdempotencyKey")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.DeleteIdempotencyKey(ctx, key, method)
+}
+
+func (r repositoryTracing) CreateProject(ctx context.Context, project *service.Project) (err error) {
+	ctx, span := r.start(ctx, "CreateProject")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.CreateProject(ctx, project)
+}
+
+func (r repositoryTracing) GetProject(ctx context.Context, id string) (project *service.Project, err error) {
+	ctx, span := r.start(ctx, "GetProject")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.GetProject(ctx, id)
+}
+
+func (r repositoryTracing) ListProjects(ctx context.Context, member string) (projects []*service.Project, err error) {
+	ctx, span := r.start(ctx, "ListProjects")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.ListProjects(ctx, member)
+}
+
+func (r repositoryTracing) DeleteProject(ctx context.Context, id string) (err error) {
+	ctx, span := r.start(ctx, "DeleteProject")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.DeleteProject(ctx, id)
+}
+
+func (r repositoryTracing) AddProjectMember(ctx context.Context, id string, subject string) (project *service.Project, err error) {
+	ctx, span := r.start(ctx, "AddProjectMember")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.AddProjectMember(ctx, id, subject)
+}
+
+func (r repositoryTracing) RemoveProjectMember(ctx context.Context, id string, subject string) (project *service.Project, err error) {
+	ctx, span := r.start(ctx, "RemoveProjectMember")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.RemoveProjectMember(ctx, id, subject)
+}
+
+func (r repositoryTracing) LockProject(ctx context.Context, id string, holder string, until time.Time) (err error) {
+	ctx, span := r.start(ctx, "LockProject")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.LockProject(ctx, id, holder, until)
+}
+
+func (r repositoryTracing) UnlockProject(ctx context.Context, id string, holder string) (err error) {
+	ctx, span := r.start(ctx, "UnlockProject")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.UnlockProject(ctx, id, holder)
+}
+
+func (r repositoryTracing) SetProjectQuota(ctx context.Context, id string, quota *service.Quota) (project *service.Project, err error) {
+	ctx, span := r.start(ctx, "SetProjectQuota")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return r.next.SetProjectQuota(ctx, id, quota)
+}
@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrCircuitOpen = errors.New("scheduler circuit breaker is open")
+
+// Error is a failure returned by the scheduler, keeping the gRPC code of the original status
+type Error struct {
+	Code    codes.Code
+	Message string
+}
+
+func NewError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if e, ok := status.FromError(err); ok {
+		return &Error{
+			Code:    e.Code(),
+			Message: e.Message(),
+		}
+	}
+
+	return &Error{
+		Code:    codes.Unknown,
+		Message: err.Error(),
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Scheduler: %s: %s", e.Code, e.Message)
+}
+
+// GRPCStatus lets status.FromError and status.Code see the original code
+func (e *Error) GRPCStatus() *status.Status {
+	return status.New(e.Code, e.Message)
+}
+
+// IsTransient reports whether a request failed with err can be retried
+func IsTransient(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	return e.Code == codes.Unavailable || e.Code == codes.DeadlineExceeded
+}
+
+// IsTimeout reports whether a request failed with err may have been applied by the scheduler anyway
+func IsTimeout(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	return e.Code == codes.DeadlineExceeded
+}
@@ -6,29 +6,28 @@ import (
 	middleware "github.com/Scarlet-Fairy/manager/pkg/scheduler"
 	"github.com/Scarlet-Fairy/manager/pkg/service"
 	"github.com/go-kit/kit/log"
-	"github.com/pkg/errors"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 type grpcScheduler struct {
 	client pb.SchedulerClient
 }
 
-func New(client pb.SchedulerClient, logger log.Logger) service.Scheduler {
+func New(client pb.SchedulerClient, config middleware.ResilienceConfig, logger log.Logger) service.Scheduler {
 	var instance service.Scheduler
 	instance = &grpcScheduler{
 		client: client,
 	}
+	instance = middleware.ResilienceMiddleware(config, logger)(instance)
 	instance = middleware.LoggingMiddleware(logger)(instance)
 
 	return instance
 }
 
-func (g grpcScheduler) ScheduleImageBuild(ctx context.Context, workloadId string, gitRepoUrl string) (string, string, error) {
+func (g grpcScheduler) ScheduleImageBuild(ctx context.Context, workloadId string, buildId string, gitRepoUrl string) (string, string, error) {
 	res, err := g.client.ScheduleImageBuild(ctx, &pb.ScheduleImageBuildRequest{
 		WorkloadId: workloadId,
 		GitRepoUrl: gitRepoUrl,
+		BuildId:    buildId,
 	})
 	if err != nil {
 		return "", "", g.handleGrpcError(err)
@@ -37,10 +36,18 @@ func (g grpcScheduler) ScheduleImageBuild(ctx context.Context, workloadId string
 	return res.JobName, res.ImageName, nil
 }
 
-func (g grpcScheduler) ScheduleWorkload(ctx context.Context, envs map[string]string, workloadId string) (string, string, error) {
+func (g grpcScheduler) ScheduleWorkload(ctx context.Context, envs map[string]string, workloadId string, replicas int, resources service.Resources, domains []string) (string, string, error) {
 	res, err := g.client.ScheduleWorkload(ctx, &pb.ScheduleWorkloadRequest{
 		Envs:       envs,
 		WorkloadId: workloadId,
+		Replicas:   int32(replicas),
+		Resources: &pb.ScheduleWorkloadRequest_Resources{
+			CpuRequestMillis:   resources.CpuRequestMillis,
+			CpuLimitMillis:     resources.CpuLimitMillis,
+			MemoryRequestBytes: resources.MemoryRequestBytes,
+			MemoryLimitBytes:   resources.MemoryLimitBytes,
+		},
+		Domains: domains,
 	})
 	if err != nil {
 		return "", "", g.handleGrpcError(err)
@@ -60,15 +67,21 @@ func (g grpcScheduler) UnScheduleJob(ctx context.Context, jobId string) error {
 	return nil
 }
 
-func (g grpcScheduler) handleGrpcError(err error) error {
-	if e, ok := status.FromError(err); ok {
-		switch e.Code() {
-		case codes.InvalidArgument:
-			return errors.Wrap(errors.New(e.Message()), "Scheduler")
-		default:
-			return errors.Errorf("%s: %s", e.Code(), e.Message())
-		}
-	} else {
-		return errors.New("Unable to parse gRPC Scheduler error")
+func (g grpcScheduler) GetJobStatus(ctx context.Context, jobId string) (*service.JobStatus, error) {
+	res, err := g.client.GetJobStatus(ctx, &pb.GetJobStatusRequest{
+		JobId: jobId,
+	})
+	if err != nil {
+		return nil, g.handleGrpcError(err)
 	}
+
+	return &service.JobStatus{
+		State:    service.JobState(res.State),
+		Restarts: int(res.RestartCount),
+		Message:  res.Message,
+	}, nil
+}
+
+func (g grpcScheduler) handleGrpcError(err error) error {
+	return middleware.NewError(err)
 }
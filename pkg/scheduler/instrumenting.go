@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/metrics"
+	"time"
+)
+
+type schedulerInstrumenting struct {
+	next           service.Scheduler
+	requestCount   metrics.Counter
+	errorCount     metrics.Counter
+	requestLatency metrics.Histogram
+}
+
+// InstrumentingMiddleware counts the calls and the errors of every method and observes their latency in seconds,
+// the metrics are labelled by method
+func InstrumentingMiddleware(requestCount metrics.Counter, errorCount metrics.Counter, requestLatency metrics.Histogram) Middleware {
+	return func(scheduler service.Scheduler) service.Scheduler {
+		return &schedulerInstrumenting{
+			next:           scheduler,
+			requestCount:   requestCount,
+			errorCount:     errorCount,
+			requestLatency: requestLatency,
+		}
+	}
+}
+
+func (s schedulerInstrumenting) observe(method string, begin time.Time, err error) {
+	s.requestCount.With("method", method).Add(1)
+	if err != nil {
+		s.errorCount.With("method", method).Add(1)
+	}
+	s.requestLatency.With("method", method).Observe(time.Since(begin).Seconds())
+}
+
+func (s schedulerInstrumenting) ScheduleImageBuild(ctx context.Context, workloadId string, buildId string, gitRepoUrl string) (jobName string, imageName string, err error) {
+	defer func(begin time.Time) {
+		s.observe("ScheduleImageBuild", begin, err)
+	}(time.Now())
+
+	return s.next.ScheduleImageBuild(ctx, workloadId, buildId, gitRepoUrl)
+}
+
+func (s schedulerInstrumenting) ScheduleWorkload(ctx context.Context, envs map[string]string, workloadId string, replicas int, resources service.Resources, domains []string) (jobName string, url string, err error) {
+	defer func(begin time.Time) {
+		s.observe("ScheduleWorkload", begin, err)
+	}(time.Now())
+
+	return s.next.ScheduleWorkload(ctx, envs, workloadId, replicas, resources, domains)
+}
+
+func (s schedulerInstrumenting) UnScheduleJob(ctx context.Context, jobId string) (err error) {
+	defer func(begin time.Time) {
+		s.observe("UnScheduleJob", begin, err)
+	}(time.Now())
+
+	return s.next.UnScheduleJob(ctx, jobId)
+}
+
+func (s schedulerInstrumenting) GetJobStatus(ctx context.Context, jobId string) (status *service.JobStatus, err error) {
+	defer func(begin time.Time) {
+		s.observe("GetJobStatus", begin, err)
+	}(time.Now())
+
+	return s.next.GetJobStatus(ctx, jobId)
+}
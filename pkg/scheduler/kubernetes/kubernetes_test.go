@@ -0,0 +1,346 @@
+package kubernetes
+
+import (
+	"context"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/log"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+const (
+	namespace  = "workloads"
+	workloadId = "project-6138c6d0c3b5a9d2e1b4f7a1"
+	buildId    = "4e0b5c1d2f3a4b5c6d7e8f90"
+)
+
+func newScheduler(config Config) (service.Scheduler, *fake.Clientset) {
+	clientset := fake.NewSimpleClientset()
+	config.Namespace = namespace
+
+	return New(clientset, config, log.NewNopLogger()), clientset
+}
+
+func envValue(envs []corev1.EnvVar, name string) (string, bool) {
+	for _, env := range envs {
+		if env.Name == name {
+			return env.Value, true
+		}
+	}
+
+	return "", false
+}
+
+func ingressHosts(t *testing.T, clientset *fake.Clientset, name string) []string {
+	t.Helper()
+
+	ingress, err := clientset.NetworkingV1().Ingresses(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting Ingress: %v", err)
+	}
+
+	var hosts []string
+	for _, rule := range ingress.Spec.Rules {
+		hosts = append(hosts, rule.Host)
+	}
+
+	return hosts
+}
+
+func TestScheduleImageBuildCreatesJob(t *testing.T) {
+	scheduler, clientset := newScheduler(DefaultConfig())
+	ctx := context.Background()
+
+	jobName, imageName, err := scheduler.ScheduleImageBuild(ctx, workloadId, buildId, "https://github.com/Scarlet-Fairy/cobold.git")
+	if err != nil {
+		t.Fatalf("ScheduleImageBuild: %v", err)
+	}
+	if jobName != service.JobId(workloadId).NameImageBuild(buildId) {
+		t.Errorf("job name %q", jobName)
+	}
+	if imageName != service.JobId(workloadId).ImageName(DefaultConfig().Registry) {
+		t.Errorf("image name %q", imageName)
+	}
+
+	job, err := clientset.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting Job: %v", err)
+	}
+	if job.Labels[WorkloadLabel] != workloadId || job.Labels[JobTypeLabel] != service.JobTypeImageBuild {
+		t.Errorf("Job labels %v", job.Labels)
+	}
+	if *job.Spec.BackoffLimit != 0 {
+		t.Errorf("Job retried %d times by kubernetes, the retries are up to the manager", *job.Spec.BackoffLimit)
+	}
+
+	containers := job.Spec.Template.Spec.Containers
+	if len(containers) != 1 || containers[0].Image != DefaultConfig().BuilderImage {
+		t.Fatalf("Job containers %v", containers)
+	}
+	for name, want := range map[string]string{
+		"WORKLOAD_ID":  workloadId,
+		"GIT_REPO_URL": "https://github.com/Scarlet-Fairy/cobold.git",
+		"IMAGE_NAME":   imageName,
+		"BROKER_URL":   DefaultConfig().BrokerUrl,
+	} {
+		if value, _ := envValue(containers[0].Env, name); value != want {
+			t.Errorf("env %s = %q, want %q", name, value, want)
+		}
+	}
+
+	// a retried scheduling finds the Job already there
+	if _, _, err := scheduler.ScheduleImageBuild(ctx, workloadId, buildId, "https://github.com/Scarlet-Fairy/cobold.git"); err != nil {
+		t.Errorf("second ScheduleImageBuild: %v", err)
+	}
+	if jobs := buildJobs(t, clientset); len(jobs) != 1 {
+		t.Errorf("%d build Jobs after a retried scheduling, want 1", len(jobs))
+	}
+
+	// while another build of the same workload gets a Job of its own
+	if _, _, err := scheduler.ScheduleImageBuild(ctx, workloadId, "5f1a2b3c4d5e6f708192a3b4", "https://github.com/Scarlet-Fairy/cobold.git"); err != nil {
+		t.Fatalf("ScheduleImageBuild of another build: %v", err)
+	}
+	if jobs := buildJobs(t, clientset); len(jobs) != 2 {
+		t.Errorf("%d build Jobs after another build, want 2", len(jobs))
+	}
+}
+
+func TestScheduleImageBuildNamesFitLabels(t *testing.T) {
+	scheduler, _ := newScheduler(DefaultConfig())
+
+	for _, workloadId := range []string{
+		workloadId,
+		// deploys of the default project and of a project with a long slug
+		"default-6138c6d0c3b5a9d2e1b4f7a1",
+		"a-project-with-a-long-slug-6138c6d0c3b5a9d2e1b4f7a1",
+	} {
+		jobName, _, err := scheduler.ScheduleImageBuild(context.Background(), workloadId, buildId, "https://github.com/Scarlet-Fairy/cobold.git")
+		if err != nil {
+			t.Fatalf("ScheduleImageBuild(%s): %v", workloadId, err)
+		}
+
+		// the fake clientset does not validate the names as the apiserver does
+		if len(jobName) > validation.DNS1123LabelMaxLength {
+			t.Errorf("job name %q is %d characters long, over %d", jobName, len(jobName), validation.DNS1123LabelMaxLength)
+		}
+		if errs := validation.IsDNS1123Subdomain(jobName); len(errs) > 0 {
+			t.Errorf("job name %q: %v", jobName, errs)
+		}
+		if errs := validation.IsValidLabelValue(jobName); len(errs) > 0 {
+			t.Errorf("job-name label %q: %v", jobName, errs)
+		}
+	}
+
+	// the cut names stay apart by their hash
+	longId := service.JobId("a-project-with-a-long-slug-6138c6d0c3b5a9d2e1b4f7a1")
+	if longId.NameImageBuild(buildId) == longId.NameImageBuild("5f1a2b3c4d5e6f708192a3b4") {
+		t.Error("builds of the same workload share the job name")
+	}
+}
+
+func buildJobs(t *testing.T, clientset *fake.Clientset) []batchv1.Job {
+	t.Helper()
+
+	jobs, err := clientset.BatchV1().Jobs(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("listing Jobs: %v", err)
+	}
+
+	return jobs.Items
+}
+
+func TestScheduleWorkloadCreatesDeploymentServiceAndIngress(t *testing.T) {
+	scheduler, clientset := newScheduler(DefaultConfig())
+	ctx := context.Background()
+
+	resources := service.Resources{
+		CpuRequestMillis: 250,
+		MemoryLimitBytes: 256 << 20,
+	}
+	jobName, url, err := scheduler.ScheduleWorkload(ctx, map[string]string{"GREETING": "hello"}, workloadId, 2, resources, nil)
+	if err != nil {
+		t.Fatalf("ScheduleWorkload: %v", err)
+	}
+	if jobName != service.JobId(workloadId).NameWorkload() {
+		t.Errorf("job name %q", jobName)
+	}
+	if url != "http://"+workloadId+".scarlet-fairy.local" {
+		t.Errorf("url %q", url)
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, jobName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting Deployment: %v", err)
+	}
+	if *deployment.Spec.Replicas != 2 {
+		t.Errorf("Deployment replicas %d", *deployment.Spec.Replicas)
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.Image != service.JobId(workloadId).ImageName(DefaultConfig().Registry) {
+		t.Errorf("Deployment image %q", container.Image)
+	}
+	if value, _ := envValue(container.Env, "GREETING"); value != "hello" {
+		t.Errorf("env GREETING = %q", value)
+	}
+	if value, _ := envValue(container.Env, "PORT"); value != "8080" {
+		t.Errorf("env PORT = %q", value)
+	}
+	if cpu := container.Resources.Requests[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("250m")) != 0 {
+		t.Errorf("cpu request %s", cpu.String())
+	}
+	if memory := container.Resources.Limits[corev1.ResourceMemory]; memory.Cmp(resource.MustParse("256Mi")) != 0 {
+		t.Errorf("memory limit %s", memory.String())
+	}
+	if _, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
+		t.Error("cpu limit set although left to the defaults")
+	}
+
+	svc, err := clientset.CoreV1().Services(namespace).Get(ctx, serviceName(jobName), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting Service: %v", err)
+	}
+	if svc.Spec.Selector[WorkloadLabel] != workloadId || svc.Spec.Ports[0].TargetPort.IntValue() != 8080 {
+		t.Errorf("Service spec %v", svc.Spec)
+	}
+
+	if hosts := ingressHosts(t, clientset, jobName); len(hosts) != 1 || hosts[0] != workloadId+".scarlet-fairy.local" {
+		t.Errorf("Ingress hosts %v", hosts)
+	}
+}
+
+func TestScheduleWorkloadUpdatesExistingObjects(t *testing.T) {
+	scheduler, clientset := newScheduler(DefaultConfig())
+	ctx := context.Background()
+
+	jobName, _, err := scheduler.ScheduleWorkload(ctx, map[string]string{"GREETING": "hello"}, workloadId, 1, service.Resources{}, nil)
+	if err != nil {
+		t.Fatalf("ScheduleWorkload: %v", err)
+	}
+
+	if _, _, err := scheduler.ScheduleWorkload(ctx, map[string]string{"GREETING": "ciao"}, workloadId, 3, service.Resources{}, []string{"example.com"}); err != nil {
+		t.Fatalf("second ScheduleWorkload: %v", err)
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, jobName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting Deployment: %v", err)
+	}
+	if *deployment.Spec.Replicas != 3 {
+		t.Errorf("Deployment replicas %d, want 3", *deployment.Spec.Replicas)
+	}
+	if value, _ := envValue(deployment.Spec.Template.Spec.Containers[0].Env, "GREETING"); value != "ciao" {
+		t.Errorf("env GREETING = %q, want ciao", value)
+	}
+
+	if hosts := ingressHosts(t, clientset, jobName); len(hosts) != 2 || hosts[1] != "example.com" {
+		t.Errorf("Ingress hosts %v, want the custom domain as well", hosts)
+	}
+}
+
+func TestScheduleWorkloadServesCustomDomainsWithTLS(t *testing.T) {
+	config := DefaultConfig()
+	config.TLSIssuer = "letsencrypt"
+	scheduler, clientset := newScheduler(config)
+	ctx := context.Background()
+
+	jobName, _, err := scheduler.ScheduleWorkload(ctx, nil, workloadId, 1, service.Resources{}, nil)
+	if err != nil {
+		t.Fatalf("ScheduleWorkload: %v", err)
+	}
+
+	ingress, err := clientset.NetworkingV1().Ingresses(namespace).Get(ctx, jobName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting Ingress: %v", err)
+	}
+	if len(ingress.Spec.TLS) != 0 || len(ingress.Annotations) != 0 {
+		t.Errorf("TLS requested without custom domains: %v %v", ingress.Spec.TLS, ingress.Annotations)
+	}
+
+	if _, _, err := scheduler.ScheduleWorkload(ctx, nil, workloadId, 1, service.Resources{}, []string{"example.com"}); err != nil {
+		t.Fatalf("ScheduleWorkload with domains: %v", err)
+	}
+
+	ingress, err = clientset.NetworkingV1().Ingresses(namespace).Get(ctx, jobName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting Ingress: %v", err)
+	}
+	if ingress.Annotations["cert-manager.io/cluster-issuer"] != "letsencrypt" {
+		t.Errorf("Ingress annotations %v", ingress.Annotations)
+	}
+	if len(ingress.Spec.TLS) != 1 {
+		t.Fatalf("Ingress TLS %v", ingress.Spec.TLS)
+	}
+	tls := ingress.Spec.TLS[0]
+	if len(tls.Hosts) != 1 || tls.Hosts[0] != "example.com" || tls.SecretName != serviceName(jobName)+"-tls" {
+		t.Errorf("Ingress TLS %v", tls)
+	}
+}
+
+func TestUnScheduleJobDeletesObjects(t *testing.T) {
+	scheduler, clientset := newScheduler(DefaultConfig())
+	ctx := context.Background()
+
+	buildName, _, err := scheduler.ScheduleImageBuild(ctx, workloadId, buildId, "https://github.com/Scarlet-Fairy/cobold.git")
+	if err != nil {
+		t.Fatalf("ScheduleImageBuild: %v", err)
+	}
+	workloadName, _, err := scheduler.ScheduleWorkload(ctx, nil, workloadId, 1, service.Resources{}, nil)
+	if err != nil {
+		t.Fatalf("ScheduleWorkload: %v", err)
+	}
+
+	if err := scheduler.UnScheduleJob(ctx, buildName); err != nil {
+		t.Fatalf("UnScheduleJob of the build: %v", err)
+	}
+	if _, err := clientset.BatchV1().Jobs(namespace).Get(ctx, buildName, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("build Job still there: %v", err)
+	}
+	if _, err := clientset.AppsV1().Deployments(namespace).Get(ctx, workloadName, metav1.GetOptions{}); err != nil {
+		t.Errorf("workload Deployment deleted along with the build: %v", err)
+	}
+
+	if err := scheduler.UnScheduleJob(ctx, workloadName); err != nil {
+		t.Fatalf("UnScheduleJob of the workload: %v", err)
+	}
+	if _, err := clientset.AppsV1().Deployments(namespace).Get(ctx, workloadName, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("workload Deployment still there: %v", err)
+	}
+	if _, err := clientset.CoreV1().Services(namespace).Get(ctx, serviceName(workloadName), metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("workload Service still there: %v", err)
+	}
+	if _, err := clientset.NetworkingV1().Ingresses(namespace).Get(ctx, workloadName, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("workload Ingress still there: %v", err)
+	}
+
+	// objects already gone are not an error, the unschedulation is retried until it succeeds
+	if err := scheduler.UnScheduleJob(ctx, workloadName); err != nil {
+		t.Errorf("second UnScheduleJob of the workload: %v", err)
+	}
+	if err := scheduler.UnScheduleJob(ctx, buildName); err != nil {
+		t.Errorf("second UnScheduleJob of the build: %v", err)
+	}
+}
+
+func TestGetJobStatusOfMissingJobs(t *testing.T) {
+	scheduler, _ := newScheduler(DefaultConfig())
+	ctx := context.Background()
+
+	for _, jobId := range []string{
+		service.JobId(workloadId).NameImageBuild(buildId),
+		service.JobId(workloadId).NameWorkload(),
+	} {
+		status, err := scheduler.GetJobStatus(ctx, jobId)
+		if err != nil {
+			t.Fatalf("GetJobStatus(%s): %v", jobId, err)
+		}
+		if status.State != service.JobStateNotFound {
+			t.Errorf("GetJobStatus(%s) = %s, want not found", jobId, status.State.ToString())
+		}
+	}
+}
@@ -22,11 +22,12 @@ func LoggingMiddleware(logger log.Logger) Middleware {
 	}
 }
 
-func (s schedulerLogger) ScheduleImageBuild(ctx context.Context, workloadId string, gitRepoUrl string) (jobName string, imageName string, err error) {
+func (s schedulerLogger) ScheduleImageBuild(ctx context.Context, workloadId string, buildId string, gitRepoUrl string) (jobName string, imageName string, err error) {
 	defer func() {
 		s.logger.Log(
 			"method", "ScheduleImageBuild",
 			"workloadId", workloadId,
+			"buildId", buildId,
 			"gitRepoUrl", gitRepoUrl,
 			"jobName", jobName,
 			"imageName", imageName,
@@ -34,22 +35,25 @@ func (s schedulerLogger) ScheduleImageBuild(ctx context.Context, workloadId stri
 		)
 	}()
 
-	return s.next.ScheduleImageBuild(ctx, workloadId, gitRepoUrl)
+	return s.next.ScheduleImageBuild(ctx, workloadId, buildId, gitRepoUrl)
 }
 
-func (s schedulerLogger) ScheduleWorkload(ctx context.Context, envs map[string]string, workloadId string) (jobName string, url string, err error) {
+func (s schedulerLogger) ScheduleWorkload(ctx context.Context, envs map[string]string, workloadId string, replicas int, resources service.Resources, domains []string) (jobName string, url string, err error) {
 	defer func() {
 		s.logger.Log(
 			"method", "ScheduleWorkload",
 			"envs", envs,
 			"workloadId", workloadId,
+			"replicas", replicas,
+			"resources", resources,
+			"domains", domains,
 			"jobName", jobName,
 			"url", url,
 			"err", err,
 		)
 	}()
 
-	return s.next.ScheduleWorkload(ctx, envs, workloadId)
+	return s.next.ScheduleWorkload(ctx, envs, workloadId, replicas, resources, domains)
 }
 
 func (s schedulerLogger) UnScheduleJob(ctx context.Context, jobId string) (err error) {
@@ -63,3 +67,16 @@ func (s schedulerLogger) UnScheduleJob(ctx context.Context, jobId string) (err e
 
 	return s.next.UnScheduleJob(ctx, jobId)
 }
+
+func (s schedulerLogger) GetJobStatus(ctx context.Context, jobId string) (status *service.JobStatus, err error) {
+	defer func() {
+		s.logger.Log(
+			"method", "GetJobStatus",
+			"jobId", jobId,
+			"status", status,
+			"err", err,
+		)
+	}()
+
+	return s.next.GetJobStatus(ctx, jobId)
+}
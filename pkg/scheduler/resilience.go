@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/sony/gobreaker"
+	"math/rand"
+	"time"
+)
+
+type ResilienceConfig struct {
+	// Deadline of a single attempt of every method
+	ScheduleImageBuildTimeout time.Duration
+	ScheduleWorkloadTimeout   time.Duration
+	UnScheduleJobTimeout      time.Duration
+	GetJobStatusTimeout       time.Duration
+
+	// Deadline of a call along with its retries, it must stay below service.WorkerTaskLease so that
+	// a task is not claimed by another worker while its scheduler call is still running
+	CallTimeout time.Duration
+
+	// Retries of a request failed because the scheduler is unavailable or too slow.
+	// Requests which are not idempotent are retried only when the scheduler was unavailable.
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// Consecutive transient failures opening the circuit breaker, and how long it stays open
+	BreakerFailures    uint32
+	BreakerOpenTimeout time.Duration
+}
+
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		ScheduleImageBuildTimeout: 15 * time.Second,
+		ScheduleWorkloadTimeout:   15 * time.Second,
+		UnScheduleJobTimeout:      10 * time.Second,
+		GetJobStatusTimeout:       5 * time.Second,
+		CallTimeout:               service.WorkerTaskLease / 2,
+		MaxRetries:                3,
+		RetryBaseDelay:            200 * time.Millisecond,
+		RetryMaxDelay:             5 * time.Second,
+		BreakerFailures:           5,
+		BreakerOpenTimeout:        30 * time.Second,
+	}
+}
+
+type schedulerResilience struct {
+	next    service.Scheduler
+	config  ResilienceConfig
+	breaker *gobreaker.CircuitBreaker
+	logger  log.Logger
+}
+
+// ResilienceMiddleware bounds every call to the scheduler with a deadline, retries transient failures
+// with jittered exponential backoff and fails fast while the scheduler keeps being unavailable
+func ResilienceMiddleware(config ResilienceConfig, logger log.Logger) Middleware {
+	return func(scheduler service.Scheduler) service.Scheduler {
+		return &schedulerResilience{
+			next:   scheduler,
+			config: config,
+			breaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{
+				Name:    "scheduler",
+				Timeout: config.BreakerOpenTimeout,
+				ReadyToTrip: func(counts gobreaker.Counts) bool {
+					return counts.ConsecutiveFailures >= config.BreakerFailures
+				},
+				// Requests rejected by the scheduler prove it is reachable
+				IsSuccessful: func(err error) bool {
+					return err == nil || !IsTransient(err)
+				},
+				OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+					level.Warn(logger).Log(
+						"msg", "circuit breaker state changed",
+						"breaker", name,
+						"from", from.String(),
+						"to", to.String(),
+					)
+				},
+			}),
+			logger: logger,
+		}
+	}
+}
+
+// ScheduleImageBuild is retried after a timeout as well, since the scheduler dedupes the builds by buildId
+func (s *schedulerResilience) ScheduleImageBuild(ctx context.Context, workloadId string, buildId string, gitRepoUrl string) (jobName string, imageName string, err error) {
+	err = s.call(ctx, s.config.ScheduleImageBuildTimeout, true, func(ctx context.Context) error {
+		var err error
+		jobName, imageName, err = s.next.ScheduleImageBuild(ctx, workloadId, buildId, gitRepoUrl)
+		return err
+	})
+
+	return jobName, imageName, err
+}
+
+func (s *schedulerResilience) ScheduleWorkload(ctx context.Context, envs map[string]string, workloadId string, replicas int, resources service.Resources, domains []string) (jobName string, url string, err error) {
+	err = s.call(ctx, s.config.ScheduleWorkloadTimeout, false, func(ctx context.Context) error {
+		var err error
+		jobName, url, err = s.next.ScheduleWorkload(ctx, envs, workloadId, replicas, resources, domains)
+		return err
+	})
+
+	return jobName, url, err
+}
+
+func (s *schedulerResilience) UnScheduleJob(ctx context.Context, jobId string) error {
+	return s.call(ctx, s.config.UnScheduleJobTimeout, true, func(ctx context.Context) error {
+		return s.next.UnScheduleJob(ctx, jobId)
+	})
+}
+
+func (s *schedulerResilience) GetJobStatus(ctx context.Context, jobId string) (status *service.JobStatus, err error) {
+	err = s.call(ctx, s.config.GetJobStatusTimeout, true, func(ctx context.Context) error {
+		var err error
+		status, err = s.next.GetJobStatus(ctx, jobId)
+		return err
+	})
+
+	return status, err
+}
+
+// call runs request within CallTimeout. A request which is not idempotent is not retried after a timeout,
+// since the scheduler may have applied it.
+func (s *schedulerResilience) call(ctx context.Context, timeout time.Duration, idempotent bool, request func(ctx context.Context) error) error {
+	if s.config.CallTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.CallTimeout)
+		defer cancel()
+	}
+
+	for attempt := 0; ; attempt++ {
+		_, err := s.breaker.Execute(func() (interface{}, error) {
+			attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			return nil, request(attemptCtx)
+		})
+		if err == gobreaker.ErrOpenState || err == gobreaker.ErrTooManyRequests {
+			return ErrCircuitOpen
+		}
+
+		if err == nil || !IsTransient(err) || attempt >= s.config.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+		if !idempotent && IsTimeout(err) {
+			return err
+		}
+
+		select {
+		case <-time.After(s.backoff(attempt)):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// backoff returns a random delay up to an exponentially growing cap ("full jitter")
+func (s *schedulerResilience) backoff(attempt int) time.Duration {
+	limit := s.config.RetryBaseDelay << uint(attempt)
+	if limit <= 0 || limit > s.config.RetryMaxDelay {
+		limit = s.config.RetryMaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(limit) + 1))
+}
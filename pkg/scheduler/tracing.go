@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type schedulerTracing struct {
+	next   service.Scheduler
+	tracer trace.Tracer
+}
+
+// TracingMiddleware records a span for every call made within a trace, named after the method.
+// Calls outside of any trace, as the polls of the background workers, are not traced.
+func TracingMiddleware(tracer trace.Tracer) Middleware {
+	return func(scheduler service.Scheduler) service.Scheduler {
+		return &schedulerTracing{
+			next:   scheduler,
+			tracer: tracer,
+		}
+	}
+}
+
+func (s schedulerTracing) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	return s.tracer.Start(ctx, "scheduler."+method, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceEnvs returns the trace context of ctx as environment variables for the builder, e.g. TRACEPARENT,
+// so that cobold propagates it in the headers of the build events. They are sorted by name.
+func TraceEnvs(ctx context.Context) []string {
+	header := http.Header{}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+
+	envs := make([]string, 0, len(header))
+	for key := range header {
+		envs = append(envs, strings.ToUpper(key)+"="+header.Get(key))
+	}
+	sort.Strings(envs)
+
+	return envs
+}
+
+func (s schedulerTracing) ScheduleImageBuild(ctx context.Context, workloadId string, buildId string, gitRepoUrl string) (jobName string, imageName string, err error) {
+	ctx, span := s.start(ctx, "ScheduleImageBuild")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return s.next.ScheduleImageBuild(ctx, workloadId, buildId, gitRepoUrl)
+}
+
+func (s schedulerTracing) ScheduleWorkload(ctx context.Context, envs map[string]string, workloadId string, replicas int, resources service.Resources, domains []string) (jobName string, url string, err error) {
+	ctx, span := s.start(ctx, "ScheduleWorkload")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return s.next.ScheduleWorkload(ctx, envs, workloadId, replicas, resources, domains)
+}
+
+func (s schedulerTracing) UnScheduleJob(ctx context.Context, jobId string) (err error) {
+	ctx, span := s.start(ctx, "UnScheduleJob")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return s.next.UnScheduleJob(ctx, jobId)
+}
+
+func (s schedulerTracing) GetJobStatus(ctx context.Context, jobId string) (status *service.JobStatus, err error) {
+	ctx, span := s.start(ctx, "GetJobStatus")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return s.next.GetJobStatus(ctx, jobId)
+}
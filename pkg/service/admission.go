@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAdmissionInterval = time.Second
+	// DefaultBuildTimeout is how long a build can stay in progress before being failed, so that
+	// a build whose events are lost does not hold a place among the builds in progress forever
+	DefaultBuildTimeout = time.Hour
+)
+
+// BuildAdmission starts the queued builds as soon as the caps on the builds in progress allow it:
+// a global cap, and a cap for every project which is the MaxConcurrentBuilds of its quota.
+// Builds are started by priority and then in order of arrival, while builds in progress for longer
+// than the build timeout are failed. The queue is made of the queued deploys
+// themselves, so it survives restarts. Replicas admitting builds at the same time may exceed the caps.
+type BuildAdmission struct {
+	repository Repository
+	// maxBuilds caps the builds in progress of all the projects together, 0 for unlimited
+	maxBuilds int
+	// quota of the projects without one of their own
+	quota        Quota
+	buildTimeout time.Duration
+	interval     time.Duration
+	logger       log.Logger
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewBuildAdmission(repository Repository, maxBuilds int, quota Quota, buildTimeout time.Duration, interval time.Duration, logger log.Logger) *BuildAdmission {
+	return &BuildAdmission{
+		repository:   repository,
+		maxBuilds:    maxBuilds,
+		quota:        quota,
+		buildTimeout: buildTimeout,
+		interval:     interval,
+		logger:       logger,
+		stop:         make(chan struct{}),
+	}
+}
+
+func (a *BuildAdmission) Run() error {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := a.admit(context.Background()); err != nil {
+			level.Error(a.logger).Log(
+				"during", "admit",
+				"err", err,
+			)
+		}
+	}
+}
+
+func (a *BuildAdmission) Stop() {
+	a.once.Do(func() {
+		close(a.stop)
+	})
+}
+
+func (a *BuildAdmission) admit(ctx context.Context) error {
+	if a.buildTimeout != 0 {
+		failed, err := a.repository.TimeoutBuilds(ctx, time.Now().Add(-a.buildTimeout))
+		if err != nil {
+			return errors.Wrap(err, "Failing timed out builds")
+		}
+		if failed != 0 {
+			level.Warn(a.logger).Log(
+				"msg", "failed builds in progress for too long",
+				"builds", failed,
+				"timeout", a.buildTimeout,
+			)
+		}
+	}
+
+	queued, err := a.repository.ListQueuedBuilds(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Listing queued builds")
+	}
+	if len(queued) == 0 {
+		return nil
+	}
+
+	projectInProgress, err := a.repository.CountBuildsInProgress(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Counting builds in progress")
+	}
+
+	inProgress := 0
+	for _, count := range projectInProgress {
+		inProgress += count
+	}
+
+	projectLimits := map[string]int{}
+	for _, deploy := range queued {
+		if a.maxBuilds != 0 && inProgress >= a.maxBuilds {
+			return nil
+		}
+
+		project := deploy.Project()
+		limit, ok := projectLimits[project]
+		if !ok {
+			limit, err = a.projectLimit(ctx, project)
+			if err != nil {
+				return err
+			}
+			projectLimits[project] = limit
+		}
+
+		// a full project does not hold back the builds of the others queued after its own
+		if limit != 0 && projectInProgress[project] >= limit {
+			continue
+		}
+
+		// the build goes on with the trace of the Deploy call which queued it
+		task := newTask(contextWithTrace(ctx, deploy.Build.TraceContext), TaskScheduleImageBuild)
+		if err := a.repository.AdmitBuild(ctx, deploy.Id, task); err != nil {
+			// destroyed, or admitted by another replica, since it has been listed
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+
+			return errors.Wrap(err, "Admitting build")
+		}
+
+		inProgress++
+		projectInProgress[project]++
+	}
+
+	return nil
+}
+
+func (a *BuildAdmission) projectLimit(ctx context.Context, projectId string) (int, error) {
+	project, err := a.repository.GetProject(ctx, projectId)
+	if err != nil {
+		return 0, errors.Wrap(err, "Retrieving Project")
+	}
+
+	return effectiveQuota(project, a.quota).MaxConcurrentBuilds, nil
+}
+
+// queuePosition returns the 1-based position of a queued deploy among every queued build
+func (s *basicService) queuePosition(ctx context.Context, deploy *Deploy) (int, error) {
+	queued, err := s.repository.ListQueuedBuilds(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "Listing queued builds")
+	}
+
+	for i, other := range queued {
+		if other.Id == deploy.Id {
+			return i + 1, nil
+		}
+	}
+
+	// admitted since it has been read
+	return 0, nil
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeDenied  = "denied"
+	AuditOutcomeFailure = "failure"
+)
+
+const (
+	DefaultAuditEventsLimit = 100
+	MaxAuditEventsLimit     = 1000
+)
+
+// redacted replaces the secrets in the request summaries of the audit events
+const redacted = "REDACTED"
+
+// AuditEvent records a mutating call on the service, audit events are never modified nor deleted
+type AuditEvent struct {
+	Id string
+	// Actor is the subject of the caller, empty when the server runs without authentication
+	Actor      string
+	AuthMethod string
+	Method     string
+	// DeployId is the deploy the call targeted, if any
+	DeployId  string
+	ProjectId string
+	// Request summarizes the arguments of the call, with the secrets redacted
+	Request    map[string]string
+	Outcome    string
+	Error      string
+	OccurredAt time.Time
+}
+
+// AuditFilter selects the audit events to list, zero values match every event
+type AuditFilter struct {
+	DeployId string
+	Actor    string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+func (s *basicService) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return nil, errors.Wrap(ErrInvalidAuditFilter, "the end of the time range is before its start")
+	}
+
+	if filter.Limit < 0 {
+		return nil, errors.Wrap(ErrInvalidAuditFilter, "the limit cannot be negative")
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultAuditEventsLimit
+	}
+	if filter.Limit > MaxAuditEventsLimit {
+		filter.Limit = MaxAuditEventsLimit
+	}
+
+	// the events of destroyed deploys are still listed, so they are filtered by project rather than by deploy
+	projects, err := s.callerProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.ListAuditEvents(ctx, filter, projects)
+}
+
+// AuditMiddleware records an AuditEvent for every mutating call, including the denied ones when it wraps
+// AuthorizationMiddleware. Calls are not failed when their event cannot be stored, since their effects
+// have already been applied, the failure is logged instead.
+func AuditMiddleware(repository Repository, logger log.Logger) Middleware {
+	return func(service Service) Service {
+		return &auditMiddleware{
+			next:       service,
+			repository: repository,
+			logger:     logger,
+		}
+	}
+}
+
+type auditMiddleware struct {
+	next       Service
+	repository Repository
+	logger     log.Logger
+}
+
+func (a *auditMiddleware) record(ctx context.Context, method string, deployId string, projectId string, request map[string]string, err error) {
+	event := &AuditEvent{
+		Method:     method,
+		DeployId:   deployId,
+		ProjectId:  projectId,
+		Request:    request,
+		Outcome:    AuditOutcomeSuccess,
+		OccurredAt: time.Now(),
+	}
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		event.Actor = principal.Subject
+		event.AuthMethod = principal.AuthMethod
+	}
+	if err != nil {
+		event.Outcome = AuditOutcomeFailure
+		if errors.Is(err, ErrPermissionDenied) {
+			event.Outcome = AuditOutcomeDenied
+		}
+		event.Error = err.Error()
+	}
+
+	// the event is stored even when the request has been cancelled meanwhile
+	if _, err := a.repository.CreateAuditEvent(context.Background(), event); err != nil {
+		level.Error(a.logger).Log(
+			"msg", "failed to store audit event",
+			"method", method,
+			"deployId", deployId,
+			"actor", event.Actor,
+			"err", err,
+		)
+	}
+}
+
+// projectOf returns the project of a deploy, or an empty string when it cannot be retrieved
+func (a *auditMiddleware) projectOf(ctx context.Context, deployId string) string {
+	deploy, err := a.repository.GetDeploy(ctx, deployId)
+	if err != nil {
+		return ""
+	}
+
+	return deploy.Project()
+}
+
+// redactEnvs keeps only the names of the envs, their values often are credentials
+func redactEnvs(envs map[string]string) string {
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name+"="+redacted)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+// redactGitRepo removes the credentials from the url of a git repository, e.g. https://token@host/repo
+func redactGitRepo(gitRepo string) string {
+	u, err := url.Parse(gitRepo)
+	if err != nil || u.User == nil {
+		return gitRepo
+	}
+	u.User = url.User(redacted)
+
+	return u.String()
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (a *auditMiddleware) Deploy(ctx context.Context, projectId string, gitRepo string, name string, envs map[string]string, labels map[string]string, healthCheck *HealthCheck, replicas int, resources Resources, priority int, idempotencyKey string) (deployId string, err error) {
+	defer func() {
+		project := projectId
+		if project == "" {
+			project = DefaultProject
+		}
+
+		a.record(ctx, "Deploy", deployId, project, map[string]string{
+			"gitRepo":   redactGitRepo(gitRepo),
+			"name":      name,
+			"envs":      redactEnvs(envs),
+			"labels":    formatLabels(labels),
+			"replicas":  fmt.Sprint(replicas),
+			"resources": fmt.Sprintf("%+v", resources),
+			"priority":  fmt.Sprint(priority),
+		}, err)
+	}()
+
+	return a.next.Deploy(ctx, projectId, gitRepo, name, envs, labels, healthCheck, replicas, resources, priority, idempotencyKey)
+}
+
+// HandleEvent is not audited, it is only called by the message consumers
+func (a *auditMiddleware) HandleEvent(ctx context.Context, event *BuildStep, buildId string) (bool, error) {
+	return a.next.HandleEvent(ctx, event, buildId)
+}
+
+func (a *auditMiddleware) UpdateEnvs(ctx context.Context, deployId string, envs map[string]string, expectedVersion int64, idempotencyKey string) (deploy *Deploy, err error) {
+	defer func() {
+		a.record(ctx, "UpdateEnvs", deployId, a.projectOf(ctx, deployId), map[string]string{
+			"envs":            redactEnvs(envs),
+			"expectedVersion": fmt.Sprint(expectedVersion),
+		}, err)
+	}()
+
+	return a.next.UpdateEnvs(ctx, deployId, envs, expectedVersion, idempotencyKey)
+}
+
+func (a *auditMiddleware) Scale(ctx context.Context, deployId string, replicas int, expectedVersion int64, idempotencyKey string) (deploy *Deploy, err error) {
+	defer func() {
+		a.record(ctx, "Scale", deployId, a.projectOf(ctx, deployId), map[string]string{
+			"replicas":        fmt.Sprint(replicas),
+			"expectedVersion": fmt.Sprint(expectedVersion),
+		}, err)
+	}()
+
+	return a.next.Scale(ctx, deployId, replicas, expectedVersion, idempotencyKey)
+}
+
+func (a *auditMiddleware) Destroy(ctx context.Context, deployId string, expectedVersion int64, idempotencyKey string) (err error) {
+	// the deploy may be gone once destroyed
+	project := a.projectOf(ctx, deployId)
+	defer func() {
+		a.record(ctx, "Destroy", deployId, project, map[string]string{
+			"expectedVersion": fmt.Sprint(expectedVersion),
+		}, err)
+	}()
+
+	return a.next.Destroy(ctx, deployId, expectedVersion, idempotencyKey)
+}
+
+func (a *auditMiddleware) GetDeploy(ctx context.Context, id string) (*Deploy, error) {
+	return a.next.GetDeploy(ctx, id)
+}
+
+func (a *auditMiddleware) ListDeploys(ctx context.Context) ([]*Deploy, error) {
+	return a.next.ListDeploys(ctx)
+}
+
+func (a *auditMiddleware) ListDeadLetters(ctx context.Context, deployId string) ([]*DeadLetter, error) {
+	return a.next.ListDeadLetters(ctx, deployId)
+}
+
+func (a *auditMiddleware) ReplayDeadLetter(ctx context.Context, id string) (err error) {
+	// the dead letter is deleted once replayed
+	deployId := ""
+	if letter, err := a.repository.GetDeadLetter(ctx, id); err == nil {
+		deployId = letter.DeployId
+	}
+	project := ""
+	if deployId != "" {
+		project = a.projectOf(ctx, deployId)
+	}
+
+	defer func() {
+		a.record(ctx, "ReplayDeadLetter", deployId, project, map[string]string{
+			"deadLetterId": id,
+		}, err)
+	}()
+
+	return a.next.ReplayDeadLetter(ctx, id)
+}
+
+func (a *auditMiddleware) AddDomain(ctx context.Context, deployId string, name string) (domain *Domain, err error) {
+	defer func() {
+		a.record(ctx, "AddDomain", deployId, a.projectOf(ctx, deployId), map[string]string{
+			"domain": name,
+		}, err)
+	}()
+
+	return a.next.AddDomain(ctx, deployId, name)
+}
+
+func (a *auditMiddleware) RemoveDomain(ctx context.Context, deployId string, name string) (err error) {
+	defer func() {
+		a.record(ctx, "RemoveDomain", deployId, a.projectOf(ctx, deployId), map[string]string{
+			"domain": name,
+		}, err)
+	}()
+
+	return a.next.RemoveDomain(ctx, deployId, name)
+}
+
+func (a *auditMiddleware) ListDomains(ctx context.Context, deployId string) ([]*Domain, error) {
+	return a.next.ListDomains(ctx, deployId)
+}
+
+func (a *auditMiddleware) CreateProject(ctx context.Context, id string, description string) (project *Project, err error) {
+	defer func() {
+		a.record(ctx, "CreateProject", "", id, map[string]string{
+			"description": description,
+		}, err)
+	}()
+
+	return a.next.CreateProject(ctx, id, description)
+}
+
+func (a *auditMiddleware) GetProject(ctx context.Context, id string) (*Project, error) {
+	return a.next.GetProject(ctx, id)
+}
+
+func (a *auditMiddleware) SetProjectQuota(ctx context.Context, id string, quota *Quota) (project *Project, err error) {
+	defer func() {
+		summary := "default"
+		if quota != nil {
+			summary = fmt.Sprintf("%+v", *quota)
+		}
+
+		a.record(ctx, "SetProjectQuota", "", id, map[string]string{
+			"quota": summary,
+		}, err)
+	}()
+
+	return a.next.SetProjectQuota(ctx, id, quota)
+}
+
+func (a *auditMiddleware) GetQuotaUsage(ctx context.Context, projectId string) (*ProjectQuota, error) {
+	return a.next.GetQuotaUsage(ctx, projectId)
+}
+
+func (a *auditMiddleware) ListProjects(ctx context.Context) ([]*Project, error) {
+	return a.next.ListProjects(ctx)
+}
+
+func (a *auditMiddleware) DeleteProject(ctx context.Context, id string) (err error) {
+	defer func() {
+		a.record(ctx, "DeleteProject", "", id, map[string]string{}, err)
+	}()
+
+	return a.next.DeleteProject(ctx, id)
+}
+
+func (a *auditMiddleware) AddProjectMember(ctx context.Context, id string, subject string) (project *Project, err error) {
+	defer func() {
+		a.record(ctx, "AddProjectMember", "", id, map[string]string{
+			"subject": subject,
+		}, err)
+	}()
+
+	return a.next.AddProjectMember(ctx, id, subject)
+}
+
+func (a *auditMiddleware) RemoveProjectMember(ctx context.Context, id string, subject string) (project *Project, err error) {
+	defer func() {
+		a.record(ctx, "RemoveProjectMember", "", id, map[string]string{
+			"subject": subject,
+		}, err)
+	}()
+
+	return a.next.RemoveProjectMember(ctx, id, subject)
+}
+
+func (a *auditMiddleware) CheckPermission(ctx context.Context, permission string, projectId string, deployId string, labels map[string]string) (*PermissionCheck, error) {
+	return a.next.CheckPermission(ctx, permission, projectId, deployId, labels)
+}
+
+func (a *auditMiddleware) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error) {
+	return a.next.ListAuditEvents(ctx, filter)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+func (b WorkloadBounds) validateReplicas(replicas int) error {
+	if replicas < b.MinReplicas || replicas > b.MaxReplicas {
+		return errors.Wrap(ErrInvalidWorkload, fmt.Sprintf("replicas must be between %d and %d", b.MinReplicas, b.MaxReplicas))
+	}
+
+	return nil
+}
+
+func (b WorkloadBounds) validateResources(resources Resources) error {
+	if err := validateQuantity("cpu request", resources.CpuRequestMillis, b.MinCpuMillis, b.MaxCpuMillis); err != nil {
+		return err
+	}
+	if err := validateQuantity("cpu limit", resources.CpuLimitMillis, b.MinCpuMillis, b.MaxCpuMillis); err != nil {
+		return err
+	}
+	if err := validateQuantity("memory request", resources.MemoryRequestBytes, b.MinMemoryBytes, b.MaxMemoryBytes); err != nil {
+		return err
+	}
+	if err := validateQuantity("memory limit", resources.MemoryLimitBytes, b.MinMemoryBytes, b.MaxMemoryBytes); err != nil {
+		return err
+	}
+
+	if resources.CpuLimitMillis != 0 && resources.CpuRequestMillis > resources.CpuLimitMillis {
+		return errors.Wrap(ErrInvalidWorkload, "cpu request exceeds cpu limit")
+	}
+	if resources.MemoryLimitBytes != 0 && resources.MemoryRequestBytes > resources.MemoryLimitBytes {
+		return errors.Wrap(ErrInvalidWorkload, "memory request exceeds memory limit")
+	}
+
+	return nil
+}
+
+// validateQuantity accepts zero, which leaves the quantity to the scheduler
+func validateQuantity(name string, value int64, min int64, max int64) error {
+	if value == 0 {
+		return nil
+	}
+
+	if value < min || value > max {
+		return errors.Wrap(ErrInvalidWorkload, fmt.Sprintf("%s must be between %d and %d", name, min, max))
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultDomainVerificationInterval = time.Minute
+
+var domainRegexp = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// Resolver looks up the TXT records of the domain challenges, *net.Resolver satisfies it
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+func normalizeDomain(name string) (string, error) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if len(name) > 253 || !domainRegexp.MatchString(name) {
+		return "", errors.Wrapf(ErrInvalidDomain, "%q is not a valid domain name", name)
+	}
+
+	return name, nil
+}
+
+// newToken returns a random hex token, such as the challenge of a domain
+func newToken() string {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(token)
+}
+
+// AddDomain claims a domain for a deploy, which holds it once the challenge is verified.
+// An unverified claim of another deploy is refused until it expires after DomainClaimTTL.
+func (s *basicService) AddDomain(ctx context.Context, deployId string, name string) (*Domain, error) {
+	name, err := normalizeDomain(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.GetDeploy(ctx, deployId); err != nil {
+		return nil, errors.Wrap(err, "Retrieving Deploy")
+	}
+
+	domain := &Domain{
+		Name:      name,
+		DeployId:  deployId,
+		Token:     newToken(),
+		CreatedAt: time.Now(),
+	}
+	if err := s.repository.CreateDomain(ctx, domain); err != nil {
+		return nil, errors.Wrap(err, "Domain creation")
+	}
+
+	return domain, nil
+}
+
+func (s *basicService) RemoveDomain(ctx context.Context, deployId string, name string) error {
+	name, err := normalizeDomain(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.GetDeploy(ctx, deployId); err != nil {
+		return errors.Wrap(err, "Retrieving Deploy")
+	}
+
+	domain, err := s.repository.GetDomain(ctx, name)
+	if err != nil {
+		return errors.Wrap(err, "Retrieving Domain")
+	}
+
+	// Domains of other deploys are hidden rather than forbidden
+	if domain.DeployId != deployId {
+		return errors.Wrap(ErrNotFound, "Retrieving Domain")
+	}
+
+	if err := s.repository.DeleteDomain(ctx, name); err != nil {
+		return errors.Wrap(err, "Domain deletion")
+	}
+
+	if domain.Verified {
+		return rescheduleWorkload(ctx, s.repository, deployId)
+	}
+
+	return nil
+}
+
+func (s *basicService) ListDomains(ctx context.Context, deployId string) ([]*Domain, error) {
+	if _, err := s.GetDeploy(ctx, deployId); err != nil {
+		return nil, errors.Wrap(err, "Retrieving Deploy")
+	}
+
+	return s.repository.ListDomains(ctx, deployId)
+}
+
+// rescheduleWorkload schedules again a running workload to route the current verified domains
+func rescheduleWorkload(ctx context.Context, repository Repository, deployId string) error {
+	deploy, err := repository.GetDeploy(ctx, deployId)
+	if err != nil {
+		return errors.Wrap(err, "Retrieving Deploy")
+	}
+
+	if deploy.Deleted || deploy.Workload.JobId == "" {
+		return nil
+	}
+
+	if err := repository.EnqueueTask(ctx, deployId, newTask(ctx, TaskScheduleWorkload)); err != nil {
+		return errors.Wrap(err, "Enqueuing Workload Schedulation")
+	}
+
+	return nil
+}
+
+// verifiedDomains are the domains routed to the workload of a deploy
+func verifiedDomains(ctx context.Context, repository Repository, deployId string) ([]string, error) {
+	domains, err := repository.ListDomains(ctx, deployId)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, domain := range domains {
+		if domain.Verified {
+			names = append(names, domain.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// DomainVerifier periodically looks up the TXT challenge of the pending domains,
+// routing them to their workload once their ownership is proven
+type DomainVerifier struct {
+	repository Repository
+	resolver   Resolver
+	interval   time.Duration
+	logger     log.Logger
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewDomainVerifier(repository Repository, resolver Resolver, interval time.Duration, logger log.Logger) *DomainVerifier {
+	return &DomainVerifier{
+		repository: repository,
+		resolver:   resolver,
+		interval:   interval,
+		logger:     logger,
+		stop:       make(chan struct{}),
+	}
+}
+
+func (v *DomainVerifier) Run() error {
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-v.stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := v.verifyPending(context.Background()); err != nil {
+			level.Error(v.logger).Log(
+				"during", "verifyPending",
+				"err", err,
+			)
+		}
+	}
+}
+
+func (v *DomainVerifier) Stop() {
+	v.once.Do(func() {
+		close(v.stop)
+	})
+}
+
+func (v *DomainVerifier) verifyPending(ctx context.Context) error {
+	domains, err := v.repository.ListDomains(ctx, "")
+	if err != nil {
+		return errors.Wrap(err, "Listing domains")
+	}
+
+	for _, domain := range domains {
+		// expired claims are not looked up anymore, the domain has to be claimed again
+		if domain.Verified || domain.ClaimExpired(time.Now()) {
+			continue
+		}
+
+		if err := v.verify(ctx, domain); err != nil {
+			level.Warn(v.logger).Log(
+				"msg", "failed to verify domain",
+				"domain", domain.Name,
+				"err", err,
+			)
+		}
+	}
+
+	return nil
+}
+
+func (v *DomainVerifier) verify(ctx context.Context, domain *Domain) error {
+	records, err := v.resolver.LookupTXT(ctx, domain.ChallengeRecord())
+	if err != nil {
+		return errors.Wrap(err, "Looking up challenge")
+	}
+
+	found := false
+	for _, record := range records {
+		if strings.TrimSpace(record) == domain.Token {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	// the token identifies the claim, which may have been taken over since it has been listed
+	if err := v.repository.VerifyDomain(ctx, domain.Name, domain.Token, time.Now()); err != nil {
+		return errors.Wrap(err, "Storing verification")
+	}
+
+	return rescheduleWorkload(ctx, v.repository, domain.DeployId)
+}
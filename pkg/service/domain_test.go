@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"github.com/go-kit/kit/log"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers the TXT lookups from records, names without records are NXDOMAIN
+type fakeResolver struct {
+	records map[string][]string
+}
+
+func (r *fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	records, ok := r.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return records, nil
+}
+
+// domainRepository keeps the domains of a single running deploy in memory,
+// the methods not needed by the verifier are left to the nil Repository
+type domainRepository struct {
+	Repository
+
+	domains  map[string]*Domain
+	deploy   *Deploy
+	enqueued []*Task
+}
+
+func (r *domainRepository) ListDomains(_ context.Context, _ string) ([]*Domain, error) {
+	var domains []*Domain
+	for _, domain := range r.domains {
+		copied := *domain
+		domains = append(domains, &copied)
+	}
+
+	return domains, nil
+}
+
+func (r *domainRepository) VerifyDomain(_ context.Context, name string, token string, verifiedAt time.Time) error {
+	domain, ok := r.domains[name]
+	if !ok || domain.Token != token {
+		return ErrNotFound
+	}
+
+	domain.Verified = true
+	domain.VerifiedAt = verifiedAt
+	return nil
+}
+
+func (r *domainRepository) GetDeploy(_ context.Context, _ string) (*Deploy, error) {
+	return r.deploy, nil
+}
+
+func (r *domainRepository) EnqueueTask(_ context.Context, _ string, task *Task) error {
+	r.enqueued = append(r.enqueued, task)
+	return nil
+}
+
+func newDomainRepository(domains ...*Domain) *domainRepository {
+	repository := &domainRepository{
+		domains: map[string]*Domain{},
+		deploy: &Deploy{
+			Id:       "6138c6d0c3b5a9d2e1b4f7a1",
+			Build:    &Build{},
+			Workload: &Workload{JobId: "workload.project-6138c6d0c3b5a9d2e1b4f7a1"},
+		},
+	}
+	for _, domain := range domains {
+		repository.domains[domain.Name] = domain
+	}
+
+	return repository
+}
+
+func pendingDomain(name string, token string) *Domain {
+	return &Domain{
+		Name:      name,
+		DeployId:  "6138c6d0c3b5a9d2e1b4f7a1",
+		Token:     token,
+		CreatedAt: time.Now(),
+	}
+}
+
+func TestDomainVerifierVerifiesMatchingToken(t *testing.T) {
+	domain := pendingDomain("example.com", "0123456789abcdef")
+	repository := newDomainRepository(domain)
+	resolver := &fakeResolver{records: map[string][]string{
+		domain.ChallengeRecord(): {"unrelated", " 0123456789abcdef "},
+	}}
+
+	verifier := NewDomainVerifier(repository, resolver, time.Minute, log.NewNopLogger())
+	if err := verifier.verify(context.Background(), domain); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	if !repository.domains["example.com"].Verified {
+		t.Error("domain with the matching token not verified")
+	}
+	if len(repository.enqueued) != 1 || repository.enqueued[0].Type != TaskScheduleWorkload {
+		t.Errorf("enqueued %v, want the workload scheduled again with the domain", repository.enqueued)
+	}
+}
+
+func TestDomainVerifierIgnoresWrongToken(t *testing.T) {
+	domain := pendingDomain("example.com", "0123456789abcdef")
+	repository := newDomainRepository(domain)
+	resolver := &fakeResolver{records: map[string][]string{
+		domain.ChallengeRecord(): {"fedcba9876543210"},
+	}}
+
+	verifier := NewDomainVerifier(repository, resolver, time.Minute, log.NewNopLogger())
+	if err := verifier.verify(context.Background(), domain); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	if repository.domains["example.com"].Verified {
+		t.Error("domain verified with a wrong token")
+	}
+	if len(repository.enqueued) != 0 {
+		t.Errorf("enqueued %v for an unverified domain", repository.enqueued)
+	}
+}
+
+func TestDomainVerifierReportsMissingChallenge(t *testing.T) {
+	domain := pendingDomain("example.com", "0123456789abcdef")
+	repository := newDomainRepository(domain)
+
+	verifier := NewDomainVerifier(repository, &fakeResolver{}, time.Minute, log.NewNopLogger())
+	if err := verifier.verify(context.Background(), domain); err == nil {
+		t.Error("NXDOMAIN not reported")
+	}
+
+	if repository.domains["example.com"].Verified {
+		t.Error("domain verified without a challenge record")
+	}
+
+	// a failed lookup does not stop the verification of the other domains
+	if err := verifier.verifyPending(context.Background()); err != nil {
+		t.Errorf("verifyPending: %v", err)
+	}
+}
+
+func TestDomainVerifierSkipsExpiredClaims(t *testing.T) {
+	domain := pendingDomain("example.com", "0123456789abcdef")
+	domain.CreatedAt = time.Now().Add(-DomainClaimTTL)
+	repository := newDomainRepository(domain)
+	resolver := &fakeResolver{records: map[string][]string{
+		domain.ChallengeRecord(): {"0123456789abcdef"},
+	}}
+
+	verifier := NewDomainVerifier(repository, resolver, time.Minute, log.NewNopLogger())
+	if err := verifier.verifyPending(context.Background()); err != nil {
+		t.Fatalf("verifyPending: %v", err)
+	}
+
+	if repository.domains["example.com"].Verified {
+		t.Error("expired claim verified")
+	}
+}
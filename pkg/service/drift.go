@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+const DefaultDriftInterval = time.Minute
+
+// DriftDetector periodically compares the stored workloads with the state of their jobs on the scheduler,
+// recording their health and flagging the deploys whose jobs disappeared
+type DriftDetector struct {
+	repository Repository
+	scheduler  Scheduler
+	interval   time.Duration
+	logger     log.Logger
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewDriftDetector(repository Repository, scheduler Scheduler, interval time.Duration, logger log.Logger) *DriftDetector {
+	return &DriftDetector{
+		repository: repository,
+		scheduler:  scheduler,
+		interval:   interval,
+		logger:     logger,
+		stop:       make(chan struct{}),
+	}
+}
+
+func (d *DriftDetector) Run() error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := d.detect(context.Background()); err != nil {
+			level.Error(d.logger).Log(
+				"during", "detect",
+				"err", err,
+			)
+		}
+	}
+}
+
+func (d *DriftDetector) Stop() {
+	d.once.Do(func() {
+		close(d.stop)
+	})
+}
+
+func (d *DriftDetector) detect(ctx context.Context) error {
+	deploys, err := d.repository.ListDeploy(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "Listing deploys")
+	}
+
+	for _, deploy := range deploys {
+		// Workloads being scheduled are checked once the worker is done with them
+		if deploy.Workload.JobId == "" || len(deploy.Tasks) > 0 {
+			continue
+		}
+
+		if err := d.check(ctx, deploy); err != nil {
+			level.Warn(d.logger).Log(
+				"msg", "failed to check workload",
+				"id", deploy.Id,
+				"err", err,
+			)
+		}
+	}
+
+	return nil
+}
+
+func (d *DriftDetector) check(ctx context.Context, deploy *Deploy) error {
+	status, err := d.scheduler.GetJobStatus(ctx, deploy.Workload.JobId)
+	if err != nil {
+		return errors.Wrap(err, "Getting job status")
+	}
+
+	health := WorkloadHealth{
+		State:     status.State,
+		Restarts:  status.Restarts,
+		Missing:   status.State == JobStateNotFound,
+		Message:   status.Message,
+		CheckedAt: time.Now(),
+	}
+
+	previous := deploy.Workload.Health
+	if health.Missing && !previous.Missing {
+		level.Warn(d.logger).Log(
+			"msg", "workload job disappeared",
+			"id", deploy.Id,
+			"jobId", deploy.Workload.JobId,
+		)
+	}
+	if health.Restarts > previous.Restarts {
+		level.Warn(d.logger).Log(
+			"msg", "workload job restarted",
+			"id", deploy.Id,
+			"jobId", deploy.Workload.JobId,
+			"restarts", health.Restarts,
+		)
+	}
+
+	if err := d.repository.SetWorkloadHealth(ctx, deploy.Id, health); err != nil && !errors.Is(err, ErrNotFound) {
+		return errors.Wrap(err, "Storing workload health")
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package service
+
+import "github.com/pkg/errors"
+
+var (
+	ErrNotFound               = errors.New("not found")
+	ErrAlreadyExists          = errors.New("already exists")
+	ErrConflict               = errors.New("deploy has been modified concurrently")
+	ErrInvalidEvent           = errors.New("invalid build event")
+	ErrDuplicateEvent         = errors.New("build event already handled")
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key already used with a different request")
+	ErrInvalidHealthCheck     = errors.New("invalid health check")
+	ErrInvalidWorkload        = errors.New("invalid workload replicas or resources")
+	ErrInvalidDomain          = errors.New("invalid domain")
+	ErrInvalidProject         = errors.New("invalid project")
+	ErrProjectNotEmpty        = errors.New("project still has deploys")
+	ErrQuotaExceeded          = errors.New("quota exceeded")
+	ErrPermissionDenied       = errors.New("permission denied")
+	ErrInvalidPermission      = errors.New("invalid permission")
+	ErrInvalidAuditFilter     = errors.New("invalid audit filter")
+	ErrRequestInProgress      = errors.New("a request with the same idempotency key is still in progress")
+	ErrShuttingDown           = errors.New("shutting down")
+	ErrNotWatched             = errors.New("build events of the deploy are not being consumed")
+)
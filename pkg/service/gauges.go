@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+const DefaultGaugesInterval = 15 * time.Second
+
+// DeployGauges periodically sets the gauges of the stored deploys: the builds in progress and queued,
+// and the deploys labelled by build_status and workload_status
+type DeployGauges struct {
+	repository       Repository
+	buildsInProgress metrics.Gauge
+	buildsQueued     metrics.Gauge
+	deploys          metrics.Gauge
+	interval         time.Duration
+	logger           log.Logger
+
+	// statuses seen by the previous collection, reset to zero when they have no deploys anymore
+	seen map[[2]string]bool
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewDeployGauges(repository Repository, buildsInProgress metrics.Gauge, buildsQueued metrics.Gauge, deploys metrics.Gauge, interval time.Duration, logger log.Logger) *DeployGauges {
+	return &DeployGauges{
+		repository:       repository,
+		buildsInProgress: buildsInProgress,
+		buildsQueued:     buildsQueued,
+		deploys:          deploys,
+		interval:         interval,
+		logger:           logger,
+		seen:             map[[2]string]bool{},
+		stop:             make(chan struct{}),
+	}
+}
+
+func (g *DeployGauges) Run() error {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := g.collect(context.Background()); err != nil {
+			level.Error(g.logger).Log(
+				"during", "collect",
+				"err", err,
+			)
+		}
+
+		select {
+		case <-g.stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (g *DeployGauges) Stop() {
+	g.once.Do(func() {
+		close(g.stop)
+	})
+}
+
+func (g *DeployGauges) collect(ctx context.Context) error {
+	deploys, err := g.repository.ListDeploy(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "Listing deploys")
+	}
+
+	inProgress, queued := 0, 0
+	counts := map[[2]string]int{}
+	for _, deploy := range deploys {
+		switch deploy.Build.Status {
+		case StatusLoading:
+			inProgress++
+		case StatusQueued:
+			queued++
+		}
+
+		counts[[2]string{deploy.Build.Status.ToString(), deploy.Workload.Status.ToString()}]++
+	}
+
+	g.buildsInProgress.Set(float64(inProgress))
+	g.buildsQueued.Set(float64(queued))
+
+	for statuses := range g.seen {
+		if _, ok := counts[statuses]; !ok {
+			g.deploys.With("build_status", statuses[0], "workload_status", statuses[1]).Set(0)
+		}
+	}
+	g.seen = map[[2]string]bool{}
+	for statuses, count := range counts {
+		g.deploys.With("build_status", statuses[0], "workload_status", statuses[1]).Set(float64(count))
+		g.seen[statuses] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// WorkloadReadinessTimeout is how long a workload can take to pass its health check before being declared unhealthy
+	WorkloadReadinessTimeout = 5 * time.Minute
+	// MaxHealthCheckTimeout bounds a single probe, which runs in the task loop of the worker
+	// and must be over well before WorkerTaskLease
+	MaxHealthCheckTimeout = 10 * time.Second
+	probeInterval         = 5 * time.Second
+)
+
+// validateHealthCheck fills the defaults of a health check declared by the user
+func validateHealthCheck(healthCheck *HealthCheck) (*HealthCheck, error) {
+	if healthCheck == nil {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(healthCheck.Path, "/") {
+		return nil, errors.Wrap(ErrInvalidHealthCheck, "path must start with /")
+	}
+
+	validated := *healthCheck
+	if validated.ExpectedStatus == 0 {
+		validated.ExpectedStatus = DefaultHealthCheckStatus
+	}
+	if validated.ExpectedStatus < 100 || validated.ExpectedStatus > 599 {
+		return nil, errors.Wrap(ErrInvalidHealthCheck, fmt.Sprintf("unknown status %d", validated.ExpectedStatus))
+	}
+
+	if validated.Timeout == 0 {
+		validated.Timeout = DefaultHealthCheckTimeout
+	}
+	if validated.Timeout < 0 || validated.Timeout > MaxHealthCheckTimeout {
+		return nil, errors.Wrap(ErrInvalidHealthCheck, fmt.Sprintf("timeout %s out of range", validated.Timeout))
+	}
+
+	return &validated, nil
+}
+
+// probe performs the health check of the workload, failing unless it answers with the expected status.
+// Health checks stored before MaxHealthCheckTimeout may have longer timeouts, which are capped.
+func probe(ctx context.Context, client *http.Client, url string, healthCheck *HealthCheck) error {
+	timeout := healthCheck.Timeout
+	if timeout > MaxHealthCheckTimeout {
+		timeout = MaxHealthCheckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(url, "/")+healthCheck.Path, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != healthCheck.ExpectedStatus {
+		return errors.Errorf("health check answered %d instead of %d", res.StatusCode, healthCheck.ExpectedStatus)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const (
+	// IdempotencyKeyTTL is how long the result of a completed request is kept
+	IdempotencyKeyTTL = 24 * time.Hour
+	// IdempotencyKeyLease is how long a request in progress holds its key: a reservation which is neither
+	// completed nor released by then, e.g. because the replica crashed, is taken over by the next retry
+	IdempotencyKeyLease = time.Minute
+)
+
+func hashRequest(request interface{}) (string, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// idempotent executes fn at most once for every (key, method) pair while the key is alive.
+// A repeated request with the same payload gets the result of the first execution, while a
+// repeated request with a different payload is rejected. The key is completed or released on a context of
+// its own, so that a request cancelled by the caller once fn is done does not leave the key reserved.
+func (s *basicService) idempotent(ctx context.Context, key string, method string, request interface{}, fn func() (string, error)) (string, error) {
+	if key == "" {
+		return fn()
+	}
+
+	requestHash, err := hashRequest(request)
+	if err != nil {
+		return "", errors.Wrap(err, "Hashing request")
+	}
+
+	if err := s.repository.CreateIdempotencyKey(ctx, &IdempotencyKey{
+		Key:         key,
+		Method:      method,
+		RequestHash: requestHash,
+		ExpiresAt:   time.Now().Add(IdempotencyKeyLease),
+	}); err != nil {
+		if !errors.Is(err, ErrAlreadyExists) {
+			return "", errors.Wrap(err, "Reserving idempotency key")
+		}
+
+		existing, err := s.repository.GetIdempotencyKey(ctx, key, method)
+		if err != nil {
+			return "", errors.Wrap(err, "Retrieving idempotency key")
+		}
+
+		if existing.RequestHash != requestHash {
+			return "", ErrIdempotencyKeyMismatch
+		}
+
+		if !existing.Completed {
+			return "", ErrRequestInProgress
+		}
+
+		return existing.Result, nil
+	}
+
+	result, err := fn()
+	if err != nil {
+		if err := s.repository.DeleteIdempotencyKey(context.Background(), key, method); err != nil {
+			return "", errors.Wrap(err, "Releasing idempotency key")
+		}
+
+		return "", err
+	}
+
+	if err := s.repository.CompleteIdempotencyKey(context.Background(), key, method, result); err != nil {
+		return "", errors.Wrap(err, "Storing idempotency key result")
+	}
+
+	return result, nil
+}
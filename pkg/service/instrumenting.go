@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"github.com/go-kit/kit/metrics"
+	"time"
+)
+
+// InstrumentingMiddleware counts the calls and the errors of every method and observes their latency in seconds,
+// the metrics are labelled by method. The duration of the build steps is observed in seconds labelled by step.
+func InstrumentingMiddleware(requestCount metrics.Counter, errorCount metrics.Counter, requestLatency metrics.Histogram, buildStepDuration metrics.Histogram) Middleware {
+	return func(service Service) Service {
+		return &instrumentingMiddleware{
+			next:              service,
+			requestCount:      requestCount,
+			errorCount:        errorCount,
+			requestLatency:    requestLatency,
+			buildStepDuration: buildStepDuration,
+		}
+	}
+}
+
+type instrumentingMiddleware struct {
+	next              Service
+	requestCount      metrics.Counter
+	errorCount        metrics.Counter
+	requestLatency    metrics.Histogram
+	buildStepDuration metrics.Histogram
+}
+
+func (i *instrumentingMiddleware) observe(method string, begin time.Time, err error) {
+	i.requestCount.With("method", method).Add(1)
+	if err != nil {
+		i.errorCount.With("method", method).Add(1)
+	}
+	i.requestLatency.With("method", method).Observe(time.Since(begin).Seconds())
+}
+
+// observeBuildStep observes the time between the event and the step recorded before it. The first step of a build
+// and legacy events, which carry no occurrence time, are not observed, while redelivered events may be observed again.
+func (i *instrumentingMiddleware) observeBuildStep(ctx context.Context, event *BuildStep, buildId string) {
+	if event.EventId == "" || event.OccurredAt.IsZero() {
+		return
+	}
+
+	deploy, err := i.next.GetDeploy(ctx, buildId)
+	if err != nil {
+		return
+	}
+
+	var previous *BuildStep
+	for _, step := range deploy.Build.Steps {
+		if step.EventId == event.EventId {
+			break
+		}
+		previous = step
+	}
+	if previous == nil || previous.OccurredAt.IsZero() || previous.OccurredAt.After(event.OccurredAt) {
+		return
+	}
+
+	i.buildStepDuration.With("step", event.Step.ToString()).Observe(event.OccurredAt.Sub(previous.OccurredAt).Seconds())
+}
+
+func (i *instrumentingMiddleware) Deploy(ctx context.Context, projectId string, gitRepo string, name string, envs map[string]string, labels map[string]string, healthCheck *HealthCheck, replicas int, resources Resources, priority int, idempotencyKey string) (deployId string, err error) {
+	defer func(begin time.Time) {
+		i.observe("Deploy", begin, err)
+	}(time.Now())
+
+	return i.next.Deploy(ctx, projectId, gitRepo, name, envs, labels, healthCheck, replicas, resources, priority, idempotencyKey)
+}
+
+// HandleEvent also observes how long the step of the event took since the previous step of the build
+func (i *instrumentingMiddleware) HandleEvent(ctx context.Context, event *BuildStep, buildId string) (isDone bool, err error) {
+	defer func(begin time.Time) {
+		i.observe("HandleEvent", begin, err)
+	}(time.Now())
+
+	isDone, err = i.next.HandleEvent(ctx, event, buildId)
+	if err == nil {
+		i.observeBuildStep(ctx, event, buildId)
+	}
+
+	return isDone, err
+}
+
+func (i *instrumentingMiddleware) UpdateEnvs(ctx context.Context, deployId string, envs map[string]string, expectedVersion int64, idempotencyKey string) (deploy *Deploy, err error) {
+	defer func(begin time.Time) {
+		i.observe("UpdateEnvs", begin, err)
+	}(time.Now())
+
+	return i.next.UpdateEnvs(ctx, deployId, envs, expectedVersion, idempotencyKey)
+}
+
+func (i *instrumentingMiddleware) Scale(ctx context.Context, deployId string, replicas int, expectedVersion int64, idempotencyKey string) (deploy *Deploy, err error) {
+	defer func(begin time.Time) {
+		i.observe("Scale", begin, err)
+	}(time.Now())
+
+	return i.next.Scale(ctx, deployId, replicas, expectedVersion, idempotencyKey)
+}
+
+func (i *instrumentingMiddleware) Destroy(ctx context.Context, deployId string, expectedVersion int64, idempotencyKey string) (err error) {
+	defer func(begin time.Time) {
+		i.observe("Destroy", begin, err)
+	}(time.Now())
+
+	return i.next.Destroy(ctx, deployId, expectedVersion, idempotencyKey)
+}
+
+func (i *instrumentingMiddleware) GetDeploy(ctx context.Context, id string) (deploy *Deploy, err error) {
+	defer func(begin time.Time) {
+		i.observe("GetDeploy", begin, err)
+	}(time.Now())
+
+	return i.next.GetDeploy(ctx, id)
+}
+
+func (i *instrumentingMiddleware) ListDeploys(ctx context.Context) (deploys []*Deploy, err error) {
+	defer func(begin time.Time) {
+		i.observe("ListDeploys", begin, err)
+	}(time.Now())
+
+	return i.next.ListDeploys(ctx)
+}
+
+func (i *instrumentingMiddleware) ListDeadLetters(ctx context.Context, deployId string) (letters []*DeadLetter, err error) {
+	defer func(begin time.Time) {
+		i.observe("ListDeadLetters", begin, err)
+	}(time.Now())
+
+	return i.next.ListDeadLetters(ctx, deployId)
+}
+
+func (i *instrumentingMiddleware) ReplayDeadLetter(ctx context.Context, id string) (err error) {
+	defer func(begin time.Time) {
+		i.observe("ReplayDeadLetter", begin, err)
+	}(time.Now())
+
+	return i.next.ReplayDeadLetter(ctx, id)
+}
+
+func (i *instrumentingMiddleware) AddDomain(ctx context.Context, deployId string, name string) (domain *Domain, err error) {
+	defer func(begin time.Time) {
+		i.observe("AddDomain", begin, err)
+	}(time.Now())
+
+	return i.next.AddDomain(ctx, deployId, name)
+}
+
+func (i *instrumentingMiddleware) RemoveDomain(ctx context.Context, deployId string, name string) (err error) {
+	defer func(begin time.Time) {
+		i.observe("RemoveDomain", begin, err)
+	}(time.Now())
+
+	return i.next.RemoveDomain(ctx, deployId, name)
+}
+
+func (i *instrumentingMiddleware) ListDomains(ctx context.Context, deployId string) (domains []*Domain, err error) {
+	defer func(begin time.Time) {
+		i.observe("ListDomains", begin, err)
+	}(time.Now())
+
+	return i.next.ListDomains(ctx, deployId)
+}
+
+func (i *instrumentingMiddleware) CheckPermission(ctx context.Context, permission string, projectId string, deployId string, labels map[string]string) (check *PermissionCheck, err error) {
+	defer func(begin time.Time) {
+		i.observe("CheckPermission", begin, err)
+	}(time.Now())
+
+	return i.next.CheckPermission(ctx, permission, projectId, deployId, labels)
+}
+
+func (i *instrumentingMiddleware) CreateProject(ctx context.Context, id string, description string) (project *Project, err error) {
+	defer func(begin time.Time) {
+		i.observe("CreateProject", begin, err)
+	}(time.Now())
+
+	return i.next.CreateProject(ctx, id, description)
+}
+
+func (i *instrumentingMiddleware) GetProject(ctx context.Context, id string) (project *Project, err error) {
+	defer func(begin time.Time) {
+		i.observe("GetProject", begin, err)
+	}(time.Now())
+
+	return i.next.GetProject(ctx, id)
+}
+
+func (i *instrumentingMiddleware) ListProjects(ctx context.Context) (projects []*Project, err error) {
+	defer func(begin time.Time) {
+		i.observe("ListProjects", begin, err)
+	}(time.Now())
+
+	return i.next.ListProjects(ctx)
+}
+
+func (i *instrumentingMiddleware) DeleteProject(ctx context.Context, id string) (err error) {
+	defer func(begin time.Time) {
+		i.observe("DeleteProject", begin, err)
+	}(time.Now())
+
+	return i.next.DeleteProject(ctx, id)
+}
+
+func (i *instrumentingMiddleware) AddProjectMember(ctx context.Context, id string, subject string) (project *Project, err error) {
+	defer func(begin time.Time) {
+		i.observe("AddProjectMember", begin, err)
+	}(time.Now())
+
+	return i.next.AddProjectMember(ctx, id, subject)
+}
+
+func (i *instrumentingMiddleware) RemoveProjectMember(ctx context.Context, id string, subject string) (project *Project, err error) {
+	defer func(begin time.Time) {
+		i.observe("RemoveProjectMember", begin, err)
+	}(time.Now())
+
+	return i.next.RemoveProjectMember(ctx, id, subject)
+}
+
+func (i *instrumentingMiddleware) SetProjectQuota(ctx context.Context, id string, quota *Quota) (project *Project, err error) {
+	defer func(begin time.Time) {
+		i.observe("SetProjectQuota", begin, err)
+	}(time.Now())
+
+	return i.next.SetProjectQuota(ctx, id, quota)
+}
+
+func (i *instrumentingMiddleware) GetQuotaUsage(ctx context.Context, projectId string) (quota *ProjectQuota, err error) {
+	defer func(begin time.Time) {
+		i.observe("GetQuotaUsage", begin, err)
+	}(time.Now())
+
+	return i.next.GetQuotaUsage(ctx, projectId)
+}
+
+func (i *instrumentingMiddleware) ListAuditEvents(ctx context.Context, filter AuditFilter) (events []*AuditEvent, err error) {
+	defer func(begin time.Time) {
+		i.observe("ListAuditEvents", begin, err)
+	}(time.Now())
+
+	return i.next.ListAuditEvents(ctx, filter)
+}
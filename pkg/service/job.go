@@ -1,7 +1,10 @@
 package service
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strings"
 )
 
 type JobId string
@@ -10,10 +13,23 @@ const (
 	JobTypeImageBuild = "imagebuild"
 	JobTypeWorkload   = "workload"
 	NameImageBuilder  = "cobold"
+
+	// maxJobNameLength is the limit of the kubernetes label values, job names are copied in the job-name label
+	maxJobNameLength  = 63
+	jobNameHashLength = 10
 )
 
-func (id JobId) NameImageBuild() string {
-	return fmt.Sprintf("%s.%s", JobTypeImageBuild, id)
+// NameImageBuild names the build buildId of the workload. The names over maxJobNameLength are cut,
+// and end with a hash of the whole name to stay unique among the builds of the workload.
+func (id JobId) NameImageBuild(buildId string) string {
+	name := fmt.Sprintf("%s.%s.%s", JobTypeImageBuild, id, buildId)
+	if len(name) <= maxJobNameLength {
+		return name
+	}
+
+	hash := sha256.Sum256([]byte(name))
+	prefix := strings.TrimRight(name[:maxJobNameLength-jobNameHashLength-1], ".-")
+	return prefix + "-" + hex.EncodeToString(hash[:])[:jobNameHashLength]
 }
 
 func (id JobId) NameWorkload() string {
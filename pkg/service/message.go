@@ -1,6 +1,21 @@
 package service
 
+import "context"
+
+// BuildEventHandler handles a build event of a deploy, routed by its workload id, reporting whether the build is over.
+// Events are acknowledged only when the handler succeeds.
+type BuildEventHandler func(ctx context.Context, workloadId string, event *BuildStep) (bool, error)
+
+// DeadLetterHandler handles events which could not be processed
+type DeadLetterHandler func(ctx context.Context, letter *DeadLetter) error
+
 type Message interface {
 	Init() error
-	ConsumeBuildEvents(id string) (<-chan *BuildStep, func() error, error)
+	// ConsumeBuildEvents passes to handler the build events of every watched deploy
+	ConsumeBuildEvents(handler BuildEventHandler) error
+	// WatchBuild starts routing the build events of a workload until its build is over or clear is called
+	WatchBuild(workloadId string) (func() error, error)
+	ConsumeDeadLetters(handler DeadLetterHandler) error
+	// ReplayDeadLetter publishes again the event with the workload id it was routed with
+	ReplayDeadLetter(letter *DeadLetter) error
 }
@@ -21,46 +21,84 @@ type loggingMiddlware struct {
 	logger log.Logger
 }
 
-func (l *loggingMiddlware) Deploy(ctx context.Context, gitRepo string, name string, envs map[string]string) (deployId string, err error) {
+func (l *loggingMiddlware) Deploy(ctx context.Context, projectId string, gitRepo string, name string, envs map[string]string, labels map[string]string, healthCheck *HealthCheck, replicas int, resources Resources, priority int, idempotencyKey string) (deployId string, err error) {
 	defer func() {
 		l.logger.Log(
 			"method", "Deploy",
+			"projectId", projectId,
 			"gitRepo", gitRepo,
 			"name", name,
 			"envs", envs,
+			"labels", labels,
+			"healthCheck", healthCheck,
+			"replicas", replicas,
+			"resources", resources,
+			"priority", priority,
+			"idempotencyKey", idempotencyKey,
 			"deployId", deployId,
 			"err", err,
 		)
 	}()
 
-	return l.next.Deploy(ctx, gitRepo, name, envs)
+	return l.next.Deploy(ctx, projectId, gitRepo, name, envs, labels, healthCheck, replicas, resources, priority, idempotencyKey)
 }
 
-func (l *loggingMiddlware) HandleEvent(ctx context.Context, event *BuildStep, buildId string, envs map[string]string) (isDone bool, err error) {
+func (l *loggingMiddlware) HandleEvent(ctx context.Context, event *BuildStep, buildId string) (isDone bool, err error) {
 	defer func() {
 		l.logger.Log(
 			"method", "HandleEvent",
 			"event", event,
 			"buildId", buildId,
-			"envs", envs,
 			"isDone", isDone,
 			"err", err,
 		)
 	}()
 
-	return l.next.HandleEvent(ctx, event, buildId, envs)
+	return l.next.HandleEvent(ctx, event, buildId)
+}
+
+func (l *loggingMiddlware) UpdateEnvs(ctx context.Context, deployId string, envs map[string]string, expectedVersion int64, idempotencyKey string) (deploy *Deploy, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "UpdateEnvs",
+			"deployId", deployId,
+			"envs", envs,
+			"expectedVersion", expectedVersion,
+			"idempotencyKey", idempotencyKey,
+			"err", err,
+		)
+	}()
+
+	return l.next.UpdateEnvs(ctx, deployId, envs, expectedVersion, idempotencyKey)
+}
+
+func (l *loggingMiddlware) Scale(ctx context.Context, deployId string, replicas int, expectedVersion int64, idempotencyKey string) (deploy *Deploy, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "Scale",
+			"deployId", deployId,
+			"replicas", replicas,
+			"expectedVersion", expectedVersion,
+			"idempotencyKey", idempotencyKey,
+			"err", err,
+		)
+	}()
+
+	return l.next.Scale(ctx, deployId, replicas, expectedVersion, idempotencyKey)
 }
 
-func (l *loggingMiddlware) Destroy(ctx context.Context, deployId string) (err error) {
+func (l *loggingMiddlware) Destroy(ctx context.Context, deployId string, expectedVersion int64, idempotencyKey string) (err error) {
 	defer func() {
 		l.logger.Log(
 			"method", "Destroy",
 			"deployId", deployId,
+			"expectedVersion", expectedVersion,
+			"idempotencyKey", idempotencyKey,
 			"err", err,
 		)
 	}()
 
-	return l.next.Destroy(ctx, deployId)
+	return l.next.Destroy(ctx, deployId, expectedVersion, idempotencyKey)
 }
 
 func (l *loggingMiddlware) GetDeploy(ctx context.Context, id string) (deploy *Deploy, err error) {
@@ -85,3 +123,195 @@ func (l *loggingMiddlware) ListDeploys(ctx context.Context) (deploys []*Deploy,
 
 	return l.next.ListDeploys(ctx)
 }
+
+func (l *loggingMiddlware) ListDeadLetters(ctx context.Context, deployId string) (letters []*DeadLetter, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "ListDeadLetters",
+			"deployId", deployId,
+			"err", err,
+		)
+	}()
+
+	return l.next.ListDeadLetters(ctx, deployId)
+}
+
+func (l *loggingMiddlware) ReplayDeadLetter(ctx context.Context, id string) (err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "ReplayDeadLetter",
+			"id", id,
+			"err", err,
+		)
+	}()
+
+	return l.next.ReplayDeadLetter(ctx, id)
+}
+
+func (l *loggingMiddlware) AddDomain(ctx context.Context, deployId string, name string) (domain *Domain, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "AddDomain",
+			"deployId", deployId,
+			"name", name,
+			"err", err,
+		)
+	}()
+
+	return l.next.AddDomain(ctx, deployId, name)
+}
+
+func (l *loggingMiddlware) RemoveDomain(ctx context.Context, deployId string, name string) (err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "RemoveDomain",
+			"deployId", deployId,
+			"name", name,
+			"err", err,
+		)
+	}()
+
+	return l.next.RemoveDomain(ctx, deployId, name)
+}
+
+func (l *loggingMiddlware) ListDomains(ctx context.Context, deployId string) (domains []*Domain, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "ListDomains",
+			"deployId", deployId,
+			"domains", len(domains),
+			"err", err,
+		)
+	}()
+
+	return l.next.ListDomains(ctx, deployId)
+}
+
+func (l *loggingMiddlware) CheckPermission(ctx context.Context, permission string, projectId string, deployId string, labels map[string]string) (check *PermissionCheck, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "CheckPermission",
+			"permission", permission,
+			"projectId", projectId,
+			"deployId", deployId,
+			"labels", labels,
+			"check", check,
+			"err", err,
+		)
+	}()
+
+	return l.next.CheckPermission(ctx, permission, projectId, deployId, labels)
+}
+
+func (l *loggingMiddlware) CreateProject(ctx context.Context, id string, description string) (project *Project, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "CreateProject",
+			"id", id,
+			"description", description,
+			"err", err,
+		)
+	}()
+
+	return l.next.CreateProject(ctx, id, description)
+}
+
+func (l *loggingMiddlware) GetProject(ctx context.Context, id string) (project *Project, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "GetProject",
+			"id", id,
+			"err", err,
+		)
+	}()
+
+	return l.next.GetProject(ctx, id)
+}
+
+func (l *loggingMiddlware) ListProjects(ctx context.Context) (projects []*Project, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "ListProjects",
+			"projects", len(projects),
+			"err", err,
+		)
+	}()
+
+	return l.next.ListProjects(ctx)
+}
+
+func (l *loggingMiddlware) DeleteProject(ctx context.Context, id string) (err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "DeleteProject",
+			"id", id,
+			"err", err,
+		)
+	}()
+
+	return l.next.DeleteProject(ctx, id)
+}
+
+func (l *loggingMiddlware) AddProjectMember(ctx context.Context, id string, subject string) (project *Project, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "AddProjectMember",
+			"id", id,
+			"subject", subject,
+			"err", err,
+		)
+	}()
+
+	return l.next.AddProjectMember(ctx, id, subject)
+}
+
+func (l *loggingMiddlware) RemoveProjectMember(ctx context.Context, id string, subject string) (project *Project, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "RemoveProjectMember",
+			"id", id,
+			"subject", subject,
+			"err", err,
+		)
+	}()
+
+	return l.next.RemoveProjectMember(ctx, id, subject)
+}
+
+func (l *loggingMiddlware) SetProjectQuota(ctx context.Context, id string, quota *Quota) (project *Project, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "SetProjectQuota",
+			"id", id,
+			"quota", quota,
+			"err", err,
+		)
+	}()
+
+	return l.next.SetProjectQuota(ctx, id, quota)
+}
+
+func (l *loggingMiddlware) GetQuotaUsage(ctx context.Context, projectId string) (quota *ProjectQuota, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "GetQuotaUsage",
+			"projectId", projectId,
+			"err", err,
+		)
+	}()
+
+	return l.next.GetQuotaUsage(ctx, projectId)
+}
+
+func (l *loggingMiddlware) ListAuditEvents(ctx context.Context, filter AuditFilter) (events []*AuditEvent, err error) {
+	defer func() {
+		l.logger.Log(
+			"method", "ListAuditEvents",
+			"filter", filter,
+			"events", len(events),
+			"err", err,
+		)
+	}()
+
+	return l.next.ListAuditEvents(ctx, filter)
+}
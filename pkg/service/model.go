@@ -1,15 +1,37 @@
 package service
 
+import (
+	"strings"
+	"time"
+)
+
 type Status byte
 
 const (
 	StatusError     Status = 1
 	StatusLoading   Status = 2
 	StatusCompleted Status = 3
+	// StatusQueued is a build waiting for BuildAdmission to start it
+	StatusQueued Status = 4
 )
 
 func (s Status) IsValid() bool {
-	return s == StatusError || s == StatusLoading || s == StatusCompleted
+	return s == StatusError || s == StatusLoading || s == StatusCompleted || s == StatusQueued
+}
+
+func (s Status) ToString() string {
+	switch s {
+	case StatusError:
+		return "error"
+	case StatusLoading:
+		return "loading"
+	case StatusCompleted:
+		return "completed"
+	case StatusQueued:
+		return "queued"
+	default:
+		return "unknown"
+	}
 }
 
 type Step byte
@@ -44,6 +66,12 @@ func (s Step) ToString() string {
 type BuildStep struct {
 	Step  Step
 	Error string
+
+	// Only set by events in the versioned format, EventId is empty for legacy events
+	EventId    string
+	Attempt    int
+	OccurredAt time.Time
+	Details    map[string]string
 }
 
 type Build struct {
@@ -52,6 +80,16 @@ type Build struct {
 	ImageName string
 	Status    Status
 	Steps     []*BuildStep
+
+	// Priority orders the queued builds, higher ones are started first
+	Priority int
+	QueuedAt time.Time
+	// StartedAt is when the build has been admitted, it is missing for the builds started before the queue
+	StartedAt time.Time
+	// TraceContext links the admission of a queued build to the trace of the Deploy call
+	TraceContext map[string]string
+	// QueuePosition is the 1-based position of a queued build, it is not stored but filled by GetDeploy
+	QueuePosition int
 }
 
 type Workload struct {
@@ -59,12 +97,272 @@ type Workload struct {
 	JobName string
 	Envs    map[string]string
 	Url     string
+	Status  WorkloadStatus
+	Health  WorkloadHealth
+
+	Replicas  int
+	Resources Resources
+}
+
+// Resources of every replica of a workload, zero values are left to the defaults of the scheduler
+type Resources struct {
+	CpuRequestMillis   int64
+	CpuLimitMillis     int64
+	MemoryRequestBytes int64
+	MemoryLimitBytes   int64
+}
+
+// WorkloadBounds are the replicas and resources the users are allowed to request
+type WorkloadBounds struct {
+	MinReplicas    int
+	MaxReplicas    int
+	MinCpuMillis   int64
+	MaxCpuMillis   int64
+	MinMemoryBytes int64
+	MaxMemoryBytes int64
+}
+
+const DefaultReplicas = 1
+
+func DefaultWorkloadBounds() WorkloadBounds {
+	return WorkloadBounds{
+		MinReplicas:    0,
+		MaxReplicas:    10,
+		MinCpuMillis:   10,
+		MaxCpuMillis:   4000,
+		MinMemoryBytes: 16 << 20,
+		MaxMemoryBytes: 8 << 30,
+	}
+}
+
+type WorkloadStatus byte
+
+const (
+	WorkloadStatusUnknown   WorkloadStatus = 0
+	WorkloadStatusStarting  WorkloadStatus = 1
+	WorkloadStatusReady     WorkloadStatus = 2
+	WorkloadStatusUnhealthy WorkloadStatus = 3
+)
+
+func (s WorkloadStatus) ToString() string {
+	switch s {
+	case WorkloadStatusStarting:
+		return "starting"
+	case WorkloadStatusReady:
+		return "ready"
+	case WorkloadStatusUnhealthy:
+		return "unhealthy"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	DefaultHealthCheckStatus  = 200
+	DefaultHealthCheckTimeout = 5 * time.Second
+)
+
+// HealthCheck is the HTTP request which tells whether a workload is ready to serve
+type HealthCheck struct {
+	Path           string
+	ExpectedStatus int
+	Timeout        time.Duration
+}
+
+type JobState byte
+
+const (
+	JobStateUnknown   JobState = 0
+	JobStatePending   JobState = 1
+	JobStateRunning   JobState = 2
+	JobStateSucceeded JobState = 3
+	JobStateFailed    JobState = 4
+	JobStateNotFound  JobState = 5
+)
+
+func (s JobState) ToString() string {
+	switch s {
+	case JobStatePending:
+		return "pending"
+	case JobStateRunning:
+		return "running"
+	case JobStateSucceeded:
+		return "succeeded"
+	case JobStateFailed:
+		return "failed"
+	case JobStateNotFound:
+		return "not found"
+	default:
+		return "unknown"
+	}
+}
+
+// JobStatus is the state of a job as seen by the scheduler
+type JobStatus struct {
+	State    JobState
+	Restarts int
+	Message  string
+}
+
+// WorkloadHealth is the last state of the workload job observed by the drift detector
+type WorkloadHealth struct {
+	State     JobState
+	Restarts  int
+	Missing   bool
+	Message   string
+	CheckedAt time.Time
+}
+
+type TaskType byte
+
+const (
+	TaskScheduleImageBuild TaskType = 1
+	TaskScheduleWorkload   TaskType = 2
+	TaskUnScheduleJobs     TaskType = 3
+	TaskProbeWorkload      TaskType = 4
+)
+
+func (t TaskType) ToString() string {
+	switch t {
+	case TaskScheduleImageBuild:
+		return "schedule_image_build"
+	case TaskScheduleWorkload:
+		return "schedule_workload"
+	case TaskUnScheduleJobs:
+		return "unschedule_jobs"
+	case TaskProbeWorkload:
+		return "probe_workload"
+	default:
+		return "unknown"
+	}
+}
+
+// Task is a pending side effect on the scheduler, persisted in the same document of its deploy
+// so that it is written atomically with the state change which originated it.
+type Task struct {
+	Id            string
+	Type          TaskType
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	// TraceContext links the task to the trace of the call which created it
+	TraceContext map[string]string
 }
 
 type Deploy struct {
+	Id string
+	// ProjectId is empty for the deploys created before projects, which belong to DefaultProject
+	ProjectId string
+	// Name is unique in the project
+	Name    string
+	GitRepo string
+	// Labels scope the permissions of the callers on the deploy, e.g. env=production
+	Labels      map[string]string
+	HealthCheck *HealthCheck
+	Build       *Build
+	Workload    *Workload
+	Deleted     bool
+	Tasks       []*Task
+	// Version is incremented on every change visible to the callers, and it is used to detect concurrent modifications.
+	// The processing of the tasks and the health checks of the workload leave it unchanged.
+	Version int64
+}
+
+// Project returns the project the deploy belongs to
+func (d *Deploy) Project() string {
+	if d.ProjectId == "" {
+		return DefaultProject
+	}
+
+	return d.ProjectId
+}
+
+// WorkloadId identifies the jobs of the deploy on the scheduler and the events of its build on the message bus
+func (d *Deploy) WorkloadId() string {
+	// deploys created before projects keep the workload id their jobs already have
+	if d.ProjectId == "" {
+		return d.Id
+	}
+
+	return d.ProjectId + "-" + d.Id
+}
+
+// DeployIdOfWorkload returns the deploy of a workload id, deploy ids never contain "-"
+func DeployIdOfWorkload(workloadId string) string {
+	return workloadId[strings.LastIndex(workloadId, "-")+1:]
+}
+
+type IdempotencyKey struct {
+	Key         string
+	Method      string
+	RequestHash string
+	Result      string
+	Completed   bool
+	ExpiresAt   time.Time
+}
+
+// DeadLetter is a build event which has been rejected, because it is malformed or
+// because its handling kept failing
+type DeadLetter struct {
 	Id       string
-	Name     string
-	GitRepo  string
-	Build    *Build
-	Workload *Workload
+	DeployId string
+	// WorkloadId is the id the event has been routed with
+	WorkloadId string
+	Body       []byte
+	Reason     string
+	Attempts   int
+	DeadAt     time.Time
+}
+
+const (
+	// DomainChallengePrefix is prepended to a domain to get the name of the TXT record proving its ownership
+	DomainChallengePrefix = "_scarlet-fairy-challenge"
+	// DomainClaimTTL is how long a domain can stay unverified, after which it can be claimed by another deploy
+	DomainClaimTTL = 72 * time.Hour
+)
+
+// Domain is a custom domain attached to a deploy, routed to its workload once verified
+type Domain struct {
+	Name       string
+	DeployId   string
+	Token      string
+	Verified   bool
+	CreatedAt  time.Time
+	VerifiedAt time.Time
+}
+
+// ChallengeRecord is the TXT record which must contain the Token to verify the domain
+func (d Domain) ChallengeRecord() string {
+	return DomainChallengePrefix + "." + d.Name
+}
+
+// ClaimExpired tells whether the domain has not been verified within DomainClaimTTL
+func (d Domain) ClaimExpired(now time.Time) bool {
+	return !d.Verified && now.Sub(d.CreatedAt) >= DomainClaimTTL
+}
+
+// DefaultProject holds the deploys created without a project, it has no members and every caller can see it,
+// as every caller could see those deploys before projects
+const DefaultProject = "default"
+
+// Project groups deploys, which are visible only to its members
+type Project struct {
+	// Id is the name of the project, it is part of the workload ids so it is a short lowercase slug
+	Id          string
+	Description string
+	Members     []string
+	// Quota overrides the default quota of the projects when set
+	Quota     *Quota
+	CreatedAt time.Time
+}
+
+func (p *Project) HasMember(subject string) bool {
+	for _, member := range p.Members {
+		if member == subject {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,37 @@
+package service
+
+import "context"
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject string
+	Roles   []string
+	// how the caller has been authenticated, e.g. token or jwt
+	AuthMethod string
+}
+
+type principalContextKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the authenticated caller
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller, if any, of the request ctx belongs to
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// AdminRole is granted the visibility of every project, regardless of its members
+const AdminRole = "admin"
+
+func (p *Principal) IsAdmin() bool {
+	for _, role := range p.Roles {
+		if role == AdminRole {
+			return true
+		}
+	}
+
+	return false
+}
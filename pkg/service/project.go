@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"regexp"
+	"time"
+)
+
+// projects are part of the names of the jobs on the scheduler, e.g. kubernetes services, which are limited to 63 chars
+var projectRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,22}[a-z0-9])?$`)
+
+func validateProjectId(id string) error {
+	if !projectRegexp.MatchString(id) {
+		return errors.Wrapf(ErrInvalidProject, "%q is not a lowercase slug of at most 24 chars", id)
+	}
+
+	return nil
+}
+
+// callerProjects returns the projects the caller is member of along with DefaultProject, or nil when it can see every project:
+// requests without a principal come from inside the manager or from a server without authentication
+func (s *basicService) callerProjects(ctx context.Context) ([]string, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok || principal.IsAdmin() {
+		return nil, nil
+	}
+
+	projects, err := s.repository.ListProjects(ctx, principal.Subject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Retrieving Projects of the caller")
+	}
+
+	ids := make([]string, 0, len(projects))
+	for _, project := range projects {
+		ids = append(ids, project.Id)
+	}
+
+	return ids, nil
+}
+
+// visibleProject returns the project if the caller can see it, projects of others are hidden rather than forbidden,
+// DefaultProject is visible to every caller
+func (s *basicService) visibleProject(ctx context.Context, id string) (*Project, error) {
+	project, err := s.repository.GetProject(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "Retrieving Project")
+	}
+
+	principal, ok := PrincipalFromContext(ctx)
+	if ok && !principal.IsAdmin() && project.Id != DefaultProject && !project.HasMember(principal.Subject) {
+		return nil, errors.Wrap(ErrNotFound, "Retrieving Project")
+	}
+
+	return project, nil
+}
+
+func (s *basicService) CreateProject(ctx context.Context, id string, description string) (*Project, error) {
+	if err := validateProjectId(id); err != nil {
+		return nil, err
+	}
+
+	project := &Project{
+		Id:          id,
+		Description: description,
+		Members:     []string{},
+		CreatedAt:   time.Now(),
+	}
+	// the creator is the first member, otherwise it could not see the project
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		project.Members = append(project.Members, principal.Subject)
+	}
+
+	if err := s.repository.CreateProject(ctx, project); err != nil {
+		return nil, errors.Wrap(err, "Project creation")
+	}
+
+	return project, nil
+}
+
+func (s *basicService) GetProject(ctx context.Context, id string) (*Project, error) {
+	return s.visibleProject(ctx, id)
+}
+
+func (s *basicService) ListProjects(ctx context.Context) ([]*Project, error) {
+	member := ""
+	if principal, ok := PrincipalFromContext(ctx); ok && !principal.IsAdmin() {
+		member = principal.Subject
+	}
+
+	return s.repository.ListProjects(ctx, member)
+}
+
+// DeleteProject deletes a project without deploys, the default project cannot be deleted
+func (s *basicService) DeleteProject(ctx context.Context, id string) error {
+	if id == DefaultProject {
+		return errors.Wrap(ErrInvalidProject, "the default project cannot be deleted")
+	}
+
+	if _, err := s.visibleProject(ctx, id); err != nil {
+		return err
+	}
+
+	deploys, err := s.repository.ListDeploy(ctx, []string{id})
+	if err != nil {
+		return errors.Wrap(err, "Retrieving Deploys of the Project")
+	}
+	if len(deploys) > 0 {
+		return errors.Wrapf(ErrProjectNotEmpty, "%d deploys have to be destroyed first", len(deploys))
+	}
+
+	if err := s.repository.DeleteProject(ctx, id); err != nil {
+		return errors.Wrap(err, "Project deletion")
+	}
+
+	return nil
+}
+
+// AddProjectMember adds a member to a project, the default project has no members since every caller can see it
+func (s *basicService) AddProjectMember(ctx context.Context, id string, subject string) (*Project, error) {
+	if subject == "" {
+		return nil, errors.Wrap(ErrInvalidProject, "empty member")
+	}
+	if id == DefaultProject {
+		return nil, errors.Wrap(ErrInvalidProject, "the default project is open to every caller")
+	}
+
+	if _, err := s.visibleProject(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.repository.AddProjectMember(ctx, id, subject)
+}
+
+func (s *basicService) RemoveProjectMember(ctx context.Context, id string, subject string) (*Project, error) {
+	if _, err := s.visibleProject(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.repository.RemoveProjectMember(ctx, id, subject)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func newProjectService() (*basicService, *projectRepository) {
+	repository := newProjectRepository(
+		&Project{Id: DefaultProject, Members: []string{}},
+		&Project{Id: "web", Members: []string{"alice"}},
+		&Project{Id: "api", Members: []string{"bob"}},
+	)
+	repository.deploys = []*Deploy{
+		// created before projects
+		{Id: "legacy", Build: &Build{}, Workload: &Workload{}},
+		{Id: "site", ProjectId: "web", Build: &Build{}, Workload: &Workload{}},
+		{Id: "gateway", ProjectId: "api", Build: &Build{}, Workload: &Workload{}},
+	}
+
+	return &basicService{
+		repository:    repository,
+		bounds:        WorkloadBounds{MinReplicas: 1, MaxReplicas: 10},
+		authenticated: true,
+	}, repository
+}
+
+func TestDeployWithoutProjectGoesToDefaultProject(t *testing.T) {
+	s, repository := newProjectService()
+	ctx := ContextWithPrincipal(context.Background(), &Principal{Subject: "alice"})
+
+	id, err := s.Deploy(ctx, "", "https://github.com/Scarlet-Fairy/cobold.git", "cobold", nil, nil, nil, 0, Resources{}, 0, "")
+	if err != nil {
+		t.Fatalf("Deploy of a member of no project: %v", err)
+	}
+
+	deploy := repository.deploys[len(repository.deploys)-1]
+	if deploy.Id != id || deploy.Project() != DefaultProject {
+		t.Errorf("deploy %s created in project %s", deploy.Id, deploy.Project())
+	}
+}
+
+func TestProjectsOfOthersAreHidden(t *testing.T) {
+	s, _ := newProjectService()
+	ctx := ContextWithPrincipal(context.Background(), &Principal{Subject: "alice"})
+
+	if _, err := s.Deploy(ctx, "api", "", "cobold", nil, nil, nil, 0, Resources{}, 0, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Deploy to the project of another returned %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetProject(ctx, "api"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetProject of the project of another returned %v, want ErrNotFound", err)
+	}
+
+	for _, id := range []string{"web", DefaultProject} {
+		if _, err := s.GetProject(ctx, id); err != nil {
+			t.Errorf("GetProject of %s: %v", id, err)
+		}
+	}
+
+	admin := ContextWithPrincipal(context.Background(), &Principal{Subject: "root", Roles: []string{AdminRole}})
+	if _, err := s.GetProject(admin, "api"); err != nil {
+		t.Errorf("GetProject of an admin: %v", err)
+	}
+}
+
+func TestListDeploysIncludesDefaultProject(t *testing.T) {
+	s, _ := newProjectService()
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{"member", ContextWithPrincipal(context.Background(), &Principal{Subject: "alice"}), []string{"legacy", "site"}},
+		{"member of no project", ContextWithPrincipal(context.Background(), &Principal{Subject: "eve"}), []string{"legacy"}},
+		{"admin", ContextWithPrincipal(context.Background(), &Principal{Subject: "root", Roles: []string{AdminRole}}), []string{"legacy", "site", "gateway"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			deploys, err := s.ListDeploys(tc.ctx)
+			if err != nil {
+				t.Fatalf("ListDeploys: %v", err)
+			}
+
+			listed := map[string]bool{}
+			for _, deploy := range deploys {
+				listed[deploy.Id] = true
+			}
+			if len(listed) != len(tc.want) {
+				t.Errorf("listed %v, want %v", listed, tc.want)
+			}
+			for _, id := range tc.want {
+				if !listed[id] {
+					t.Errorf("%s not listed", id)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultProjectHasNoMembers(t *testing.T) {
+	s, _ := newProjectService()
+	ctx := ContextWithPrincipal(context.Background(), &Principal{Subject: "root", Roles: []string{AdminRole}})
+
+	if _, err := s.AddProjectMember(ctx, DefaultProject, "alice"); !errors.Is(err, ErrInvalidProject) {
+		t.Errorf("AddProjectMember to the default project returned %v, want ErrInvalidProject", err)
+	}
+	if err := s.DeleteProject(ctx, DefaultProject); !errors.Is(err, ErrInvalidProject) {
+		t.Errorf("DeleteProject of the default project returned %v, want ErrInvalidProject", err)
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const (
+	// quotaLockLease bounds how long a request holds the lock of a project, it is taken over afterwards
+	quotaLockLease         = 10 * time.Second
+	quotaLockRetryInterval = 50 * time.Millisecond
+)
+
+// Quota limits what the deploys of a project may consume, zero values are unlimited
+type Quota struct {
+	MaxDeploys int
+	// MaxConcurrentBuilds caps the builds in progress, the further builds wait in the queue
+	MaxConcurrentBuilds int
+	// MaxReplicas is the total of the replicas of the workloads of the project
+	MaxReplicas int
+	// MaxMemoryBytes is the total of the memory of every replica of the workloads of the project
+	MaxMemoryBytes int64
+}
+
+// QuotaUsage is what the deploys of a project consume
+type QuotaUsage struct {
+	Deploys          int
+	ConcurrentBuilds int
+	QueuedBuilds     int
+	Replicas         int
+	MemoryBytes      int64
+}
+
+type ProjectQuota struct {
+	ProjectId string
+	Quota     Quota
+	Usage     QuotaUsage
+}
+
+// replicaMemory is the memory reserved by a replica, its limit or otherwise its request
+func replicaMemory(resources Resources) int64 {
+	if resources.MemoryLimitBytes != 0 {
+		return resources.MemoryLimitBytes
+	}
+
+	return resources.MemoryRequestBytes
+}
+
+func workloadUsage(replicas int, resources Resources) QuotaUsage {
+	return QuotaUsage{
+		Replicas:    replicas,
+		MemoryBytes: int64(replicas) * replicaMemory(resources),
+	}
+}
+
+func effectiveQuota(project *Project, defaultQuota Quota) Quota {
+	if project.Quota != nil {
+		return *project.Quota
+	}
+
+	return defaultQuota
+}
+
+func (s *basicService) quotaOf(project *Project) Quota {
+	return effectiveQuota(project, s.quota)
+}
+
+func (s *basicService) usageOf(ctx context.Context, projectId string) (QuotaUsage, error) {
+	deploys, err := s.repository.ListDeploy(ctx, []string{projectId})
+	if err != nil {
+		return QuotaUsage{}, errors.Wrap(err, "Retrieving Deploys of the Project")
+	}
+
+	usage := QuotaUsage{
+		Deploys: len(deploys),
+	}
+	for _, deploy := range deploys {
+		switch deploy.Build.Status {
+		case StatusLoading:
+			usage.ConcurrentBuilds++
+		case StatusQueued:
+			usage.QueuedBuilds++
+		}
+
+		workload := workloadUsage(deploy.Workload.Replicas, deploy.Workload.Resources)
+		usage.Replicas += workload.Replicas
+		usage.MemoryBytes += workload.MemoryBytes
+	}
+
+	return usage, nil
+}
+
+// checkQuota fails with ErrQuotaExceeded when adding delta to the usage of the project exceeds its quota.
+// Only the growing quantities are checked, so that a project over its quota can always shrink.
+// Concurrent builds are not checked, since the builds over the quota are queued by BuildAdmission.
+// The check has to run within withQuotaLock along with the change it allows.
+func (s *basicService) checkQuota(ctx context.Context, project *Project, delta QuotaUsage) error {
+	quota := s.quotaOf(project)
+	if quota == (Quota{}) {
+		return nil
+	}
+
+	usage, err := s.usageOf(ctx, project.Id)
+	if err != nil {
+		return err
+	}
+
+	exceeded := func(name string, used int64, added int64, max int64) error {
+		if added <= 0 || max == 0 || used+added <= max {
+			return nil
+		}
+
+		return errors.Wrap(ErrQuotaExceeded, fmt.Sprintf("%s of project %s would be %d, the quota is %d", name, project.Id, used+added, max))
+	}
+
+	if err := exceeded("deploys", int64(usage.Deploys), int64(delta.Deploys), int64(quota.MaxDeploys)); err != nil {
+		return err
+	}
+	if err := exceeded("replicas", int64(usage.Replicas), int64(delta.Replicas), int64(quota.MaxReplicas)); err != nil {
+		return err
+	}
+	if err := exceeded("memory bytes", usage.MemoryBytes, delta.MemoryBytes, quota.MaxMemoryBytes); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// withQuotaLock runs fn holding the lock of the project, so that the requests checking its quota are serialized
+// and none of them can exceed it by racing the others. Projects without quota are not locked.
+func (s *basicService) withQuotaLock(ctx context.Context, project *Project, fn func() (string, error)) (string, error) {
+	if s.quotaOf(project) == (Quota{}) {
+		return fn()
+	}
+
+	holder := newToken()
+	for {
+		err := s.repository.LockProject(ctx, project.Id, holder, time.Now().Add(quotaLockLease))
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrConflict) {
+			return "", errors.Wrap(err, "Locking Project")
+		}
+
+		// the holder releases the lock as soon as it is done, or it is taken over once its lease is over
+		select {
+		case <-time.After(quotaLockRetryInterval):
+		case <-ctx.Done():
+			return "", errors.Wrap(ctx.Err(), "Locking Project")
+		}
+	}
+	// released even when the request has been cancelled meanwhile
+	defer func() {
+		_ = s.repository.UnlockProject(context.Background(), project.Id, holder)
+	}()
+
+	return fn()
+}
+
+// validateQuotaResources rejects workloads without memory in a project with a memory quota,
+// since they could not be accounted
+func (s *basicService) validateQuotaResources(project *Project, resources Resources) error {
+	if s.quotaOf(project).MaxMemoryBytes != 0 && replicaMemory(resources) == 0 {
+		return errors.Wrapf(ErrInvalidWorkload, "project %s has a memory quota, the memory of the replicas is required", project.Id)
+	}
+
+	return nil
+}
+
+func (s *basicService) GetQuotaUsage(ctx context.Context, projectId string) (*ProjectQuota, error) {
+	project, err := s.visibleProject(ctx, projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := s.usageOf(ctx, project.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProjectQuota{
+		ProjectId: project.Id,
+		Quota:     s.quotaOf(project),
+		Usage:     usage,
+	}, nil
+}
+
+// SetProjectQuota overrides the default quota for a project, a nil quota restores the default one.
+// Only admins can change the quotas, otherwise the members could lift the quota of their own project,
+// requests without principal are allowed only when the callers are not authenticated at all.
+// The deploys already exceeding the new quota are left untouched.
+func (s *basicService) SetProjectQuota(ctx context.Context, projectId string, quota *Quota) (*Project, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if (ok && !principal.IsAdmin()) || (!ok && s.authenticated) {
+		return nil, errors.Wrap(ErrPermissionDenied, "only admins can set the quota of a project")
+	}
+
+	if quota != nil && (quota.MaxDeploys < 0 || quota.MaxConcurrentBuilds < 0 || quota.MaxReplicas < 0 || quota.MaxMemoryBytes < 0) {
+		return nil, errors.Wrap(ErrInvalidProject, "quota limits cannot be negative")
+	}
+
+	if _, err := s.visibleProject(ctx, projectId); err != nil {
+		return nil, err
+	}
+
+	return s.repository.SetProjectQuota(ctx, projectId, quota)
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// projectRepository keeps projects and their deploys in memory, the locks of the projects expire like the stored ones,
+// the methods not needed by the tests are left to the nil Repository
+type projectRepository struct {
+	Repository
+
+	mutex    sync.Mutex
+	projects map[string]*Project
+	deploys  []*Deploy
+	locks    map[string]string
+	lockedAt map[string]time.Time
+	unlocked int
+}
+
+func (r *projectRepository) GetProject(_ context.Context, id string) (*Project, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	project, ok := r.projects[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return project, nil
+}
+
+func (r *projectRepository) ListProjects(_ context.Context, member string) ([]*Project, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var projects []*Project
+	for _, project := range r.projects {
+		if member == "" || project.Id == DefaultProject || project.HasMember(member) {
+			projects = append(projects, project)
+		}
+	}
+
+	return projects, nil
+}
+
+func (r *projectRepository) SetProjectQuota(_ context.Context, id string, quota *Quota) (*Project, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	project, ok := r.projects[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	project.Quota = quota
+	return project, nil
+}
+
+func (r *projectRepository) ListDeploy(_ context.Context, projects []string) ([]*Deploy, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if projects == nil {
+		return r.deploys, nil
+	}
+
+	var deploys []*Deploy
+	for _, deploy := range r.deploys {
+		for _, project := range projects {
+			if deploy.Project() == project {
+				deploys = append(deploys, deploy)
+			}
+		}
+	}
+
+	return deploys, nil
+}
+
+func (r *projectRepository) CreateDeploy(_ context.Context, deploy *Deploy) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	deploy.Id = newToken()
+	r.deploys = append(r.deploys, deploy)
+
+	return deploy.Id, nil
+}
+
+func (r *projectRepository) LockProject(_ context.Context, id string, holder string, until time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if current, ok := r.locks[id]; ok && current != holder && time.Now().Before(r.lockedAt[id]) {
+		return ErrConflict
+	}
+
+	r.locks[id] = holder
+	r.lockedAt[id] = until
+	return nil
+}
+
+func (r *projectRepository) UnlockProject(_ context.Context, id string, holder string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.locks[id] == holder {
+		delete(r.locks, id)
+		delete(r.lockedAt, id)
+		r.unlocked++
+	}
+
+	return nil
+}
+
+func newProjectRepository(projects ...*Project) *projectRepository {
+	repository := &projectRepository{
+		projects: map[string]*Project{},
+		locks:    map[string]string{},
+		lockedAt: map[string]time.Time{},
+	}
+	for _, project := range projects {
+		repository.projects[project.Id] = project
+	}
+
+	return repository
+}
+
+func deployOf(project string, replicas int, memoryBytes int64) *Deploy {
+	return &Deploy{
+		ProjectId: project,
+		Build:     &Build{Status: StatusCompleted},
+		Workload:  &Workload{Replicas: replicas, Resources: Resources{MemoryLimitBytes: memoryBytes}},
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	repository := newProjectRepository()
+	repository.deploys = []*Deploy{deployOf("web", 2, 256), deployOf("web", 1, 256), deployOf("api", 10, 1024)}
+	s := &basicService{repository: repository, quota: Quota{MaxDeploys: 2, MaxReplicas: 4, MaxMemoryBytes: 1024}}
+	web := &Project{Id: "web"}
+
+	for _, tc := range []struct {
+		name     string
+		project  *Project
+		delta    QuotaUsage
+		exceeded bool
+	}{
+		{"within the default quota", web, QuotaUsage{Replicas: 1, MemoryBytes: 256}, false},
+		{"one deploy too many", web, QuotaUsage{Deploys: 1}, true},
+		{"one replica too many", web, QuotaUsage{Replicas: 2}, true},
+		{"memory over the quota", web, QuotaUsage{MemoryBytes: 512}, true},
+		// a project over its quota can always shrink
+		{"shrinking", web, QuotaUsage{Replicas: -2, MemoryBytes: -512}, false},
+		{"own quota", &Project{Id: "web", Quota: &Quota{MaxDeploys: 3}}, QuotaUsage{Deploys: 1, Replicas: 100}, false},
+		{"unlimited", &Project{Id: "api", Quota: &Quota{}}, QuotaUsage{Deploys: 1, Replicas: 100}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := s.checkQuota(context.Background(), tc.project, tc.delta)
+			if tc.exceeded != errors.Is(err, ErrQuotaExceeded) {
+				t.Errorf("checkQuota returned %v, exceeded %t", err, tc.exceeded)
+			}
+		})
+	}
+}
+
+func TestWithQuotaLockSerializesRequests(t *testing.T) {
+	repository := newProjectRepository()
+	s := &basicService{repository: repository, quota: Quota{MaxDeploys: 1}}
+	project := &Project{Id: "web"}
+
+	var (
+		mutex   sync.Mutex
+		holders int
+		overlap bool
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := s.withQuotaLock(context.Background(), project, func() (string, error) {
+				mutex.Lock()
+				holders++
+				overlap = overlap || holders > 1
+				mutex.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				mutex.Lock()
+				holders--
+				mutex.Unlock()
+				return "", nil
+			})
+			if err != nil {
+				t.Errorf("withQuotaLock: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if overlap {
+		t.Error("requests held the lock together")
+	}
+	if repository.unlocked != 5 || len(repository.locks) != 0 {
+		t.Errorf("%d unlocks, %d locks left", repository.unlocked, len(repository.locks))
+	}
+}
+
+func TestWithQuotaLockGivesUpOnCancel(t *testing.T) {
+	repository := newProjectRepository()
+	repository.locks["web"] = "other"
+	repository.lockedAt["web"] = time.Now().Add(time.Hour)
+	s := &basicService{repository: repository, quota: Quota{MaxDeploys: 1}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*quotaLockRetryInterval)
+	defer cancel()
+
+	called := false
+	_, err := s.withQuotaLock(ctx, &Project{Id: "web"}, func() (string, error) {
+		called = true
+		return "", nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || called {
+		t.Errorf("withQuotaLock returned %v, called %t", err, called)
+	}
+	if repository.locks["web"] != "other" {
+		t.Error("lock of the other holder released")
+	}
+}
+
+func TestWithQuotaLockSkipsProjectsWithoutQuota(t *testing.T) {
+	repository := newProjectRepository()
+	repository.locks["web"] = "other"
+	repository.lockedAt["web"] = time.Now().Add(time.Hour)
+	s := &basicService{repository: repository}
+
+	called := false
+	if _, err := s.withQuotaLock(context.Background(), &Project{Id: "web"}, func() (string, error) {
+		called = true
+		return "", nil
+	}); err != nil || !called {
+		t.Errorf("withQuotaLock returned %v, called %t", err, called)
+	}
+}
+
+func TestSetProjectQuota(t *testing.T) {
+	admin := ContextWithPrincipal(context.Background(), &Principal{Subject: "root", Roles: []string{AdminRole}})
+	member := ContextWithPrincipal(context.Background(), &Principal{Subject: "alice"})
+
+	for _, tc := range []struct {
+		name          string
+		ctx           context.Context
+		authenticated bool
+		allowed       bool
+	}{
+		{"admin", admin, true, true},
+		{"member of the project", member, true, false},
+		{"without principal", context.Background(), true, false},
+		{"without authentication", context.Background(), false, true},
+		{"member without authorization", member, false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repository := newProjectRepository(&Project{Id: "web", Members: []string{"alice"}})
+			s := &basicService{repository: repository, authenticated: tc.authenticated}
+
+			project, err := s.SetProjectQuota(tc.ctx, "web", &Quota{MaxDeploys: 10})
+			if !tc.allowed {
+				if !errors.Is(err, ErrPermissionDenied) {
+					t.Errorf("SetProjectQuota returned %v, want ErrPermissionDenied", err)
+				}
+				if repository.projects["web"].Quota != nil {
+					t.Error("quota changed")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("SetProjectQuota: %v", err)
+			}
+			if project.Quota == nil || project.Quota.MaxDeploys != 10 {
+				t.Errorf("quota %v", project.Quota)
+			}
+		})
+	}
+}
+
+func TestSetProjectQuotaRejectsNegativeLimits(t *testing.T) {
+	s := &basicService{repository: newProjectRepository(&Project{Id: "web"})}
+
+	if _, err := s.SetProjectQuota(context.Background(), "web", &Quota{MaxReplicas: -1}); !errors.Is(err, ErrInvalidProject) {
+		t.Errorf("SetProjectQuota returned %v, want ErrInvalidProject", err)
+	}
+}
@@ -0,0 +1,479 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"strings"
+)
+
+// Permissions are named after the methods of Service they allow
+const (
+	PermissionDeploy              = "Deploy"
+	PermissionUpdateEnvs          = "UpdateEnvs"
+	PermissionScale               = "Scale"
+	PermissionDestroy             = "Destroy"
+	PermissionGetDeploy           = "GetDeploy"
+	PermissionListDeploys         = "ListDeploys"
+	PermissionListDeadLetters     = "ListDeadLetters"
+	PermissionReplayDeadLetter    = "ReplayDeadLetter"
+	PermissionAddDomain           = "AddDomain"
+	PermissionRemoveDomain        = "RemoveDomain"
+	PermissionListDomains         = "ListDomains"
+	PermissionCreateProject       = "CreateProject"
+	PermissionGetProject          = "GetProject"
+	PermissionListProjects        = "ListProjects"
+	PermissionDeleteProject       = "DeleteProject"
+	PermissionSetProjectQuota     = "SetProjectQuota"
+	PermissionGetQuotaUsage       = "GetQuotaUsage"
+	PermissionAddProjectMember    = "AddProjectMember"
+	PermissionRemoveProjectMember = "RemoveProjectMember"
+	PermissionListAuditEvents     = "ListAuditEvents"
+	// PermissionAll grants every permission
+	PermissionAll = "*"
+)
+
+var permissions = map[string]bool{
+	PermissionDeploy:              true,
+	PermissionUpdateEnvs:          true,
+	PermissionScale:               true,
+	PermissionDestroy:             true,
+	PermissionGetDeploy:           true,
+	PermissionListDeploys:         true,
+	PermissionListDeadLetters:     true,
+	PermissionReplayDeadLetter:    true,
+	PermissionAddDomain:           true,
+	PermissionRemoveDomain:        true,
+	PermissionListDomains:         true,
+	PermissionCreateProject:       true,
+	PermissionGetProject:          true,
+	PermissionListProjects:        true,
+	PermissionDeleteProject:       true,
+	PermissionSetProjectQuota:     true,
+	PermissionGetQuotaUsage:       true,
+	PermissionAddProjectMember:    true,
+	PermissionRemoveProjectMember: true,
+	PermissionListAuditEvents:     true,
+}
+
+func validatePermission(permission string) error {
+	if !permissions[permission] {
+		return errors.Wrapf(ErrInvalidPermission, "unknown permission %q", permission)
+	}
+
+	return nil
+}
+
+type PermissionCheck struct {
+	Allowed bool
+	Reason  string
+}
+
+// Scope is what a permission is checked on: a deploy, or a project when Labels is empty
+type Scope struct {
+	Project string
+	Labels  map[string]string
+}
+
+func deployScope(deploy *Deploy) *Scope {
+	return &Scope{
+		Project: deploy.Project(),
+		Labels:  deploy.Labels,
+	}
+}
+
+// Rule grants permissions on the deploys matching its projects and labels
+type Rule struct {
+	Permissions []string `json:"permissions"`
+	// the rule applies only to these projects, to every project when empty
+	Projects []string `json:"projects"`
+	// a deploy matches when it has every label with the same value, or with a different value (or none)
+	// when the value is prefixed by "!"
+	Labels map[string]string `json:"labels"`
+}
+
+type Role struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Policy maps the roles of the callers to the permissions they are granted
+type Policy struct {
+	Roles map[string]Role `json:"roles"`
+	// roles granted to subjects besides the ones carried by their credentials
+	Bindings map[string][]string `json:"bindings"`
+}
+
+// LoadPolicy reads the policy from a JSON file
+func LoadPolicy(path string) (*Policy, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Reading policy file")
+	}
+
+	policy := &Policy{}
+	if err := json.Unmarshal(content, policy); err != nil {
+		return nil, errors.Wrap(err, "Parsing policy file")
+	}
+
+	if err := policy.validate(); err != nil {
+		return nil, errors.Wrap(err, "Validating policy file")
+	}
+
+	return policy, nil
+}
+
+func (p *Policy) validate() error {
+	for name, role := range p.Roles {
+		for i, rule := range role.Rules {
+			for _, permission := range rule.Permissions {
+				if permission == PermissionAll {
+					continue
+				}
+				if err := validatePermission(permission); err != nil {
+					return errors.Wrapf(err, "rule %d of role %s", i, name)
+				}
+			}
+		}
+	}
+
+	for subject, roles := range p.Bindings {
+		for _, role := range roles {
+			if _, ok := p.Roles[role]; !ok {
+				return errors.Errorf("subject %s is bound to the undefined role %s", subject, role)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Check tells whether the principal is granted the permission on the scope. A nil scope stands for the
+// operations not targeting a single project, e.g. ReplayDeadLetter, which only rules without projects
+// and labels grant
+func (p *Policy) Check(principal *Principal, permission string, scope *Scope) *PermissionCheck {
+	if principal == nil {
+		return &PermissionCheck{Reason: "the request is not authenticated"}
+	}
+
+	roles := append(append([]string{}, principal.Roles...), p.Bindings[principal.Subject]...)
+	for _, name := range roles {
+		role, ok := p.Roles[name]
+		if !ok {
+			continue
+		}
+
+		for _, rule := range role.Rules {
+			if rule.grants(permission) && rule.matches(scope) {
+				return &PermissionCheck{
+					Allowed: true,
+					Reason:  fmt.Sprintf("granted by role %s", name),
+				}
+			}
+		}
+	}
+
+	return &PermissionCheck{
+		Reason: fmt.Sprintf("no role of %s grants %s", principal.Subject, permission),
+	}
+}
+
+func (r Rule) grants(permission string) bool {
+	for _, granted := range r.Permissions {
+		if granted == permission || granted == PermissionAll {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Rule) matches(scope *Scope) bool {
+	if scope == nil {
+		return len(r.Projects) == 0 && len(r.Labels) == 0
+	}
+
+	if len(r.Projects) > 0 {
+		found := false
+		for _, project := range r.Projects {
+			found = found || project == scope.Project
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for key, selector := range r.Labels {
+		value, ok := scope.Labels[key]
+		if strings.HasPrefix(selector, "!") {
+			if ok && value == selector[1:] {
+				return false
+			}
+		} else if !ok || value != selector {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AuthorizationMiddleware denies with ErrPermissionDenied the calls the principal in the context is not
+// granted by the policy. HandleEvent is not checked, since it is only called by the message consumers
+func AuthorizationMiddleware(policy *Policy) Middleware {
+	return func(service Service) Service {
+		return &authorizationMiddleware{
+			next:   service,
+			policy: policy,
+		}
+	}
+}
+
+type authorizationMiddleware struct {
+	next   Service
+	policy *Policy
+}
+
+func (a *authorizationMiddleware) authorize(ctx context.Context, permission string, scope *Scope) error {
+	principal, _ := PrincipalFromContext(ctx)
+	if check := a.policy.Check(principal, permission, scope); !check.Allowed {
+		return errors.Wrap(ErrPermissionDenied, check.Reason)
+	}
+
+	return nil
+}
+
+func (a *authorizationMiddleware) authorizeDeploy(ctx context.Context, permission string, deployId string) error {
+	deploy, err := a.next.GetDeploy(ctx, deployId)
+	if err != nil {
+		return err
+	}
+
+	return a.authorize(ctx, permission, deployScope(deploy))
+}
+
+func (a *authorizationMiddleware) Deploy(ctx context.Context, projectId string, gitRepo string, name string, envs map[string]string, labels map[string]string, healthCheck *HealthCheck, replicas int, resources Resources, priority int, idempotencyKey string) (string, error) {
+	// the scope is the one of the deploy which would be created
+	if err := a.authorize(ctx, PermissionDeploy, deployScope(&Deploy{ProjectId: projectId, Labels: labels})); err != nil {
+		return "", err
+	}
+
+	return a.next.Deploy(ctx, projectId, gitRepo, name, envs, labels, healthCheck, replicas, resources, priority, idempotencyKey)
+}
+
+func (a *authorizationMiddleware) HandleEvent(ctx context.Context, event *BuildStep, buildId string) (bool, error) {
+	return a.next.HandleEvent(ctx, event, buildId)
+}
+
+func (a *authorizationMiddleware) UpdateEnvs(ctx context.Context, deployId string, envs map[string]string, expectedVersion int64, idempotencyKey string) (*Deploy, error) {
+	if err := a.authorizeDeploy(ctx, PermissionUpdateEnvs, deployId); err != nil {
+		return nil, err
+	}
+
+	return a.next.UpdateEnvs(ctx, deployId, envs, expectedVersion, idempotencyKey)
+}
+
+func (a *authorizationMiddleware) Scale(ctx context.Context, deployId string, replicas int, expectedVersion int64, idempotencyKey string) (*Deploy, error) {
+	if err := a.authorizeDeploy(ctx, PermissionScale, deployId); err != nil {
+		return nil, err
+	}
+
+	return a.next.Scale(ctx, deployId, replicas, expectedVersion, idempotencyKey)
+}
+
+func (a *authorizationMiddleware) Destroy(ctx context.Context, deployId string, expectedVersion int64, idempotencyKey string) error {
+	if err := a.authorizeDeploy(ctx, PermissionDestroy, deployId); err != nil {
+		return err
+	}
+
+	return a.next.Destroy(ctx, deployId, expectedVersion, idempotencyKey)
+}
+
+func (a *authorizationMiddleware) GetDeploy(ctx context.Context, deployId string) (*Deploy, error) {
+	if err := a.authorizeDeploy(ctx, PermissionGetDeploy, deployId); err != nil {
+		return nil, err
+	}
+
+	return a.next.GetDeploy(ctx, deployId)
+}
+
+// ListDeploys lists only the deploys the principal is granted ListDeploys on
+func (a *authorizationMiddleware) ListDeploys(ctx context.Context) ([]*Deploy, error) {
+	deploys, err := a.next.ListDeploys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	principal, _ := PrincipalFromContext(ctx)
+	allowed := []*Deploy{}
+	for _, deploy := range deploys {
+		if a.policy.Check(principal, PermissionListDeploys, deployScope(deploy)).Allowed {
+			allowed = append(allowed, deploy)
+		}
+	}
+
+	return allowed, nil
+}
+
+func (a *authorizationMiddleware) ListDeadLetters(ctx context.Context, deployId string) ([]*DeadLetter, error) {
+	var err error
+	if deployId == "" {
+		err = a.authorize(ctx, PermissionListDeadLetters, nil)
+	} else {
+		err = a.authorizeDeploy(ctx, PermissionListDeadLetters, deployId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return a.next.ListDeadLetters(ctx, deployId)
+}
+
+func (a *authorizationMiddleware) ReplayDeadLetter(ctx context.Context, id string) error {
+	if err := a.authorize(ctx, PermissionReplayDeadLetter, nil); err != nil {
+		return err
+	}
+
+	return a.next.ReplayDeadLetter(ctx, id)
+}
+
+func (a *authorizationMiddleware) AddDomain(ctx context.Context, deployId string, name string) (*Domain, error) {
+	if err := a.authorizeDeploy(ctx, PermissionAddDomain, deployId); err != nil {
+		return nil, err
+	}
+
+	return a.next.AddDomain(ctx, deployId, name)
+}
+
+func (a *authorizationMiddleware) RemoveDomain(ctx context.Context, deployId string, name string) error {
+	if err := a.authorizeDeploy(ctx, PermissionRemoveDomain, deployId); err != nil {
+		return err
+	}
+
+	return a.next.RemoveDomain(ctx, deployId, name)
+}
+
+func (a *authorizationMiddleware) ListDomains(ctx context.Context, deployId string) ([]*Domain, error) {
+	if err := a.authorizeDeploy(ctx, PermissionListDomains, deployId); err != nil {
+		return nil, err
+	}
+
+	return a.next.ListDomains(ctx, deployId)
+}
+
+// CheckPermission answers with the policy instead of the next service, which allows everything
+func (a *authorizationMiddleware) CheckPermission(ctx context.Context, permission string, projectId string, deployId string, labels map[string]string) (*PermissionCheck, error) {
+	if err := validatePermission(permission); err != nil {
+		return nil, err
+	}
+
+	var scope *Scope
+	switch {
+	case deployId != "":
+		deploy, err := a.next.GetDeploy(ctx, deployId)
+		if err != nil {
+			return nil, err
+		}
+		scope = deployScope(deploy)
+	case projectId != "" || permission == PermissionDeploy:
+		// the scope is the one of the deploy which would be created
+		scope = deployScope(&Deploy{ProjectId: projectId, Labels: labels})
+	}
+
+	principal, _ := PrincipalFromContext(ctx)
+	return a.policy.Check(principal, permission, scope), nil
+}
+
+func (a *authorizationMiddleware) CreateProject(ctx context.Context, id string, description string) (*Project, error) {
+	if err := a.authorize(ctx, PermissionCreateProject, &Scope{Project: id}); err != nil {
+		return nil, err
+	}
+
+	return a.next.CreateProject(ctx, id, description)
+}
+
+func (a *authorizationMiddleware) GetProject(ctx context.Context, id string) (*Project, error) {
+	if err := a.authorize(ctx, PermissionGetProject, &Scope{Project: id}); err != nil {
+		return nil, err
+	}
+
+	return a.next.GetProject(ctx, id)
+}
+
+// ListProjects lists only the projects the principal is granted ListProjects on
+func (a *authorizationMiddleware) ListProjects(ctx context.Context) ([]*Project, error) {
+	projects, err := a.next.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	principal, _ := PrincipalFromContext(ctx)
+	allowed := []*Project{}
+	for _, project := range projects {
+		if a.policy.Check(principal, PermissionListProjects, &Scope{Project: project.Id}).Allowed {
+			allowed = append(allowed, project)
+		}
+	}
+
+	return allowed, nil
+}
+
+func (a *authorizationMiddleware) DeleteProject(ctx context.Context, id string) error {
+	if err := a.authorize(ctx, PermissionDeleteProject, &Scope{Project: id}); err != nil {
+		return err
+	}
+
+	return a.next.DeleteProject(ctx, id)
+}
+
+func (a *authorizationMiddleware) AddProjectMember(ctx context.Context, id string, subject string) (*Project, error) {
+	if err := a.authorize(ctx, PermissionAddProjectMember, &Scope{Project: id}); err != nil {
+		return nil, err
+	}
+
+	return a.next.AddProjectMember(ctx, id, subject)
+}
+
+func (a *authorizationMiddleware) RemoveProjectMember(ctx context.Context, id string, subject string) (*Project, error) {
+	if err := a.authorize(ctx, PermissionRemoveProjectMember, &Scope{Project: id}); err != nil {
+		return nil, err
+	}
+
+	return a.next.RemoveProjectMember(ctx, id, subject)
+}
+
+func (a *authorizationMiddleware) SetProjectQuota(ctx context.Context, id string, quota *Quota) (*Project, error) {
+	if err := a.authorize(ctx, PermissionSetProjectQuota, &Scope{Project: id}); err != nil {
+		return nil, err
+	}
+
+	return a.next.SetProjectQuota(ctx, id, quota)
+}
+
+func (a *authorizationMiddleware) GetQuotaUsage(ctx context.Context, projectId string) (*ProjectQuota, error) {
+	if err := a.authorize(ctx, PermissionGetQuotaUsage, &Scope{Project: projectId}); err != nil {
+		return nil, err
+	}
+
+	return a.next.GetQuotaUsage(ctx, projectId)
+}
+
+// ListAuditEvents of a deploy are scoped to it while it exists, otherwise the permission has to be unscoped
+func (a *authorizationMiddleware) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error) {
+	var scope *Scope
+	if filter.DeployId != "" {
+		deploy, err := a.next.GetDeploy(ctx, filter.DeployId)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			scope = deployScope(deploy)
+		}
+	}
+
+	if err := a.authorize(ctx, PermissionListAuditEvents, scope); err != nil {
+		return nil, err
+	}
+
+	return a.next.ListAuditEvents(ctx, filter)
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func testPolicy() *Policy {
+	return &Policy{
+		Roles: map[string]Role{
+			AdminRole: {Rules: []Rule{
+				{Permissions: []string{PermissionAll}},
+			}},
+			"developer": {Rules: []Rule{
+				{
+					Permissions: []string{PermissionDeploy, PermissionUpdateEnvs, PermissionScale, PermissionGetDeploy, PermissionListDeploys},
+					Projects:    []string{"web"},
+					Labels:      map[string]string{"env": "!production"},
+				},
+			}},
+			"viewer": {Rules: []Rule{
+				{Permissions: []string{PermissionGetDeploy, PermissionListDeploys}},
+			}},
+			"operator": {Rules: []Rule{
+				{Permissions: []string{PermissionReplayDeadLetter, PermissionListDeadLetters}},
+			}},
+			"releaser": {Rules: []Rule{
+				{Permissions: []string{PermissionDestroy}, Labels: map[string]string{"env": "staging"}},
+			}},
+		},
+		Bindings: map[string][]string{
+			"carol": {"operator"},
+		},
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	webStaging := &Scope{Project: "web", Labels: map[string]string{"env": "staging"}}
+	webProduction := &Scope{Project: "web", Labels: map[string]string{"env": "production"}}
+	webUnlabeled := &Scope{Project: "web"}
+	apiStaging := &Scope{Project: "api", Labels: map[string]string{"env": "staging"}}
+
+	for _, tc := range []struct {
+		name       string
+		principal  *Principal
+		permission string
+		scope      *Scope
+		allowed    bool
+	}{
+		{"admin deploys anywhere", &Principal{Subject: "root", Roles: []string{AdminRole}}, PermissionDeploy, webProduction, true},
+		{"admin replays dead letters", &Principal{Subject: "root", Roles: []string{AdminRole}}, PermissionReplayDeadLetter, nil, true},
+
+		{"developer deploys to staging of its project", &Principal{Subject: "alice", Roles: []string{"developer"}}, PermissionDeploy, webStaging, true},
+		{"developer deploys unlabeled", &Principal{Subject: "alice", Roles: []string{"developer"}}, PermissionDeploy, webUnlabeled, true},
+		{"developer scales to production", &Principal{Subject: "alice", Roles: []string{"developer"}}, PermissionScale, webProduction, false},
+		{"developer deploys to another project", &Principal{Subject: "alice", Roles: []string{"developer"}}, PermissionDeploy, apiStaging, false},
+		{"developer destroys", &Principal{Subject: "alice", Roles: []string{"developer"}}, PermissionDestroy, webStaging, false},
+		{"developer lists every project", &Principal{Subject: "alice", Roles: []string{"developer"}}, PermissionListDeploys, nil, false},
+
+		{"viewer gets any deploy", &Principal{Subject: "bob", Roles: []string{"viewer"}}, PermissionGetDeploy, apiStaging, true},
+		{"viewer scales", &Principal{Subject: "bob", Roles: []string{"viewer"}}, PermissionScale, apiStaging, false},
+
+		{"releaser destroys staging", &Principal{Subject: "dave", Roles: []string{"releaser"}}, PermissionDestroy, apiStaging, true},
+		{"releaser destroys production", &Principal{Subject: "dave", Roles: []string{"releaser"}}, PermissionDestroy, webProduction, false},
+		// labeled rules never match the operations without a scope
+		{"releaser destroys unscoped", &Principal{Subject: "dave", Roles: []string{"releaser"}}, PermissionDestroy, nil, false},
+
+		{"role bound to the subject", &Principal{Subject: "carol"}, PermissionReplayDeadLetter, nil, true},
+		{"roles of the credentials and bindings add up", &Principal{Subject: "carol", Roles: []string{"viewer"}}, PermissionGetDeploy, webStaging, true},
+
+		// deny by default
+		{"unauthenticated", nil, PermissionGetDeploy, webStaging, false},
+		{"without roles", &Principal{Subject: "eve"}, PermissionGetDeploy, webStaging, false},
+		{"undefined role", &Principal{Subject: "eve", Roles: []string{"superuser"}}, PermissionGetDeploy, webStaging, false},
+		{"unknown permission", &Principal{Subject: "bob", Roles: []string{"viewer"}}, "DropDatabase", webStaging, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			check := testPolicy().Check(tc.principal, tc.permission, tc.scope)
+			if check.Allowed != tc.allowed {
+				t.Errorf("allowed %t (%s), want %t", check.Allowed, check.Reason, tc.allowed)
+			}
+			if check.Reason == "" {
+				t.Error("check without reason")
+			}
+		})
+	}
+}
+
+func TestLoadPolicyValidates(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown permission": `{"roles": {"developer": {"rules": [{"permissions": ["DropDatabase"]}]}}}`,
+		"undefined role":     `{"roles": {}, "bindings": {"alice": ["developer"]}}`,
+		"malformed":          `{"roles": [`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.json")
+			if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatalf("writing policy: %v", err)
+			}
+
+			if _, err := LoadPolicy(path); err == nil {
+				t.Error("invalid policy loaded")
+			}
+		})
+	}
+}
+
+// deployService serves deploys by id, recording the calls reaching it past the authorization,
+// the methods not needed by the tests are left to the nil Service
+type deployService struct {
+	Service
+
+	deploys map[string]*Deploy
+	calls   []string
+}
+
+func (s *deployService) GetDeploy(_ context.Context, id string) (*Deploy, error) {
+	deploy, ok := s.deploys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return deploy, nil
+}
+
+func (s *deployService) ListDeploys(_ context.Context) ([]*Deploy, error) {
+	var deploys []*Deploy
+	for _, deploy := range s.deploys {
+		deploys = append(deploys, deploy)
+	}
+
+	return deploys, nil
+}
+
+func (s *deployService) Destroy(_ context.Context, id string, _ int64, _ string) error {
+	s.calls = append(s.calls, "Destroy "+id)
+	return nil
+}
+
+func (s *deployService) Deploy(_ context.Context, projectId string, _ string, name string, _ map[string]string, _ map[string]string, _ *HealthCheck, _ int, _ Resources, _ int, _ string) (string, error) {
+	s.calls = append(s.calls, "Deploy "+projectId+"/"+name)
+	return "6138c6d0c3b5a9d2e1b4f7a1", nil
+}
+
+func (s *deployService) ReplayDeadLetter(_ context.Context, id string) error {
+	s.calls = append(s.calls, "ReplayDeadLetter "+id)
+	return nil
+}
+
+func (s *deployService) HandleEvent(_ context.Context, _ *BuildStep, buildId string) (bool, error) {
+	s.calls = append(s.calls, "HandleEvent "+buildId)
+	return false, nil
+}
+
+func newDeployService() *deployService {
+	return &deployService{
+		deploys: map[string]*Deploy{
+			"staging":    {Id: "staging", ProjectId: "web", Labels: map[string]string{"env": "staging"}},
+			"production": {Id: "production", ProjectId: "web", Labels: map[string]string{"env": "production"}},
+		},
+	}
+}
+
+func TestAuthorizationMiddlewareChecksScopeOfDeploy(t *testing.T) {
+	next := newDeployService()
+	s := AuthorizationMiddleware(testPolicy())(next)
+	ctx := ContextWithPrincipal(context.Background(), &Principal{Subject: "dave", Roles: []string{"releaser"}})
+
+	if err := s.Destroy(ctx, "staging", 0, ""); err != nil {
+		t.Fatalf("Destroy of staging: %v", err)
+	}
+	if err := s.Destroy(ctx, "production", 0, ""); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Destroy of production returned %v, want ErrPermissionDenied", err)
+	}
+	if err := s.Destroy(ctx, "missing", 0, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Destroy of a missing deploy returned %v, want ErrNotFound", err)
+	}
+
+	if len(next.calls) != 1 || next.calls[0] != "Destroy staging" {
+		t.Errorf("calls %v, want only the allowed one", next.calls)
+	}
+}
+
+func TestAuthorizationMiddlewareChecksScopeOfNewDeploy(t *testing.T) {
+	next := newDeployService()
+	s := AuthorizationMiddleware(testPolicy())(next)
+	ctx := ContextWithPrincipal(context.Background(), &Principal{Subject: "alice", Roles: []string{"developer"}})
+
+	if _, err := s.Deploy(ctx, "web", "", "api", nil, map[string]string{"env": "staging"}, nil, 1, Resources{}, 0, ""); err != nil {
+		t.Fatalf("Deploy to staging: %v", err)
+	}
+	if _, err := s.Deploy(ctx, "web", "", "api", nil, map[string]string{"env": "production"}, nil, 1, Resources{}, 0, ""); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Deploy to production returned %v, want ErrPermissionDenied", err)
+	}
+
+	if len(next.calls) != 1 {
+		t.Errorf("calls %v, want only the allowed one", next.calls)
+	}
+}
+
+func TestAuthorizationMiddlewareDeniesByDefault(t *testing.T) {
+	next := newDeployService()
+	s := AuthorizationMiddleware(testPolicy())(next)
+
+	// without principal nothing is granted
+	if err := s.ReplayDeadLetter(context.Background(), "letter"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("ReplayDeadLetter without principal returned %v, want ErrPermissionDenied", err)
+	}
+
+	ctx := ContextWithPrincipal(context.Background(), &Principal{Subject: "alice", Roles: []string{"developer"}})
+	if err := s.ReplayDeadLetter(ctx, "letter"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("ReplayDeadLetter of a developer returned %v, want ErrPermissionDenied", err)
+	}
+
+	if len(next.calls) != 0 {
+		t.Errorf("calls %v reached the service", next.calls)
+	}
+
+	// the events are handled for the message consumers, which have no principal
+	if _, err := s.HandleEvent(context.Background(), &BuildStep{}, "staging"); err != nil {
+		t.Errorf("HandleEvent: %v", err)
+	}
+}
+
+func TestAuthorizationMiddlewareFiltersListedDeploys(t *testing.T) {
+	s := AuthorizationMiddleware(testPolicy())(newDeployService())
+	ctx := ContextWithPrincipal(context.Background(), &Principal{Subject: "alice", Roles: []string{"developer"}})
+
+	deploys, err := s.ListDeploys(ctx)
+	if err != nil {
+		t.Fatalf("ListDeploys: %v", err)
+	}
+
+	if len(deploys) != 1 || deploys[0].Id != "staging" {
+		t.Errorf("listed %d deploys, want only staging", len(deploys))
+	}
+}
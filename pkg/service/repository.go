@@ -1,17 +1,77 @@
 package service
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Repository interface {
+	Init(ctx context.Context) error
+
 	CreateDeploy(ctx context.Context, deploy *Deploy) (string, error)
 	GetDeploy(ctx context.Context, id string) (*Deploy, error)
-	GetDeployByName(ctx context.Context, name string) (*Deploy, error)
-	ListDeploy(ctx context.Context) ([]*Deploy, error)
-	UpdateDeploy(ctx context.Context, deploy *Deploy) error
+	GetDeployByName(ctx context.Context, projectId string, name string) (*Deploy, error)
+	// ListDeploy lists the deploys of the given projects, or of every project when projects is nil
+	ListDeploy(ctx context.Context, projects []string) ([]*Deploy, error)
+	// UpdateDeploy stores the changes of the callers to a deploy, i.e. its configuration and its deletion, and appends
+	// tasks to the ones of the deploy. It fails with ErrConflict when the deploy has changed since it has been read,
+	// the state written by the processing of the deploy is left untouched.
+	UpdateDeploy(ctx context.Context, deploy *Deploy, tasks []*Task) error
 	DeleteDeploy(ctx context.Context, id string) error
 
 	InitBuild(ctx context.Context, id string, jobName, jobId, imageName string) error
 	InitWorkload(ctx context.Context, id string, jobName, jobId string, envs map[string]string, url string) error
 	SetBuildStatus(ctx context.Context, id string, status Status) error
 	RecordBuildStep(ctx context.Context, id string, buildStep BuildStep) error
+	SetWorkloadStatus(ctx context.Context, id string, status WorkloadStatus) error
+	SetWorkloadHealth(ctx context.Context, id string, health WorkloadHealth) error
+
+	// ListQueuedBuilds lists the deploys whose build is queued, in the order they have to be started
+	ListQueuedBuilds(ctx context.Context) ([]*Deploy, error)
+	// AdmitBuild starts a queued build enqueuing task, it fails with ErrNotFound when the build is no longer queued
+	AdmitBuild(ctx context.Context, id string, task *Task) error
+	// CountBuildsInProgress counts the loading builds of every project
+	CountBuildsInProgress(ctx context.Context) (map[string]int, error)
+	// TimeoutBuilds fails the builds loading since before startedBefore, returning how many have been failed
+	TimeoutBuilds(ctx context.Context, startedBefore time.Time) (int64, error)
+
+	EnqueueTask(ctx context.Context, id string, task *Task) error
+	ClaimTask(ctx context.Context, lease time.Duration) (string, *Task, error)
+	RetryTask(ctx context.Context, id string, taskId string, nextAttemptAt time.Time, lastError string) error
+	CompleteTask(ctx context.Context, id string, taskId string) error
+
+	CreateDeadLetter(ctx context.Context, letter *DeadLetter) (string, error)
+	GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error)
+	ListDeadLetters(ctx context.Context, deployId string) ([]*DeadLetter, error)
+	DeleteDeadLetter(ctx context.Context, id string) error
+
+	CreateDomain(ctx context.Context, domain *Domain) error
+	GetDomain(ctx context.Context, name string) (*Domain, error)
+	ListDomains(ctx context.Context, deployId string) ([]*Domain, error)
+	VerifyDomain(ctx context.Context, name string, token string, verifiedAt time.Time) error
+	DeleteDomain(ctx context.Context, name string) error
+	DeleteDomains(ctx context.Context, deployId string) error
+
+	CreateProject(ctx context.Context, project *Project) error
+	GetProject(ctx context.Context, id string) (*Project, error)
+	// ListProjects lists the projects having member along with DefaultProject, or every project when member is empty
+	ListProjects(ctx context.Context, member string) ([]*Project, error)
+	DeleteProject(ctx context.Context, id string) error
+	SetProjectQuota(ctx context.Context, id string, quota *Quota) (*Project, error)
+	// LockProject holds the lock of a project for holder until the given time, it fails with ErrConflict
+	// while the lock is held by another holder
+	LockProject(ctx context.Context, id string, holder string, until time.Time) error
+	// UnlockProject releases the lock of a project, unless it has been taken over by another holder
+	UnlockProject(ctx context.Context, id string, holder string) error
+	AddProjectMember(ctx context.Context, id string, subject string) (*Project, error)
+	RemoveProjectMember(ctx context.Context, id string, subject string) (*Project, error)
+
+	CreateAuditEvent(ctx context.Context, event *AuditEvent) (string, error)
+	// ListAuditEvents lists the audit events matching filter of the given projects, or of every project when projects is nil
+	ListAuditEvents(ctx context.Context, filter AuditFilter, projects []string) ([]*AuditEvent, error)
+
+	CreateIdempotencyKey(ctx context.Context, key *IdempotencyKey) error
+	GetIdempotencyKey(ctx context.Context, key string, method string) (*IdempotencyKey, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, method string, result string) error
+	DeleteIdempotencyKey(ctx context.Context, key string, method string) error
 }
@@ -3,7 +3,10 @@ package service
 import "context"
 
 type Scheduler interface {
-	ScheduleImageBuild(ctx context.Context, workloadId string, gitRepoUrl string) (jobName string, imageName string, err error)
-	ScheduleWorkload(ctx context.Context, envs map[string]string, workloadId string) (jobName string, url string, err error)
+	// ScheduleImageBuild starts the build of the image of a workload, the job is named after buildId,
+	// so that a scheduling repeated with the same buildId finds the job already scheduled
+	ScheduleImageBuild(ctx context.Context, workloadId string, buildId string, gitRepoUrl string) (jobName string, imageName string, err error)
+	ScheduleWorkload(ctx context.Context, envs map[string]string, workloadId string, replicas int, resources Resources, domains []string) (jobName string, url string, err error)
 	UnScheduleJob(ctx context.Context, jobId string) error
+	GetJobStatus(ctx context.Context, jobId string) (*JobStatus, error)
 }
@@ -4,29 +4,57 @@ import (
 	"context"
 	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
+	"time"
 )
 
 type Service interface {
-	Deploy(ctx context.Context, gitRepo string, name string, envs map[string]string) (string, error)
-	HandleEvent(ctx context.Context, event *BuildStep, buildId string, envs map[string]string) (bool, error)
-	Destroy(ctx context.Context, deployId string) error
+	Deploy(ctx context.Context, projectId string, gitRepo string, name string, envs map[string]string, labels map[string]string, healthCheck *HealthCheck, replicas int, resources Resources, priority int, idempotencyKey string) (string, error)
+	HandleEvent(ctx context.Context, event *BuildStep, buildId string) (bool, error)
+	UpdateEnvs(ctx context.Context, deployId string, envs map[string]string, expectedVersion int64, idempotencyKey string) (*Deploy, error)
+	Scale(ctx context.Context, deployId string, replicas int, expectedVersion int64, idempotencyKey string) (*Deploy, error)
+	Destroy(ctx context.Context, deployId string, expectedVersion int64, idempotencyKey string) error
 	GetDeploy(ctx context.Context, name string) (*Deploy, error)
 	ListDeploys(ctx context.Context) ([]*Deploy, error)
+	ListDeadLetters(ctx context.Context, deployId string) ([]*DeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, id string) error
+	AddDomain(ctx context.Context, deployId string, name string) (*Domain, error)
+	RemoveDomain(ctx context.Context, deployId string, name string) error
+	ListDomains(ctx context.Context, deployId string) ([]*Domain, error)
+	CreateProject(ctx context.Context, id string, description string) (*Project, error)
+	GetProject(ctx context.Context, id string) (*Project, error)
+	SetProjectQuota(ctx context.Context, id string, quota *Quota) (*Project, error)
+	GetQuotaUsage(ctx context.Context, projectId string) (*ProjectQuota, error)
+	ListProjects(ctx context.Context) ([]*Project, error)
+	DeleteProject(ctx context.Context, id string) error
+	AddProjectMember(ctx context.Context, id string, subject string) (*Project, error)
+	RemoveProjectMember(ctx context.Context, id string, subject string) (*Project, error)
+	// CheckPermission tells, without doing anything, whether the caller may call a method on a deploy,
+	// or on a project, or on a deploy of the project with the given labels when deployId is empty
+	CheckPermission(ctx context.Context, permission string, projectId string, deployId string, labels map[string]string) (*PermissionCheck, error)
+	// ListAuditEvents lists the audit events matching filter, the most recent first
+	ListAuditEvents(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error)
 }
 
 type basicService struct {
 	repository Repository
 	message    Message
-	scheduler  Scheduler
+	bounds     WorkloadBounds
+	// quota of the projects without one of their own
+	quota Quota
+	// authenticated tells the callers are authenticated, so the requests without principal come from inside the manager
+	authenticated bool
 }
 
-func NewService(repository Repository, message Message, scheduler Scheduler, logger log.Logger) Service {
+func NewService(repository Repository, message Message, bounds WorkloadBounds, quota Quota, authenticated bool, logger log.Logger) Service {
 	var service Service
 	{
 		service = &basicService{
 			repository: repository,
 			message:    message,
-			scheduler:  scheduler,
+			bounds:     bounds,
+			quota:      quota,
+
+			authenticated: authenticated,
 		}
 		service = LoggingMiddleware(logger)(service)
 	}
@@ -34,124 +62,293 @@ func NewService(repository Repository, message Message, scheduler Scheduler, log
 	return service
 }
 
-func (s *basicService) Deploy(ctx context.Context, gitRepoUrl string, name string, envs map[string]string) (string, error) {
-	id, err := s.repository.CreateDeploy(ctx, &Deploy{
-		Name:    name,
-		GitRepo: gitRepoUrl,
-		Build:   &Build{},
-		Workload: &Workload{
-			Envs: envs,
-		},
-	})
+func (s *basicService) Deploy(ctx context.Context, projectId string, gitRepoUrl string, name string, envs map[string]string, labels map[string]string, healthCheck *HealthCheck, replicas int, resources Resources, priority int, idempotencyKey string) (string, error) {
+	healthCheck, err := validateHealthCheck(healthCheck)
 	if err != nil {
-		return "", errors.Wrap(err, "Deploy creation")
+		return "", err
 	}
 
-	buildJobName, imageName, err := s.scheduler.ScheduleImageBuild(ctx, id, gitRepoUrl)
-	if err != nil {
-		return "", errors.Wrap(err, "Image Build Schedulation")
+	if replicas == 0 {
+		replicas = DefaultReplicas
 	}
-
-	if err := s.repository.InitBuild(ctx, id, buildJobName, buildJobName, imageName); err != nil {
-		return "", errors.Wrap(err, "Storing build infos")
+	if err := s.bounds.validateReplicas(replicas); err != nil {
+		return "", err
 	}
-
-	if err := s.repository.SetBuildStatus(ctx, id, StatusLoading); err != nil {
-		return "", errors.Wrap(err, "Failed to set build status")
+	if err := s.bounds.validateResources(resources); err != nil {
+		return "", err
 	}
 
-	events, clear, err := s.message.ConsumeBuildEvents(id)
+	if projectId == "" {
+		projectId = DefaultProject
+	}
+	project, err := s.visibleProject(ctx, projectId)
 	if err != nil {
-		if err := s.repository.SetBuildStatus(ctx, id, StatusError); err != nil {
-			return "", errors.Wrap(err, "Failed to set build status")
-		}
-
-		return "", errors.Wrap(err, "Failed to consume events")
+		return "", err
+	}
+	if err := s.validateQuotaResources(project, resources); err != nil {
+		return "", err
 	}
 
-	go func() {
-		for event := range events {
-			done, err := s.HandleEvent(context.Background(), event, id, envs)
-			if err != nil {
-				return
+	request := struct {
+		ProjectId   string
+		GitRepo     string
+		Name        string
+		Envs        map[string]string
+		Labels      map[string]string
+		HealthCheck *HealthCheck
+		Replicas    int
+		Resources   Resources
+		Priority    int
+	}{projectId, gitRepoUrl, name, envs, labels, healthCheck, replicas, resources, priority}
+
+	return s.idempotent(ctx, idempotencyKey, "Deploy", request, func() (string, error) {
+		return s.withQuotaLock(ctx, project, func() (string, error) {
+			delta := workloadUsage(replicas, resources)
+			delta.Deploys = 1
+			if err := s.checkQuota(ctx, project, delta); err != nil {
+				return "", err
 			}
 
-			if done {
-				break
-			}
-		}
-		_ = clear()
-	}()
+			return s.deploy(ctx, projectId, gitRepoUrl, name, envs, labels, healthCheck, replicas, resources, priority)
+		})
+	})
+}
+
+func (s *basicService) deploy(ctx context.Context, projectId string, gitRepoUrl string, name string, envs map[string]string, labels map[string]string, healthCheck *HealthCheck, replicas int, resources Resources, priority int) (string, error) {
+	// the build waits in the queue until BuildAdmission starts it
+	id, err := s.repository.CreateDeploy(ctx, &Deploy{
+		ProjectId:   projectId,
+		Name:        name,
+		GitRepo:     gitRepoUrl,
+		Labels:      labels,
+		HealthCheck: healthCheck,
+		Build: &Build{
+			Status:   StatusQueued,
+			Priority: priority,
+			QueuedAt: time.Now(),
+
+			TraceContext: traceContext(ctx),
+		},
+		Workload: &Workload{
+			Envs:      envs,
+			Replicas:  replicas,
+			Resources: resources,
+		},
+		Tasks: []*Task{},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "Deploy creation")
+	}
 
 	return id, nil
 }
 
-func (s *basicService) HandleEvent(ctx context.Context, event *BuildStep, buildId string, envs map[string]string) (bool, error) {
+func (s *basicService) HandleEvent(ctx context.Context, event *BuildStep, buildId string) (bool, error) {
 	if !event.Step.IsValid() {
 		if err := s.repository.SetBuildStatus(ctx, buildId, StatusError); err != nil {
 			return false, errors.Wrap(err, "Settings Build status on Error")
 		}
 
-		return false, errors.Wrap(
-			errors.New("Internal error while parsing"),
-			"Build failed",
-		)
+		return false, errors.Wrap(ErrInvalidEvent, "Build failed")
 	}
 
-	if event.Error != "" {
-		if err := s.repository.RecordBuildStep(ctx, buildId, *event); err != nil {
-			return false, errors.Wrap(err, "Recording Build Step with error")
+	if err := s.repository.RecordBuildStep(ctx, buildId, *event); err != nil {
+		if !errors.Is(err, ErrDuplicateEvent) {
+			return false, errors.Wrap(err, "Recording Build Step")
+		}
+
+		// A redelivered event whose effects may have been applied only partially
+		deploy, err := s.repository.GetDeploy(ctx, buildId)
+		if err != nil {
+			return false, errors.Wrap(err, "Getting Deploy of duplicated event")
 		}
 
+		if deploy.Build.Status != StatusLoading {
+			return true, nil
+		}
+	}
+
+	if event.Error != "" {
 		if err := s.repository.SetBuildStatus(ctx, buildId, StatusError); err != nil {
 			return false, errors.Wrap(err, "Settings Build Status on Error")
 		}
 
 		return true, nil
 	}
-	if err := s.repository.RecordBuildStep(ctx, buildId, *event); err != nil {
-		return false, errors.Wrap(err, "Recoding Build Step")
-	}
 
 	if event.Step == StepPush {
+		if err := s.repository.EnqueueTask(ctx, buildId, newTask(ctx, TaskScheduleWorkload)); err != nil {
+			return false, errors.Wrap(err, "Enqueuing Workload Schedulation")
+		}
+
 		if err := s.repository.SetBuildStatus(ctx, buildId, StatusCompleted); err != nil {
 			return false, errors.Wrap(err, "Settings Build Status on Completed")
 		}
 
-		jobName, url, err := s.scheduler.ScheduleWorkload(ctx, envs, buildId)
-		if err != nil {
-			if err := s.repository.SetBuildStatus(ctx, buildId, StatusError); err != nil {
-				return false, errors.Wrap(err, "Settings Build Status on Error")
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (s *basicService) UpdateEnvs(ctx context.Context, deployId string, envs map[string]string, expectedVersion int64, idempotencyKey string) (*Deploy, error) {
+	request := struct {
+		DeployId        string
+		Envs            map[string]string
+		ExpectedVersion int64
+	}{deployId, envs, expectedVersion}
+
+	var deploy *Deploy
+	_, err := s.idempotent(ctx, idempotencyKey, "UpdateEnvs", request, func() (string, error) {
+		updated, err := s.readModifyWrite(ctx, deployId, expectedVersion, func(deploy *Deploy) error {
+			deploy.Workload.Envs = envs
+
+			// A running workload is scheduled again to pick up the new envs
+			if deploy.Workload.JobId != "" {
+				deploy.Tasks = append(deploy.Tasks, newTask(ctx, TaskScheduleWorkload))
 			}
 
-			return false, errors.Wrap(err, "Scheduling Workload")
+			return nil
+		})
+		if err != nil {
+			return "", err
 		}
 
-		if err := s.repository.InitWorkload(ctx, buildId, jobName, jobName, envs, url); err != nil {
-			return false, errors.Wrap(err, "Storing workload infos")
-		}
+		deploy = updated
+		return deploy.Id, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		return true, nil
+	if deploy == nil {
+		return s.GetDeploy(ctx, deployId)
 	}
 
-	return false, nil
+	return deploy, nil
 }
 
-func (s *basicService) Destroy(ctx context.Context, deployId string) error {
-	deploy, err := s.repository.GetDeploy(ctx, deployId)
-	if err != nil {
-		return errors.Wrap(err, "Retrieving Deploy")
+func (s *basicService) Scale(ctx context.Context, deployId string, replicas int, expectedVersion int64, idempotencyKey string) (*Deploy, error) {
+	if err := s.bounds.validateReplicas(replicas); err != nil {
+		return nil, err
 	}
 
-	if err := s.repository.DeleteDeploy(ctx, deployId); err != nil {
-		return errors.Wrap(err, "Deleting Deploy")
+	request := struct {
+		DeployId        string
+		Replicas        int
+		ExpectedVersion int64
+	}{deployId, replicas, expectedVersion}
+
+	var deploy *Deploy
+	_, err := s.idempotent(ctx, idempotencyKey, "Scale", request, func() (string, error) {
+		current, err := s.GetDeploy(ctx, deployId)
+		if err != nil {
+			return "", errors.Wrap(err, "Retrieving Deploy")
+		}
+
+		project, err := s.repository.GetProject(ctx, current.Project())
+		if err != nil {
+			return "", errors.Wrap(err, "Retrieving Project")
+		}
+
+		return s.withQuotaLock(ctx, project, func() (string, error) {
+			updated, err := s.readModifyWrite(ctx, deployId, expectedVersion, func(deploy *Deploy) error {
+				delta := workloadUsage(replicas-deploy.Workload.Replicas, deploy.Workload.Resources)
+				if err := s.checkQuota(ctx, project, delta); err != nil {
+					return err
+				}
+
+				deploy.Workload.Replicas = replicas
+
+				// A running workload is scheduled again with the new replicas
+				if deploy.Workload.JobId != "" {
+					deploy.Tasks = append(deploy.Tasks, newTask(ctx, TaskScheduleWorkload))
+				}
+
+				return nil
+			})
+			if err != nil {
+				return "", err
+			}
+
+			deploy = updated
+			return deploy.Id, nil
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.scheduler.UnScheduleJob(ctx, deploy.Workload.JobId); err != nil {
-		return errors.Wrap(err, "UnScheduling Workload")
+	if deploy == nil {
+		return s.GetDeploy(ctx, deployId)
 	}
 
-	return nil
+	return deploy, nil
+}
+
+func (s *basicService) Destroy(ctx context.Context, deployId string, expectedVersion int64, idempotencyKey string) error {
+	request := struct {
+		DeployId        string
+		ExpectedVersion int64
+	}{deployId, expectedVersion}
+
+	_, err := s.idempotent(ctx, idempotencyKey, "Destroy", request, func() (string, error) {
+		return deployId, s.destroy(ctx, deployId, expectedVersion)
+	})
+
+	return err
+}
+
+func (s *basicService) destroy(ctx context.Context, deployId string, expectedVersion int64) error {
+	_, err := s.readModifyWrite(ctx, deployId, expectedVersion, func(deploy *Deploy) error {
+		deploy.Deleted = true
+		deploy.Tasks = append(deploy.Tasks, newTask(ctx, TaskUnScheduleJobs))
+
+		return nil
+	})
+
+	return err
+}
+
+const maxConflictRetries = 3
+
+// readModifyWrite applies modify to the latest version of a deploy and stores it.
+// Concurrent modifications are retried when the caller does not expect a specific version,
+// otherwise they are reported as ErrConflict.
+func (s *basicService) readModifyWrite(ctx context.Context, deployId string, expectedVersion int64, modify func(deploy *Deploy) error) (*Deploy, error) {
+	for attempt := 0; ; attempt++ {
+		deploy, err := s.repository.GetDeploy(ctx, deployId)
+		if err != nil {
+			return nil, errors.Wrap(err, "Retrieving Deploy")
+		}
+
+		if deploy.Deleted {
+			return nil, errors.Wrap(ErrNotFound, "Retrieving Deploy")
+		}
+
+		if _, err := s.visibleProject(ctx, deploy.Project()); err != nil {
+			return nil, errors.Wrap(ErrNotFound, "Retrieving Deploy")
+		}
+
+		if expectedVersion != 0 && deploy.Version != expectedVersion {
+			return nil, errors.Wrapf(ErrConflict, "expected version %d, found %d", expectedVersion, deploy.Version)
+		}
+
+		// modify appends the tasks it needs, the ones already stored may be processed meanwhile
+		stored := len(deploy.Tasks)
+		if err := modify(deploy); err != nil {
+			return nil, err
+		}
+
+		err = s.repository.UpdateDeploy(ctx, deploy, deploy.Tasks[stored:])
+		if err == nil {
+			return deploy, nil
+		}
+
+		if !errors.Is(err, ErrConflict) || expectedVersion != 0 || attempt >= maxConflictRetries {
+			return nil, errors.Wrap(err, "Updating Deploy")
+		}
+	}
 }
 
 func (s *basicService) GetDeploy(ctx context.Context, id string) (*Deploy, error) {
@@ -160,14 +357,130 @@ func (s *basicService) GetDeploy(ctx context.Context, id string) (*Deploy, error
 		return nil, err
 	}
 
+	if deploy.Deleted {
+		return nil, ErrNotFound
+	}
+
+	// deploys of the projects of others are hidden rather than forbidden
+	if _, err := s.visibleProject(ctx, deploy.Project()); err != nil {
+		return nil, ErrNotFound
+	}
+
+	if deploy.Build.Status == StatusQueued {
+		position, err := s.queuePosition(ctx, deploy)
+		if err != nil {
+			return nil, err
+		}
+		deploy.Build.QueuePosition = position
+	}
+
 	return deploy, nil
 }
 
 func (s *basicService) ListDeploys(ctx context.Context) ([]*Deploy, error) {
-	deploys, err := s.repository.ListDeploy(ctx)
+	projects, err := s.callerProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deploys, err := s.repository.ListDeploy(ctx, projects)
 	if err != nil {
 		return nil, err
 	}
 
 	return deploys, nil
 }
+
+func (s *basicService) ListDeadLetters(ctx context.Context, deployId string) ([]*DeadLetter, error) {
+	if deployId != "" {
+		if _, err := s.GetDeploy(ctx, deployId); err != nil {
+			return nil, errors.Wrap(err, "Retrieving Deploy")
+		}
+	}
+
+	letters, err := s.repository.ListDeadLetters(ctx, deployId)
+	if err != nil {
+		return nil, err
+	}
+
+	if deployId != "" {
+		return letters, nil
+	}
+
+	// dead letters of every deploy are filtered by the projects of the caller
+	projects, err := s.callerProjects(ctx)
+	if err != nil || projects == nil {
+		return letters, err
+	}
+
+	deploys, err := s.repository.ListDeploy(ctx, projects)
+	if err != nil {
+		return nil, errors.Wrap(err, "Retrieving Deploys of the caller")
+	}
+	visible := map[string]bool{}
+	for _, deploy := range deploys {
+		visible[deploy.Id] = true
+	}
+
+	var visibleLetters []*DeadLetter
+	for _, letter := range letters {
+		if visible[letter.DeployId] {
+			visibleLetters = append(visibleLetters, letter)
+		}
+	}
+
+	return visibleLetters, nil
+}
+
+// ReplayDeadLetter publishes again a dead letter to the queue of its deploy, which must still be consuming build events,
+// otherwise ErrNotWatched is returned. The letter is deleted only once the broker has accepted it.
+func (s *basicService) ReplayDeadLetter(ctx context.Context, id string) error {
+	letter, err := s.repository.GetDeadLetter(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "Retrieving Dead Letter")
+	}
+
+	projects, err := s.callerProjects(ctx)
+	if err != nil {
+		return err
+	}
+	if projects != nil {
+		if _, err := s.GetDeploy(ctx, letter.DeployId); err != nil {
+			return errors.Wrap(ErrNotFound, "Retrieving Dead Letter")
+		}
+	}
+
+	if err := s.message.ReplayDeadLetter(letter); err != nil {
+		return errors.Wrap(err, "Replaying Dead Letter")
+	}
+
+	if err := s.repository.DeleteDeadLetter(ctx, id); err != nil {
+		return errors.Wrap(err, "Deleting Dead Letter")
+	}
+
+	return nil
+}
+
+// CheckPermission allows everything, the permissions are enforced by AuthorizationMiddleware when it is configured
+func (s *basicService) CheckPermission(ctx context.Context, permission string, projectId string, deployId string, labels map[string]string) (*PermissionCheck, error) {
+	if err := validatePermission(permission); err != nil {
+		return nil, err
+	}
+
+	if projectId != "" {
+		if _, err := s.visibleProject(ctx, projectId); err != nil {
+			return nil, err
+		}
+	}
+
+	if deployId != "" {
+		if _, err := s.GetDeploy(ctx, deployId); err != nil {
+			return nil, errors.Wrap(err, "Retrieving Deploy")
+		}
+	}
+
+	return &PermissionCheck{
+		Allowed: true,
+		Reason:  "authorization is disabled",
+	}, nil
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+func newTask(ctx context.Context, taskType TaskType) *Task {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+
+	now := time.Now()
+	return &Task{
+		Id:            hex.EncodeToString(id),
+		Type:          taskType,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		TraceContext:  traceContext(ctx),
+	}
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName names the tracer of the manager, its spans are named after the layer and the method, e.g. service.Deploy
+const TracerName = "github.com/Scarlet-Fairy/manager"
+
+// TracingMiddleware records a span for every call made within a trace, named after the method.
+// Calls outside of any trace, as the polls of the background workers, are not traced.
+func TracingMiddleware(tracer trace.Tracer) Middleware {
+	return func(service Service) Service {
+		return &tracingMiddleware{
+			next:   service,
+			tracer: tracer,
+		}
+	}
+}
+
+type tracingMiddleware struct {
+	next   Service
+	tracer trace.Tracer
+}
+
+func (t *tracingMiddleware) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	return t.tracer.Start(ctx, "service."+method)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// mapCarrier stores a trace context in a map
+type mapCarrier map[string]string
+
+func (c mapCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c mapCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c mapCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// traceContext returns the trace context of ctx to be stored, e.g. along a task, or nil outside of any trace
+func traceContext(ctx context.Context) map[string]string {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+
+	carrier := mapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	return carrier
+}
+
+// contextWithTrace restores a trace context stored by traceContext, so that the work continues its trace
+func contextWithTrace(ctx context.Context, traceContext map[string]string) context.Context {
+	if len(traceContext) == 0 {
+		return ctx
+	}
+
+	return otel.GetTextMapPropagator().Extract(ctx, mapCarrier(traceContext))
+}
+
+func (t *tracingMiddleware) Deploy(ctx context.Context, projectId string, gitRepo string, name string, envs map[string]string, labels map[string]string, healthCheck *HealthCheck, replicas int, resources Resources, priority int, idempotencyKey string) (deployId string, err error) {
+	ctx, span := t.start(ctx, "Deploy")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.Deploy(ctx, projectId, gitRepo, name, envs, labels, healthCheck, replicas, resources, priority, idempotencyKey)
+}
+
+func (t *tracingMiddleware) HandleEvent(ctx context.Context, event *BuildStep, buildId string) (isDone bool, err error) {
+	ctx, span := t.start(ctx, "HandleEvent")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.HandleEvent(ctx, event, buildId)
+}
+
+func (t *tracingMiddleware) UpdateEnvs(ctx context.Context, deployId string, envs map[string]string, expectedVersion int64, idempotencyKey string) (deploy *Deploy, err error) {
+	ctx, span := t.start(ctx, "UpdateEnvs")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.UpdateEnvs(ctx, deployId, envs, expectedVersion, idempotencyKey)
+}
+
+func (t *tracingMiddleware) Scale(ctx context.Context, deployId string, replicas int, expectedVersion int64, idempotencyKey string) (deploy *Deploy, err error) {
+	ctx, span := t.start(ctx, "Scale")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.Scale(ctx, deployId, replicas, expectedVersion, idempotencyKey)
+}
+
+func (t *tracingMiddleware) Destroy(ctx context.Context, deployId string, expectedVersion int64, idempotencyKey string) (err error) {
+	ctx, span := t.start(ctx, "Destroy")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.Destroy(ctx, deployId, expectedVersion, idempotencyKey)
+}
+
+func (t *tracingMiddleware) GetDeploy(ctx context.Context, id string) (deploy *Deploy, err error) {
+	ctx, span := t.start(ctx, "GetDeploy")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.GetDeploy(ctx, id)
+}
+
+func (t *tracingMiddleware) ListDeploys(ctx context.Context) (deploys []*Deploy, err error) {
+	ctx, span := t.start(ctx, "ListDeploys")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.ListDeploys(ctx)
+}
+
+func (t *tracingMiddleware) ListDeadLetters(ctx context.Context, deployId string) (letters []*DeadLetter, err error) {
+	ctx, span := t.start(ctx, "ListDeadLetters")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.ListDeadLetters(ctx, deployId)
+}
+
+func (t *tracingMiddleware) ReplayDeadLetter(ctx context.Context, id string) (err error) {
+	ctx, span := t.start(ctx, "ReplayDeadLetter")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.ReplayDeadLetter(ctx, id)
+}
+
+func (t *tracingMiddleware) AddDomain(ctx context.Context, deployId string, name string) (domain *Domain, err error) {
+	ctx, span := t.start(ctx, "AddDomain")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.AddDomain(ctx, deployId, name)
+}
+
+func (t *tracingMiddleware) RemoveDomain(ctx context.Context, deployId string, name string) (err error) {
+	ctx, span := t.start(ctx, "RemoveDomain")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.RemoveDomain(ctx, deployId, name)
+}
+
+func (t *tracingMiddleware) ListDomains(ctx context.Context, deployId string) (domains []*Domain, err error) {
+	ctx, span := t.start(ctx, "ListDomains")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.ListDomains(ctx, deployId)
+}
+
+func (t *tracingMiddleware) CheckPermission(ctx context.Context, permission string, projectId string, deployId string, labels map[string]string) (check *PermissionCheck, err error) {
+	ctx, span := t.start(ctx, "CheckPermission")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.CheckPermission(ctx, permission, projectId, deployId, labels)
+}
+
+func (t *tracingMiddleware) CreateProject(ctx context.Context, id string, description string) (project *Project, err error) {
+	ctx, span := t.start(ctx, "CreateProject")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.CreateProject(ctx, id, description)
+}
+
+func (t *tracingMiddleware) GetProject(ctx context.Context, id string) (project *Project, err error) {
+	ctx, span := t.start(ctx, "GetProject")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.GetProject(ctx, id)
+}
+
+func (t *tracingMiddleware) ListProjects(ctx context.Context) (projects []*Project, err error) {
+	ctx, span := t.start(ctx, "ListProjects")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.ListProjects(ctx)
+}
+
+func (t *tracingMiddleware) DeleteProject(ctx context.Context, id string) (err error) {
+	ctx, span := t.start(ctx, "DeleteProject")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.DeleteProject(ctx, id)
+}
+
+func (t *tracingMiddleware) AddProjectMember(ctx context.Context, id string, subject string) (project *Project, err error) {
+	ctx, span := t.start(ctx, "AddProjectMember")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.AddProjectMember(ctx, id, subject)
+}
+
+func (t *tracingMiddleware) RemoveProjectMember(ctx context.Context, id string, subject string) (project *Project, err error) {
+	ctx, span := t.start(ctx, "RemoveProjectMember")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.RemoveProjectMember(ctx, id, subject)
+}
+
+func (t *tracingMiddleware) SetProjectQuota(ctx context.Context, id string, quota *Quota) (project *Project, err error) {
+	ctx, span := t.start(ctx, "SetProjectQuota")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.SetProjectQuota(ctx, id, quota)
+}
+
+func (t *tracingMiddleware) GetQuotaUsage(ctx context.Context, projectId string) (quota *ProjectQuota, err error) {
+	ctx, span := t.start(ctx, "GetQuotaUsage")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.GetQuotaUsage(ctx, projectId)
+}
+
+func (t *tracingMiddleware) ListAuditEvents(ctx context.Context, filter AuditFilter) (events []*AuditEvent, err error) {
+	ctx, span := t.start(ctx, "ListAuditEvents")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return t.next.ListAuditEvents(ctx, filter)
+}
@@ -0,0 +1,494 @@
+package service
+
+import (
+	"context"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	WorkerPollInterval = time.Second
+	WorkerTaskLease    = time.Minute
+	MaxTaskAttempts    = 10
+	// WorkerDrainTimeout is how long a stopped worker waits for the build events and dead letters being handled
+	WorkerDrainTimeout = 30 * time.Second
+	maxTaskBackoff     = time.Minute
+)
+
+// Worker processes the tasks stored along the deploys, performing the scheduler calls with retries.
+// Tasks are claimed with a lease, so a crashed worker releases them after WorkerTaskLease
+// and every side effect is executed at least once.
+type Worker struct {
+	repository Repository
+	message    Message
+	scheduler  Scheduler
+	service    Service
+	logger     log.Logger
+	http       *http.Client
+
+	mu       sync.Mutex
+	watching map[string]func() error
+	stopping bool
+	// handlers counts the build events and dead letters being handled
+	handlers sync.WaitGroup
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewWorker(repository Repository, message Message, scheduler Scheduler, service Service, logger log.Logger) *Worker {
+	return &Worker{
+		repository: repository,
+		message:    message,
+		scheduler:  scheduler,
+		service:    service,
+		logger:     logger,
+		http:       &http.Client{},
+		watching:   map[string]func() error{},
+		stop:       make(chan struct{}),
+	}
+}
+
+func (w *Worker) Run() error {
+	ctx := context.Background()
+
+	if err := w.message.ConsumeBuildEvents(w.handleBuildEvent); err != nil {
+		return errors.Wrap(err, "Consuming build events")
+	}
+
+	if err := w.message.ConsumeDeadLetters(w.storeDeadLetter); err != nil {
+		return errors.Wrap(err, "Consuming dead letters")
+	}
+
+	if err := w.resumeBuilds(ctx); err != nil {
+		return errors.Wrap(err, "Resuming builds in progress")
+	}
+
+	ticker := time.NewTicker(WorkerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			w.drain()
+			return nil
+		case <-ticker.C:
+		}
+
+		for {
+			processed, err := w.processNext(ctx)
+			if err != nil {
+				level.Error(w.logger).Log(
+					"during", "processNext",
+					"err", err,
+				)
+			}
+
+			if !processed {
+				break
+			}
+		}
+	}
+}
+
+// Stop refuses the build events and dead letters delivered from now on with ErrShuttingDown, which the
+// brokers leave unacknowledged so that they are delivered again after the shutdown rather than requeued
+// as failures, while Run returns once the task and the handlers in progress are done
+func (w *Worker) Stop() {
+	w.once.Do(func() {
+		w.mu.Lock()
+		w.stopping = true
+		w.mu.Unlock()
+
+		close(w.stop)
+	})
+}
+
+// beginHandling registers a handler in progress, unless the worker is stopping
+func (w *Worker) beginHandling() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopping {
+		return false
+	}
+	w.handlers.Add(1)
+
+	return true
+}
+
+// drain waits for the handlers in progress, up to WorkerDrainTimeout
+func (w *Worker) drain() {
+	drained := make(chan struct{})
+	go func() {
+		w.handlers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(WorkerDrainTimeout):
+		level.Warn(w.logger).Log(
+			"msg", "gave up waiting for the handlers in progress",
+		)
+	}
+}
+
+// resumeBuilds starts again the consumption of build events for builds interrupted by a restart.
+// Besides the stored builds, it resumes the ones whose scheduling has been attempted: the build may
+// have been scheduled without being stored, and its events would be lost until the task is retried.
+func (w *Worker) resumeBuilds(ctx context.Context) error {
+	deploys, err := w.repository.ListDeploy(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, deploy := range deploys {
+		if deploy.Build.Status != StatusLoading {
+			continue
+		}
+
+		if deploy.Build.JobId != "" || attemptedTask(deploy.Tasks, TaskScheduleImageBuild) {
+			if err := w.watchBuild(deploy.WorkloadId()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// attemptedTask tells whether a task of the given type has been claimed at least once without completing
+func attemptedTask(tasks []*Task, taskType TaskType) bool {
+	for _, task := range tasks {
+		if task.Type == taskType && task.Attempts > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *Worker) processNext(ctx context.Context) (bool, error) {
+	id, task, err := w.repository.ClaimTask(ctx, WorkerTaskLease)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+
+		return false, errors.Wrap(err, "Claiming task")
+	}
+
+	if err := w.traceProcess(ctx, id, task); err != nil {
+		return true, w.fail(ctx, id, task, err)
+	}
+
+	// Completed unschedulations remove the whole deploy along with its tasks
+	if err := w.repository.CompleteTask(ctx, id, task.Id); err != nil && !errors.Is(err, ErrNotFound) {
+		return true, errors.Wrap(err, "Completing task")
+	}
+
+	return true, nil
+}
+
+// traceProcess processes a task in a span, which continues the trace of the call that created the task
+func (w *Worker) traceProcess(ctx context.Context, id string, task *Task) (err error) {
+	ctx, span := otel.Tracer(TracerName).Start(
+		contextWithTrace(ctx, task.TraceContext),
+		"worker."+task.Type.ToString(),
+		trace.WithAttributes(
+			attribute.String("deploy.id", id),
+			attribute.Int("task.attempts", task.Attempts),
+		),
+	)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return w.process(ctx, id, task)
+}
+
+func (w *Worker) process(ctx context.Context, id string, task *Task) error {
+	switch task.Type {
+	case TaskScheduleImageBuild:
+		return w.scheduleImageBuild(ctx, id, task)
+	case TaskScheduleWorkload:
+		return w.scheduleWorkload(ctx, id)
+	case TaskUnScheduleJobs:
+		return w.unScheduleJobs(ctx, id)
+	case TaskProbeWorkload:
+		return w.probeWorkload(ctx, id)
+	default:
+		return errors.Errorf("unknown task type %d", task.Type)
+	}
+}
+
+func (w *Worker) fail(ctx context.Context, id string, task *Task, cause error) error {
+	level.Warn(w.logger).Log(
+		"deployId", id,
+		"task", task.Type.ToString(),
+		"attempts", task.Attempts,
+		"err", cause,
+	)
+
+	// Probes are repeated at a steady pace until the readiness deadline, past which the deploy has failed
+	if task.Type == TaskProbeWorkload {
+		if time.Since(task.CreatedAt) >= WorkloadReadinessTimeout {
+			if err := w.repository.SetWorkloadStatus(ctx, id, WorkloadStatusUnhealthy); err != nil {
+				return errors.Wrap(err, "Settings Workload Status on Unhealthy")
+			}
+
+			if err := w.repository.SetBuildStatus(ctx, id, StatusError); err != nil {
+				return errors.Wrap(err, "Settings Build Status on Error")
+			}
+
+			if err := w.repository.CompleteTask(ctx, id, task.Id); err != nil {
+				return errors.Wrap(err, "Discarding task")
+			}
+
+			return nil
+		}
+
+		if err := w.repository.RetryTask(ctx, id, task.Id, time.Now().Add(probeInterval), cause.Error()); err != nil {
+			return errors.Wrap(err, "Rescheduling task")
+		}
+
+		return nil
+	}
+
+	// The unschedulation is never given up, otherwise jobs of deleted deploys would keep running
+	if task.Attempts >= MaxTaskAttempts && task.Type != TaskUnScheduleJobs {
+		if err := w.repository.SetBuildStatus(ctx, id, StatusError); err != nil {
+			return errors.Wrap(err, "Settings Build Status on Error")
+		}
+
+		if err := w.repository.CompleteTask(ctx, id, task.Id); err != nil {
+			return errors.Wrap(err, "Discarding task")
+		}
+
+		return nil
+	}
+
+	nextAttemptAt := time.Now().Add(taskBackoff(task.Attempts))
+	if err := w.repository.RetryTask(ctx, id, task.Id, nextAttemptAt, cause.Error()); err != nil {
+		return errors.Wrap(err, "Rescheduling task")
+	}
+
+	return nil
+}
+
+func taskBackoff(attempts int) time.Duration {
+	if attempts >= 6 {
+		return maxTaskBackoff
+	}
+
+	return time.Second << uint(attempts)
+}
+
+// scheduleImageBuild names the build after the task, so that the retries of a task which scheduled the build
+// but failed storing it find the build already scheduled, rather than starting another one
+func (w *Worker) scheduleImageBuild(ctx context.Context, id string, task *Task) error {
+	deploy, err := w.repository.GetDeploy(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "Retrieving Deploy")
+	}
+
+	if deploy.Deleted {
+		return nil
+	}
+
+	// Queues must exist before the build starts, otherwise the first events would be lost
+	if err := w.watchBuild(deploy.WorkloadId()); err != nil {
+		return errors.Wrap(err, "Failed to consume events")
+	}
+
+	buildJobName, imageName, err := w.scheduler.ScheduleImageBuild(ctx, deploy.WorkloadId(), task.Id, deploy.GitRepo)
+	if err != nil {
+		return errors.Wrap(err, "Image Build Schedulation")
+	}
+
+	if err := w.repository.InitBuild(ctx, id, buildJobName, buildJobName, imageName); err != nil {
+		return errors.Wrap(err, "Storing build infos")
+	}
+
+	return nil
+}
+
+func (w *Worker) scheduleWorkload(ctx context.Context, id string) error {
+	deploy, err := w.repository.GetDeploy(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "Retrieving Deploy")
+	}
+
+	if deploy.Deleted {
+		return nil
+	}
+
+	domains, err := verifiedDomains(ctx, w.repository, id)
+	if err != nil {
+		return errors.Wrap(err, "Retrieving Domains")
+	}
+
+	jobName, url, err := w.scheduler.ScheduleWorkload(ctx, deploy.Workload.Envs, deploy.WorkloadId(), deploy.Workload.Replicas, deploy.Workload.Resources, domains)
+	if err != nil {
+		return errors.Wrap(err, "Scheduling Workload")
+	}
+
+	if err := w.repository.InitWorkload(ctx, id, jobName, jobName, deploy.Workload.Envs, url); err != nil {
+		return errors.Wrap(err, "Storing workload infos")
+	}
+
+	// Without a health check the workload is trusted to be ready as soon as it is scheduled,
+	// while a workload scaled to zero has nothing to probe
+	if deploy.HealthCheck == nil || deploy.Workload.Replicas == 0 {
+		if err := w.repository.SetWorkloadStatus(ctx, id, WorkloadStatusReady); err != nil {
+			return errors.Wrap(err, "Settings Workload Status on Ready")
+		}
+
+		return nil
+	}
+
+	if err := w.repository.SetWorkloadStatus(ctx, id, WorkloadStatusStarting); err != nil {
+		return errors.Wrap(err, "Settings Workload Status on Starting")
+	}
+
+	if err := w.repository.EnqueueTask(ctx, id, newTask(ctx, TaskProbeWorkload)); err != nil {
+		return errors.Wrap(err, "Enqueuing Workload Probe")
+	}
+
+	return nil
+}
+
+func (w *Worker) probeWorkload(ctx context.Context, id string) error {
+	deploy, err := w.repository.GetDeploy(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "Retrieving Deploy")
+	}
+
+	if deploy.Deleted || deploy.HealthCheck == nil || deploy.Workload.Url == "" {
+		return nil
+	}
+
+	if err := probe(ctx, w.http, deploy.Workload.Url, deploy.HealthCheck); err != nil {
+		return errors.Wrap(err, "Probing Workload")
+	}
+
+	if err := w.repository.SetWorkloadStatus(ctx, id, WorkloadStatusReady); err != nil {
+		return errors.Wrap(err, "Settings Workload Status on Ready")
+	}
+
+	return nil
+}
+
+func (w *Worker) unScheduleJobs(ctx context.Context, id string) error {
+	deploy, err := w.repository.GetDeploy(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "Retrieving Deploy")
+	}
+
+	if deploy.Build.Status == StatusLoading && deploy.Build.JobId != "" {
+		if err := w.scheduler.UnScheduleJob(ctx, deploy.Build.JobId); err != nil {
+			return errors.Wrap(err, "UnScheduling Image Build")
+		}
+
+		if err := w.unwatchBuild(deploy.WorkloadId()); err != nil {
+			return errors.Wrap(err, "Failed to stop consuming events")
+		}
+	}
+
+	if deploy.Workload.JobId != "" {
+		if err := w.scheduler.UnScheduleJob(ctx, deploy.Workload.JobId); err != nil {
+			return errors.Wrap(err, "UnScheduling Workload")
+		}
+	}
+
+	// Domains are released before the deploy, so that they can be attached again
+	if err := w.repository.DeleteDomains(ctx, id); err != nil {
+		return errors.Wrap(err, "Deleting Domains")
+	}
+
+	if err := w.repository.DeleteDeploy(ctx, id); err != nil {
+		return errors.Wrap(err, "Deleting Deploy")
+	}
+
+	return nil
+}
+
+// watchBuild starts the consumption of the build events of a deploy, which are routed by its workload id
+func (w *Worker) watchBuild(id string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.watching[id]; ok {
+		return nil
+	}
+
+	clear, err := w.message.WatchBuild(id)
+	if err != nil {
+		return err
+	}
+	w.watching[id] = clear
+
+	return nil
+}
+
+// handleBuildEvent forwards a build event to the service. Events can come from builds
+// watched by other replicas, so they are handled regardless of the local state.
+func (w *Worker) handleBuildEvent(ctx context.Context, id string, event *BuildStep) (bool, error) {
+	if !w.beginHandling() {
+		return false, ErrShuttingDown
+	}
+	defer w.handlers.Done()
+
+	done, err := w.service.HandleEvent(ctx, event, DeployIdOfWorkload(id))
+	if err == nil && done {
+		// the consumption can't be stopped from within its own handler
+		go func() {
+			if err := w.unwatchBuild(id); err != nil {
+				level.Warn(w.logger).Log(
+					"msg", "failed to stop consuming build events",
+					"id", id,
+					"err", err,
+				)
+			}
+		}()
+	}
+
+	return done, err
+}
+
+// unwatchBuild stops the consumption of build events of a deploy whose build has finished or has been interrupted
+func (w *Worker) unwatchBuild(id string) error {
+	w.mu.Lock()
+	clear, ok := w.watching[id]
+	delete(w.watching, id)
+	w.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return clear()
+}
+
+func (w *Worker) storeDeadLetter(ctx context.Context, letter *DeadLetter) error {
+	if !w.beginHandling() {
+		return ErrShuttingDown
+	}
+	defer w.handlers.Done()
+
+	letter.DeployId = DeployIdOfWorkload(letter.WorkloadId)
+
+	if _, err := w.repository.CreateDeadLetter(ctx, letter); err != nil {
+		return errors.Wrap(err, "Storing Dead Letter")
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	authorizationHeader = "authorization"
+	bearerPrefix        = "bearer "
+)
+
+// publicMethods are served without authentication, the health checks are performed by the orchestrators
+var publicMethods = map[string]bool{
+	"/grpc.health.v1.Health/Check": true,
+}
+
+var (
+	ErrMissingCredentials = errors.New("missing bearer token in the authorization metadata")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator resolves the bearer token of a request to the principal it was issued to
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*service.Principal, error)
+}
+
+// AuthInterceptor rejects with Unauthenticated the requests without a valid bearer token and
+// places the principal of the others in their context
+func AuthInterceptor(authenticator Authenticator, logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if publicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		token, err := bearerToken(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		principal, err := authenticator.Authenticate(ctx, token)
+		if err != nil {
+			logger.Log("method", info.FullMethod, "err", err)
+			// the cause is only logged, it would help guessing valid credentials
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidCredentials.Error())
+		}
+
+		return handler(service.ContextWithPrincipal(ctx, principal), req)
+	}
+}
+
+func bearerToken(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", ErrMissingCredentials
+	}
+
+	for _, value := range md.Get(authorizationHeader) {
+		if len(value) > len(bearerPrefix) && strings.ToLower(value[:len(bearerPrefix)]) == bearerPrefix {
+			return strings.TrimSpace(value[len(bearerPrefix):]), nil
+		}
+	}
+
+	return "", ErrMissingCredentials
+}
+
+type authenticators []Authenticator
+
+// Authenticators tries every authenticator in order, accepting the first principal resolved
+func Authenticators(list ...Authenticator) Authenticator {
+	return authenticators(list)
+}
+
+func (a authenticators) Authenticate(ctx context.Context, token string) (*service.Principal, error) {
+	err := ErrInvalidCredentials
+	for _, authenticator := range a {
+		principal, authErr := authenticator.Authenticate(ctx, token)
+		if authErr == nil {
+			return principal, nil
+		}
+		err = authErr
+	}
+
+	return nil, err
+}
+
+// StaticToken is an API token issued to a principal, as stored in the tokens file
+type StaticToken struct {
+	Token   string   `json:"token"`
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+}
+
+type staticTokenAuthenticator struct {
+	tokens []StaticToken
+}
+
+func NewStaticTokenAuthenticator(tokens []StaticToken) Authenticator {
+	return &staticTokenAuthenticator{
+		tokens: tokens,
+	}
+}
+
+// LoadStaticTokenAuthenticator reads the tokens from a JSON file holding an array of StaticToken
+func LoadStaticTokenAuthenticator(path string) (Authenticator, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Reading tokens file")
+	}
+
+	var tokens []StaticToken
+	if err := json.Unmarshal(content, &tokens); err != nil {
+		return nil, errors.Wrap(err, "Parsing tokens file")
+	}
+
+	for i, token := range tokens {
+		if token.Token == "" || token.Subject == "" {
+			return nil, errors.Errorf("token %d of the tokens file has no token or subject", i)
+		}
+	}
+
+	return NewStaticTokenAuthenticator(tokens), nil
+}
+
+func (s staticTokenAuthenticator) Authenticate(_ context.Context, token string) (*service.Principal, error) {
+	var match *StaticToken
+	// every token is compared, so that the time taken does not reveal which one matched
+	for i := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(s.tokens[i].Token), []byte(token)) == 1 {
+			match = &s.tokens[i]
+		}
+	}
+
+	if match == nil {
+		return nil, errors.Wrap(ErrInvalidCredentials, "unknown api token")
+	}
+
+	return &service.Principal{
+		Subject:    match.Subject,
+		Roles:      match.Roles,
+		AuthMethod: "token",
+	}, nil
+}
@@ -0,0 +1,101 @@
+package grpc
+
+import (
+	"context"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func intercept(t *testing.T, authenticator Authenticator, method string, md metadata.MD) (*service.Principal, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	if md != nil {
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+
+	var principal *service.Principal
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		principal, _ = service.PrincipalFromContext(ctx)
+		return nil, nil
+	}
+
+	_, err := AuthInterceptor(authenticator, log.NewNopLogger())(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+
+	return principal, err
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	authenticator := NewStaticTokenAuthenticator([]StaticToken{
+		{Token: "secret", Subject: "alice", Roles: []string{"developer"}},
+	})
+
+	for _, tc := range []struct {
+		name    string
+		md      metadata.MD
+		want    codes.Code
+		subject string
+	}{
+		{"missing metadata", nil, codes.Unauthenticated, ""},
+		{"missing token", metadata.Pairs("x-request-id", "1"), codes.Unauthenticated, ""},
+		{"other scheme", metadata.Pairs("authorization", "Basic c2VjcmV0"), codes.Unauthenticated, ""},
+		{"unknown token", metadata.Pairs("authorization", "Bearer guess"), codes.Unauthenticated, ""},
+		{"valid token", metadata.Pairs("authorization", "Bearer secret"), codes.OK, "alice"},
+		{"lowercase scheme", metadata.Pairs("authorization", "bearer secret"), codes.OK, "alice"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			principal, err := intercept(t, authenticator, "/protobuf.Manager/GetDeploy", tc.md)
+			if code := status.Code(err); code != tc.want {
+				t.Fatalf("code %s, want %s", code, tc.want)
+			}
+
+			if tc.subject == "" {
+				if principal != nil {
+					t.Errorf("handler called with principal %s", principal.Subject)
+				}
+				return
+			}
+			if principal == nil || principal.Subject != tc.subject {
+				t.Errorf("principal %v, want %s", principal, tc.subject)
+			}
+		})
+	}
+}
+
+func TestAuthInterceptorHidesCause(t *testing.T) {
+	authenticator := NewStaticTokenAuthenticator([]StaticToken{{Token: "secret", Subject: "alice"}})
+
+	_, err := intercept(t, authenticator, "/protobuf.Manager/GetDeploy", metadata.Pairs("authorization", "Bearer guess"))
+	if message := status.Convert(err).Message(); message != ErrInvalidCredentials.Error() {
+		t.Errorf("message %q, want only %q", message, ErrInvalidCredentials.Error())
+	}
+}
+
+func TestAuthInterceptorServesPublicMethods(t *testing.T) {
+	authenticator := NewStaticTokenAuthenticator(nil)
+
+	if _, err := intercept(t, authenticator, "/grpc.health.v1.Health/Check", nil); err != nil {
+		t.Errorf("health check rejected: %v", err)
+	}
+}
+
+func TestAuthenticatorsAcceptFirstPrincipal(t *testing.T) {
+	authenticator := Authenticators(
+		NewStaticTokenAuthenticator([]StaticToken{{Token: "first", Subject: "alice"}}),
+		NewStaticTokenAuthenticator([]StaticToken{{Token: "second", Subject: "bob"}}),
+	)
+
+	principal, err := authenticator.Authenticate(context.Background(), "second")
+	if err != nil || principal.Subject != "bob" {
+		t.Errorf("Authenticate returned %v, %v, want bob", principal, err)
+	}
+
+	if _, err := authenticator.Authenticate(context.Background(), "third"); err == nil {
+		t.Error("unknown token accepted")
+	}
+}
@@ -3,6 +3,8 @@ package grpc
 import (
 	"github.com/Scarlet-Fairy/manager/pb"
 	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func transportDeployToCoreDeploy(deploy *pb.Deploy) *service.Deploy {
@@ -31,6 +33,7 @@ func transportDeployToCoreDeploy(deploy *pb.Deploy) *service.Deploy {
 			Envs:    deploy.Workload.Envs,
 			Url:     deploy.Workload.Url,
 		},
+		Version: deploy.Version,
 	}
 }
 
@@ -43,21 +46,175 @@ func coreDeployToTransportDeploy(deploy *service.Deploy) *pb.Deploy {
 		})
 	}
 
+	build := &pb.Build{
+		JobId:         deploy.Build.JobId,
+		JobName:       deploy.Build.JobName,
+		Status:        pb.Build_Status(deploy.Build.Status),
+		Steps:         buildSteps,
+		Priority:      int32(deploy.Build.Priority),
+		QueuePosition: int32(deploy.Build.QueuePosition),
+	}
+	// deploys created before the build queue have never been queued
+	if !deploy.Build.QueuedAt.IsZero() {
+		build.QueuedAt = timestamppb.New(deploy.Build.QueuedAt)
+	}
+
 	return &pb.Deploy{
-		Id:      deploy.Id,
-		Name:    deploy.Name,
-		GitRepo: deploy.GitRepo,
-		Build: &pb.Build{
-			JobId:   deploy.Build.JobId,
-			JobName: deploy.Build.JobName,
-			Status:  pb.Build_Status(deploy.Build.Status),
-			Steps:   buildSteps,
-		},
+		Id:        deploy.Id,
+		ProjectId: deploy.Project(),
+		Name:      deploy.Name,
+		GitRepo:   deploy.GitRepo,
+		Labels:    deploy.Labels,
+		Build:     build,
 		Workload: &pb.Workload{
-			JobId:   deploy.Workload.JobId,
-			JobName: deploy.Workload.JobName,
-			Envs:    deploy.Workload.Envs,
-			Url:     deploy.Workload.Url,
+			JobId:     deploy.Workload.JobId,
+			JobName:   deploy.Workload.JobName,
+			Envs:      deploy.Workload.Envs,
+			Url:       deploy.Workload.Url,
+			Health:    coreWorkloadHealthToTransportWorkloadHealth(deploy.Workload.Health),
+			Status:    pb.Workload_Status(deploy.Workload.Status),
+			Replicas:  int32(deploy.Workload.Replicas),
+			Resources: coreResourcesToTransportResources(deploy.Workload.Resources),
 		},
+		Version:     deploy.Version,
+		HealthCheck: coreHealthCheckToTransportHealthCheck(deploy.HealthCheck),
+	}
+}
+
+func transportResourcesToCoreResources(resources *pb.Resources) service.Resources {
+	if resources == nil {
+		return service.Resources{}
+	}
+
+	return service.Resources{
+		CpuRequestMillis:   resources.CpuRequestMillis,
+		CpuLimitMillis:     resources.CpuLimitMillis,
+		MemoryRequestBytes: resources.MemoryRequestBytes,
+		MemoryLimitBytes:   resources.MemoryLimitBytes,
+	}
+}
+
+func coreResourcesToTransportResources(resources service.Resources) *pb.Resources {
+	return &pb.Resources{
+		CpuRequestMillis:   resources.CpuRequestMillis,
+		CpuLimitMillis:     resources.CpuLimitMillis,
+		MemoryRequestBytes: resources.MemoryRequestBytes,
+		MemoryLimitBytes:   resources.MemoryLimitBytes,
+	}
+}
+
+func transportHealthCheckToCoreHealthCheck(healthCheck *pb.HealthCheck) *service.HealthCheck {
+	if healthCheck == nil {
+		return nil
+	}
+
+	return &service.HealthCheck{
+		Path:           healthCheck.Path,
+		ExpectedStatus: int(healthCheck.ExpectedStatus),
+		Timeout:        healthCheck.Timeout.AsDuration(),
+	}
+}
+
+func coreHealthCheckToTransportHealthCheck(healthCheck *service.HealthCheck) *pb.HealthCheck {
+	if healthCheck == nil {
+		return nil
+	}
+
+	return &pb.HealthCheck{
+		Path:           healthCheck.Path,
+		ExpectedStatus: int32(healthCheck.ExpectedStatus),
+		Timeout:        durationpb.New(healthCheck.Timeout),
+	}
+}
+
+func coreWorkloadHealthToTransportWorkloadHealth(health service.WorkloadHealth) *pb.Workload_Health {
+	if health.CheckedAt.IsZero() {
+		return nil
+	}
+
+	return &pb.Workload_Health{
+		State:        pb.Workload_Health_State(health.State),
+		RestartCount: int32(health.Restarts),
+		Missing:      health.Missing,
+		Message:      health.Message,
+		CheckedAt:    timestamppb.New(health.CheckedAt),
+	}
+}
+
+func coreDeadLetterToTransportDeadLetter(letter *service.DeadLetter) *pb.DeadLetter {
+	return &pb.DeadLetter{
+		Id:       letter.Id,
+		DeployId: letter.DeployId,
+		Body:     letter.Body,
+		Reason:   letter.Reason,
+		Attempts: int32(letter.Attempts),
+		DeadAt:   timestamppb.New(letter.DeadAt),
+	}
+}
+
+func coreDomainToTransportDomain(domain *service.Domain) *pb.Domain {
+	result := &pb.Domain{
+		Name:            domain.Name,
+		DeployId:        domain.DeployId,
+		Verified:        domain.Verified,
+		ChallengeRecord: domain.ChallengeRecord(),
+		ChallengeValue:  domain.Token,
+		CreatedAt:       timestamppb.New(domain.CreatedAt),
+	}
+	if domain.Verified {
+		result.VerifiedAt = timestamppb.New(domain.VerifiedAt)
+	}
+
+	return result
+}
+
+func coreProjectToTransportProject(project *service.Project) *pb.Project {
+	return &pb.Project{
+		Id:          project.Id,
+		Description: project.Description,
+		Members:     project.Members,
+		Quota:       coreQuotaToTransportQuota(project.Quota),
+		CreatedAt:   timestamppb.New(project.CreatedAt),
+	}
+}
+
+func transportQuotaToCoreQuota(quota *pb.Quota) *service.Quota {
+	if quota == nil {
+		return nil
+	}
+
+	return &service.Quota{
+		MaxDeploys:          int(quota.MaxDeploys),
+		MaxConcurrentBuilds: int(quota.MaxConcurrentBuilds),
+		MaxReplicas:         int(quota.MaxReplicas),
+		MaxMemoryBytes:      quota.MaxMemoryBytes,
+	}
+}
+
+func coreQuotaToTransportQuota(quota *service.Quota) *pb.Quota {
+	if quota == nil {
+		return nil
+	}
+
+	return &pb.Quota{
+		MaxDeploys:          int32(quota.MaxDeploys),
+		MaxConcurrentBuilds: int32(quota.MaxConcurrentBuilds),
+		MaxReplicas:         int32(quota.MaxReplicas),
+		MaxMemoryBytes:      quota.MaxMemoryBytes,
+	}
+}
+
+func coreAuditEventToTransportAuditEvent(event *service.AuditEvent) *pb.AuditEvent {
+	return &pb.AuditEvent{
+		Id:         event.Id,
+		Actor:      event.Actor,
+		AuthMethod: event.AuthMethod,
+		Method:     event.Method,
+		DeployId:   event.DeployId,
+		ProjectId:  event.ProjectId,
+		Request:    event.Request,
+		Outcome:    event.Outcome,
+		Error:      event.Error,
+		OccurredAt: timestamppb.New(event.OccurredAt),
 	}
 }
@@ -0,0 +1,45 @@
+package grpc
+
+import (
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func encodeError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, service.ErrIdempotencyKeyMismatch):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrInvalidHealthCheck):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrInvalidWorkload):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrInvalidDomain):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrInvalidProject):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrProjectNotEmpty):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, service.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrInvalidPermission):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrInvalidAuditFilter):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrRequestInProgress):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, service.ErrNotWatched):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return err
+	}
+}
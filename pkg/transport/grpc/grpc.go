@@ -12,9 +12,31 @@ import (
 type grpcServer struct {
 	pb.UnimplementedManagerServer
 	deploy      grpctransport.Handler
+	updateEnvs  grpctransport.Handler
+	scale       grpctransport.Handler
 	destroy     grpctransport.Handler
 	getDeploy   grpctransport.Handler
 	listDeploys grpctransport.Handler
+
+	listDeadLetters  grpctransport.Handler
+	replayDeadLetter grpctransport.Handler
+
+	addDomain    grpctransport.Handler
+	removeDomain grpctransport.Handler
+	listDomains  grpctransport.Handler
+
+	checkPermission grpctransport.Handler
+
+	createProject       grpctransport.Handler
+	getProject          grpctransport.Handler
+	listProjects        grpctransport.Handler
+	deleteProject       grpctransport.Handler
+	addProjectMember    grpctransport.Handler
+	removeProjectMember grpctransport.Handler
+	setProjectQuota     grpctransport.Handler
+	getQuotaUsage       grpctransport.Handler
+
+	listAuditEvents grpctransport.Handler
 }
 
 func NewGRPCServer(endpoints endpoint.ManagerEndpoint, logger log.Logger) pb.ManagerServer {
@@ -29,6 +51,18 @@ func NewGRPCServer(endpoints endpoint.ManagerEndpoint, logger log.Logger) pb.Man
 			encodeDeployResponse,
 			options...,
 		),
+		updateEnvs: grpctransport.NewServer(
+			endpoints.UpdateEnvsEndpoint,
+			decodeUpdateEnvsRequest,
+			encodeUpdateEnvsResponse,
+			options...,
+		),
+		scale: grpctransport.NewServer(
+			endpoints.ScaleEndpoint,
+			decodeScaleRequest,
+			encodeScaleResponse,
+			options...,
+		),
 		destroy: grpctransport.NewServer(
 			endpoints.DestroyEndpoint,
 			decodeDestroyRequest,
@@ -47,22 +81,130 @@ func NewGRPCServer(endpoints endpoint.ManagerEndpoint, logger log.Logger) pb.Man
 			encodeListDeploysResponse,
 			options...,
 		),
+		listDeadLetters: grpctransport.NewServer(
+			endpoints.ListDeadLettersEndpoint,
+			decodeListDeadLettersRequest,
+			encodeListDeadLettersResponse,
+			options...,
+		),
+		replayDeadLetter: grpctransport.NewServer(
+			endpoints.ReplayDeadLetterEndpoint,
+			decodeReplayDeadLetterRequest,
+			encodeReplayDeadLetterResponse,
+			options...,
+		),
+		addDomain: grpctransport.NewServer(
+			endpoints.AddDomainEndpoint,
+			decodeAddDomainRequest,
+			encodeAddDomainResponse,
+			options...,
+		),
+		removeDomain: grpctransport.NewServer(
+			endpoints.RemoveDomainEndpoint,
+			decodeRemoveDomainRequest,
+			encodeRemoveDomainResponse,
+			options...,
+		),
+		listDomains: grpctransport.NewServer(
+			endpoints.ListDomainsEndpoint,
+			decodeListDomainsRequest,
+			encodeListDomainsResponse,
+			options...,
+		),
+		checkPermission: grpctransport.NewServer(
+			endpoints.CheckPermissionEndpoint,
+			decodeCheckPermissionRequest,
+			encodeCheckPermissionResponse,
+			options...,
+		),
+		createProject: grpctransport.NewServer(
+			endpoints.CreateProjectEndpoint,
+			decodeCreateProjectRequest,
+			encodeCreateProjectResponse,
+			options...,
+		),
+		getProject: grpctransport.NewServer(
+			endpoints.GetProjectEndpoint,
+			decodeGetProjectRequest,
+			encodeGetProjectResponse,
+			options...,
+		),
+		listProjects: grpctransport.NewServer(
+			endpoints.ListProjectsEndpoint,
+			decodeListProjectsRequest,
+			encodeListProjectsResponse,
+			options...,
+		),
+		deleteProject: grpctransport.NewServer(
+			endpoints.DeleteProjectEndpoint,
+			decodeDeleteProjectRequest,
+			encodeDeleteProjectResponse,
+			options...,
+		),
+		addProjectMember: grpctransport.NewServer(
+			endpoints.AddProjectMemberEndpoint,
+			decodeAddProjectMemberRequest,
+			encodeAddProjectMemberResponse,
+			options...,
+		),
+		removeProjectMember: grpctransport.NewServer(
+			endpoints.RemoveProjectMemberEndpoint,
+			decodeRemoveProjectMemberRequest,
+			encodeRemoveProjectMemberResponse,
+			options...,
+		),
+		setProjectQuota: grpctransport.NewServer(
+			endpoints.SetProjectQuotaEndpoint,
+			decodeSetProjectQuotaRequest,
+			encodeSetProjectQuotaResponse,
+			options...,
+		),
+		getQuotaUsage: grpctransport.NewServer(
+			endpoints.GetQuotaUsageEndpoint,
+			decodeGetQuotaUsageRequest,
+			encodeGetQuotaUsageResponse,
+			options...,
+		),
+		listAuditEvents: grpctransport.NewServer(
+			endpoints.ListAuditEventsEndpoint,
+			decodeListAuditEventsRequest,
+			encodeListAuditEventsResponse,
+			options...,
+		),
 	}
 }
 
 func (g grpcServer) Deploy(ctx context.Context, request *pb.DeployRequest) (*pb.DeployResponse, error) {
 	_, resp, err := g.deploy.ServeGRPC(ctx, request)
 	if err != nil {
-		return nil, err
+		return nil, encodeError(err)
 	}
 
 	return resp.(*pb.DeployResponse), nil
 }
 
+func (g grpcServer) UpdateEnvs(ctx context.Context, request *pb.UpdateEnvsRequest) (*pb.UpdateEnvsResponse, error) {
+	_, resp, err := g.updateEnvs.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.UpdateEnvsResponse), nil
+}
+
+func (g grpcServer) Scale(ctx context.Context, request *pb.ScaleRequest) (*pb.ScaleResponse, error) {
+	_, resp, err := g.scale.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.ScaleResponse), nil
+}
+
 func (g grpcServer) Destroy(ctx context.Context, request *pb.DestroyRequest) (*pb.DestroyResponse, error) {
 	_, resp, err := g.destroy.ServeGRPC(ctx, request)
 	if err != nil {
-		return nil, err
+		return nil, encodeError(err)
 	}
 
 	return resp.(*pb.DestroyResponse), nil
@@ -71,7 +213,7 @@ func (g grpcServer) Destroy(ctx context.Context, request *pb.DestroyRequest) (*p
 func (g grpcServer) GetDeploy(ctx context.Context, request *pb.GetDeployRequest) (*pb.GetDeployResponse, error) {
 	_, resp, err := g.getDeploy.ServeGRPC(ctx, request)
 	if err != nil {
-		return nil, err
+		return nil, encodeError(err)
 	}
 
 	return resp.(*pb.GetDeployResponse), nil
@@ -80,8 +222,143 @@ func (g grpcServer) GetDeploy(ctx context.Context, request *pb.GetDeployRequest)
 func (g grpcServer) ListDeploys(ctx context.Context, request *pb.ListDeploysRequest) (*pb.ListDeploysResponse, error) {
 	_, resp, err := g.listDeploys.ServeGRPC(ctx, request)
 	if err != nil {
-		return nil, err
+		return nil, encodeError(err)
 	}
 
 	return resp.(*pb.ListDeploysResponse), nil
 }
+
+func (g grpcServer) ListDeadLetters(ctx context.Context, request *pb.ListDeadLettersRequest) (*pb.ListDeadLettersResponse, error) {
+	_, resp, err := g.listDeadLetters.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.ListDeadLettersResponse), nil
+}
+
+func (g grpcServer) ReplayDeadLetter(ctx context.Context, request *pb.ReplayDeadLetterRequest) (*pb.ReplayDeadLetterResponse, error) {
+	_, resp, err := g.replayDeadLetter.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.ReplayDeadLetterResponse), nil
+}
+
+func (g grpcServer) AddDomain(ctx context.Context, request *pb.AddDomainRequest) (*pb.AddDomainResponse, error) {
+	_, resp, err := g.addDomain.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.AddDomainResponse), nil
+}
+
+func (g grpcServer) RemoveDomain(ctx context.Context, request *pb.RemoveDomainRequest) (*pb.RemoveDomainResponse, error) {
+	_, resp, err := g.removeDomain.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.RemoveDomainResponse), nil
+}
+
+func (g grpcServer) ListDomains(ctx context.Context, request *pb.ListDomainsRequest) (*pb.ListDomainsResponse, error) {
+	_, resp, err := g.listDomains.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.ListDomainsResponse), nil
+}
+
+func (g grpcServer) CheckPermission(ctx context.Context, request *pb.CheckPermissionRequest) (*pb.CheckPermissionResponse, error) {
+	_, resp, err := g.checkPermission.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.CheckPermissionResponse), nil
+}
+
+func (g grpcServer) CreateProject(ctx context.Context, request *pb.CreateProjectRequest) (*pb.CreateProjectResponse, error) {
+	_, resp, err := g.createProject.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.CreateProjectResponse), nil
+}
+
+func (g grpcServer) GetProject(ctx context.Context, request *pb.GetProjectRequest) (*pb.GetProjectResponse, error) {
+	_, resp, err := g.getProject.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.GetProjectResponse), nil
+}
+
+func (g grpcServer) ListProjects(ctx context.Context, request *pb.ListProjectsRequest) (*pb.ListProjectsResponse, error) {
+	_, resp, err := g.listProjects.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.ListProjectsResponse), nil
+}
+
+func (g grpcServer) DeleteProject(ctx context.Context, request *pb.DeleteProjectRequest) (*pb.DeleteProjectResponse, error) {
+	_, resp, err := g.deleteProject.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.DeleteProjectResponse), nil
+}
+
+func (g grpcServer) AddProjectMember(ctx context.Context, request *pb.AddProjectMemberRequest) (*pb.AddProjectMemberResponse, error) {
+	_, resp, err := g.addProjectMember.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.AddProjectMemberResponse), nil
+}
+
+func (g grpcServer) RemoveProjectMember(ctx context.Context, request *pb.RemoveProjectMemberRequest) (*pb.RemoveProjectMemberResponse, error) {
+	_, resp, err := g.removeProjectMember.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.RemoveProjectMemberResponse), nil
+}
+
+func (g grpcServer) SetProjectQuota(ctx context.Context, request *pb.SetProjectQuotaRequest) (*pb.SetProjectQuotaResponse, error) {
+	_, resp, err := g.setProjectQuota.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.SetProjectQuotaResponse), nil
+}
+
+func (g grpcServer) GetQuotaUsage(ctx context.Context, request *pb.GetQuotaUsageRequest) (*pb.GetQuotaUsageResponse, error) {
+	_, resp, err := g.getQuotaUsage.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.GetQuotaUsageResponse), nil
+}
+
+func (g grpcServer) ListAuditEvents(ctx context.Context, request *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	_, resp, err := g.listAuditEvents.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return resp.(*pb.ListAuditEventsResponse), nil
+}
@@ -0,0 +1,114 @@
+package grpc
+
+import (
+	"context"
+	"github.com/Scarlet-Fairy/manager/pb"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultHealthInterval = 10 * time.Second
+	DefaultHealthTimeout  = 5 * time.Second
+)
+
+// DependencyCheck returns an error when a dependency of the manager, e.g. mongodb, cannot be reached
+type DependencyCheck func(ctx context.Context) error
+
+// HealthReporter runs the dependency checks periodically and reports their outcome through the grpc.health.v1 service.
+// Every dependency is reported as a service of its own, named after it, while the server as a whole,
+// i.e. the empty service name and the Manager service, is serving only when every dependency is.
+type HealthReporter struct {
+	server   *health.Server
+	checks   map[string]DependencyCheck
+	interval time.Duration
+	timeout  time.Duration
+	logger   log.Logger
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewHealthReporter(server *health.Server, checks map[string]DependencyCheck, interval time.Duration, timeout time.Duration, logger log.Logger) *HealthReporter {
+	// nothing is serving until the dependencies have been checked
+	for name := range checks {
+		server.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	server.SetServingStatus(pb.Manager_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	return &HealthReporter{
+		server:   server,
+		checks:   checks,
+		interval: interval,
+		timeout:  timeout,
+		logger:   logger,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (h *HealthReporter) Run() error {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.check()
+
+		select {
+		case <-h.stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop reports every service as not serving for good, so that no new requests are routed to the server while it drains
+func (h *HealthReporter) Stop() {
+	h.once.Do(func() {
+		close(h.stop)
+		h.server.Shutdown()
+	})
+}
+
+func (h *HealthReporter) check() {
+	results := make(map[string]error, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func(name string, check DependencyCheck) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+			defer cancel()
+			err := check(ctx)
+
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	// once shut down, the health server ignores the statuses set
+	overall := healthpb.HealthCheckResponse_SERVING
+	for name, err := range results {
+		status := healthpb.HealthCheckResponse_SERVING
+		if err != nil {
+			level.Warn(h.logger).Log(
+				"msg", "dependency check failed",
+				"dependency", name,
+				"err", err,
+			)
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+			overall = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		h.server.SetServingStatus(name, status)
+	}
+	h.server.SetServingStatus("", overall)
+	h.server.SetServingStatus(pb.Manager_ServiceDesc.ServiceName, overall)
+}
@@ -0,0 +1,205 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"math/big"
+	"time"
+)
+
+const DefaultRolesClaim = "roles"
+
+type JWTConfig struct {
+	// when set, the iss claim of the tokens must match
+	Issuer string
+	// when set, the aud claim of the tokens must contain it
+	Audience string
+	// claim holding the roles of the principal, DefaultRolesClaim when empty
+	RolesClaim string
+}
+
+// JWKS holds the keys the tokens are verified with, by kid: RSA public keys for RS256/384/512
+// and symmetric secrets for HS256/384/512
+type JWKS struct {
+	rsaKeys  map[string]*rsa.PublicKey
+	hmacKeys map[string][]byte
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	// RSA modulus and exponent
+	N string `json:"n"`
+	E string `json:"e"`
+	// symmetric secret
+	K string `json:"k"`
+}
+
+// LoadJWKS reads a JSON Web Key Set file, only RSA and oct keys are supported
+func LoadJWKS(path string) (*JWKS, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Reading JWKS file")
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, errors.Wrap(err, "Parsing JWKS file")
+	}
+
+	jwks := &JWKS{
+		rsaKeys:  map[string]*rsa.PublicKey{},
+		hmacKeys: map[string][]byte{},
+	}
+	for i, key := range set.Keys {
+		switch key.Kty {
+		case "RSA":
+			publicKey, err := rsaPublicKey(key)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Parsing RSA key %d of the JWKS file", i)
+			}
+			jwks.rsaKeys[key.Kid] = publicKey
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(key.K)
+			if err != nil || len(secret) == 0 {
+				return nil, errors.Errorf("invalid secret of oct key %d of the JWKS file", i)
+			}
+			jwks.hmacKeys[key.Kid] = secret
+		default:
+			return nil, errors.Errorf("unsupported kty %q of key %d of the JWKS file", key.Kty, i)
+		}
+	}
+
+	return jwks, nil
+}
+
+func rsaPublicKey(key jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, errors.Wrap(err, "Decoding modulus")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, errors.Wrap(err, "Decoding exponent")
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 {
+		return nil, errors.New("invalid modulus or exponent")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
+
+// lookup returns the key with the given kid, or the only key of the set when the token has no kid
+func lookup(keys map[string]interface{}, kid string) (interface{}, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+func (j *JWKS) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	keys := map[string]interface{}{}
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA:
+		for id, key := range j.rsaKeys {
+			keys[id] = key
+		}
+	case *jwt.SigningMethodHMAC:
+		for id, key := range j.hmacKeys {
+			keys[id] = key
+		}
+	default:
+		return nil, errors.Errorf("unsupported signing method %s", token.Method.Alg())
+	}
+
+	key, ok := lookup(keys, kid)
+	if !ok {
+		return nil, errors.Errorf("no %s key with kid %q", token.Method.Alg(), kid)
+	}
+
+	return key, nil
+}
+
+type jwtAuthenticator struct {
+	jwks   *JWKS
+	config JWTConfig
+	parser *jwt.Parser
+}
+
+func NewJWTAuthenticator(jwks *JWKS, config JWTConfig) Authenticator {
+	if config.RolesClaim == "" {
+		config.RolesClaim = DefaultRolesClaim
+	}
+
+	return &jwtAuthenticator{
+		jwks:   jwks,
+		config: config,
+		parser: jwt.NewParser(jwt.WithValidMethods([]string{
+			"RS256", "RS384", "RS512", "HS256", "HS384", "HS512",
+		})),
+	}
+}
+
+func (j jwtAuthenticator) Authenticate(_ context.Context, tokenString string) (*service.Principal, error) {
+	claims := jwt.MapClaims{}
+	// the parser validates exp and nbf only when the token has them
+	if _, err := j.parser.ParseWithClaims(tokenString, claims, j.jwks.keyFunc); err != nil {
+		return nil, errors.Wrap(ErrInvalidCredentials, err.Error())
+	}
+
+	// tokens without exp would never expire
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.Wrap(ErrInvalidCredentials, "missing or past expiration")
+	}
+
+	if j.config.Issuer != "" && !claims.VerifyIssuer(j.config.Issuer, true) {
+		return nil, errors.Wrap(ErrInvalidCredentials, "unexpected issuer")
+	}
+	if j.config.Audience != "" && !claims.VerifyAudience(j.config.Audience, true) {
+		return nil, errors.Wrap(ErrInvalidCredentials, "unexpected audience")
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.Wrap(ErrInvalidCredentials, "missing subject")
+	}
+
+	var roles []string
+	switch claim := claims[j.config.RolesClaim].(type) {
+	case string:
+		roles = []string{claim}
+	case []interface{}:
+		for _, role := range claim {
+			if role, ok := role.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	return &service.Principal{
+		Subject:    subject,
+		Roles:      roles,
+		AuthMethod: "jwt",
+	}, nil
+}
@@ -0,0 +1,224 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// writeJWKS stores a key set holding the public part of rsaKey as "rsa-1" and hmacSecret as "oct-1"
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey) string {
+	t.Helper()
+
+	encode := base64.RawURLEncoding.EncodeToString
+	set := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa-1",
+				"n":   encode(rsaKey.N.Bytes()),
+				"e":   encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "oct",
+				"kid": "oct-1",
+				"k":   encode(hmacSecret),
+			},
+		},
+	}
+
+	return writeFile(t, set)
+}
+
+func writeFile(t *testing.T, content interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		t.Fatalf("encoding file: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+
+	return path
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+
+	return key
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "alice",
+		"iss":   "https://issuer.example.com",
+		"aud":   "manager",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"developer", "viewer"},
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+
+	return signed
+}
+
+func newAuthenticator(t *testing.T, rsaKey *rsa.PrivateKey) Authenticator {
+	t.Helper()
+
+	jwks, err := LoadJWKS(writeJWKS(t, rsaKey))
+	if err != nil {
+		t.Fatalf("LoadJWKS: %v", err)
+	}
+
+	return NewJWTAuthenticator(jwks, JWTConfig{
+		Issuer:   "https://issuer.example.com",
+		Audience: "manager",
+	})
+}
+
+func TestLoadJWKSRejectsInvalidKeys(t *testing.T) {
+	for name, key := range map[string]map[string]string{
+		"unsupported kty": {"kty": "EC", "kid": "ec-1"},
+		"empty secret":    {"kty": "oct", "kid": "oct-1", "k": ""},
+		"invalid secret":  {"kty": "oct", "kid": "oct-1", "k": "not base64!"},
+		"empty modulus":   {"kty": "RSA", "kid": "rsa-1", "n": "", "e": "AQAB"},
+		"small exponent":  {"kty": "RSA", "kid": "rsa-1", "n": "AQAB", "e": "AQ"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := writeFile(t, map[string]interface{}{"keys": []map[string]string{key}})
+			if _, err := LoadJWKS(path); err == nil {
+				t.Error("invalid key loaded")
+			}
+		})
+	}
+
+	if _, err := LoadJWKS(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file loaded")
+	}
+}
+
+func TestJWTAuthenticatorAcceptsValidTokens(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	authenticator := newAuthenticator(t, rsaKey)
+
+	for name, token := range map[string]string{
+		"RS256": sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims()),
+		"HS256": sign(t, jwt.SigningMethodHS256, "oct-1", hmacSecret, validClaims()),
+		// the only key of the algorithm is used for the tokens without kid
+		"RS512 without kid": sign(t, jwt.SigningMethodRS512, "", rsaKey, validClaims()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(context.Background(), token)
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+
+			if principal.Subject != "alice" || principal.AuthMethod != "jwt" {
+				t.Errorf("principal %s authenticated by %s", principal.Subject, principal.AuthMethod)
+			}
+			if len(principal.Roles) != 2 || principal.Roles[0] != "developer" || principal.Roles[1] != "viewer" {
+				t.Errorf("roles %v", principal.Roles)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorRejectsInvalidTokens(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	otherKey := newRSAKey(t)
+	authenticator := newAuthenticator(t, rsaKey)
+
+	with := func(change func(claims jwt.MapClaims)) jwt.MapClaims {
+		claims := validClaims()
+		change(claims)
+		return claims
+	}
+
+	for name, token := range map[string]string{
+		"missing exp": sign(t, jwt.SigningMethodHS256, "oct-1", hmacSecret, with(func(claims jwt.MapClaims) {
+			delete(claims, "exp")
+		})),
+		"expired": sign(t, jwt.SigningMethodHS256, "oct-1", hmacSecret, with(func(claims jwt.MapClaims) {
+			claims["exp"] = time.Now().Add(-time.Minute).Unix()
+		})),
+		"not yet valid": sign(t, jwt.SigningMethodHS256, "oct-1", hmacSecret, with(func(claims jwt.MapClaims) {
+			claims["nbf"] = time.Now().Add(time.Hour).Unix()
+		})),
+		"other issuer": sign(t, jwt.SigningMethodHS256, "oct-1", hmacSecret, with(func(claims jwt.MapClaims) {
+			claims["iss"] = "https://attacker.example.com"
+		})),
+		"other audience": sign(t, jwt.SigningMethodHS256, "oct-1", hmacSecret, with(func(claims jwt.MapClaims) {
+			claims["aud"] = "billing"
+		})),
+		"missing subject": sign(t, jwt.SigningMethodHS256, "oct-1", hmacSecret, with(func(claims jwt.MapClaims) {
+			delete(claims, "sub")
+		})),
+		"unknown kid":    sign(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, validClaims()),
+		"other key":      sign(t, jwt.SigningMethodRS256, "rsa-1", otherKey, validClaims()),
+		"wrong secret":   sign(t, jwt.SigningMethodHS256, "oct-1", []byte("guessed"), validClaims()),
+		"none algorithm": sign(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, validClaims()),
+		// the public RSA key used as HMAC secret, it is looked up among the oct keys only
+		"algorithm confusion": sign(t, jwt.SigningMethodHS256, "rsa-1", rsaKey.PublicKey.N.Bytes(), validClaims()),
+		"malformed":           "not.a.token",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := authenticator.Authenticate(context.Background(), token)
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Authenticate returned %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorReadsConfiguredRolesClaim(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	jwks, err := LoadJWKS(writeJWKS(t, rsaKey))
+	if err != nil {
+		t.Fatalf("LoadJWKS: %v", err)
+	}
+	authenticator := NewJWTAuthenticator(jwks, JWTConfig{RolesClaim: "groups"})
+
+	claims := validClaims()
+	claims["groups"] = service.AdminRole
+	principal, err := authenticator.Authenticate(context.Background(), sign(t, jwt.SigningMethodHS256, "oct-1", hmacSecret, claims))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	if !principal.IsAdmin() || len(principal.Roles) != 1 {
+		t.Errorf("roles %v, want only the admin one from the groups claim", principal.Roles)
+	}
+}
@@ -13,9 +13,16 @@ func decodeDeployRequest(_ context.Context, grpcReq interface{}) (interface{}, e
 	}
 
 	return &endpoint.DeployRequest{
-		GitRepo: req.GitRepo,
-		Name:    req.Name,
-		Envs:    req.Envs,
+		ProjectId:      req.ProjectId,
+		GitRepo:        req.GitRepo,
+		Name:           req.Name,
+		Envs:           req.Envs,
+		Labels:         req.Labels,
+		HealthCheck:    transportHealthCheckToCoreHealthCheck(req.HealthCheck),
+		Replicas:       int(req.Replicas),
+		Resources:      transportResourcesToCoreResources(req.Resources),
+		Priority:       int(req.Priority),
+		IdempotencyKey: req.IdempotencyKey,
 	}, nil
 }
 
@@ -27,11 +34,54 @@ func encodeDeployResponse(_ context.Context, resp interface{}) (interface{}, err
 	}, nil
 }
 
+func decodeUpdateEnvsRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.UpdateEnvsRequest)
+	if req.Envs == nil {
+		req.Envs = map[string]string{}
+	}
+
+	return &endpoint.UpdateEnvsRequest{
+		Id:              req.DeployId,
+		Envs:            req.Envs,
+		ExpectedVersion: req.ExpectedVersion,
+		IdempotencyKey:  req.IdempotencyKey,
+	}, nil
+}
+
+func encodeUpdateEnvsResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.UpdateEnvsResponse)
+
+	return &pb.UpdateEnvsResponse{
+		Deploy: coreDeployToTransportDeploy(res.Deploy),
+	}, nil
+}
+
+func decodeScaleRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ScaleRequest)
+
+	return &endpoint.ScaleRequest{
+		Id:              req.DeployId,
+		Replicas:        int(req.Replicas),
+		ExpectedVersion: req.ExpectedVersion,
+		IdempotencyKey:  req.IdempotencyKey,
+	}, nil
+}
+
+func encodeScaleResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.ScaleResponse)
+
+	return &pb.ScaleResponse{
+		Deploy: coreDeployToTransportDeploy(res.Deploy),
+	}, nil
+}
+
 func decodeDestroyRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
 	req := grpcReq.(*pb.DestroyRequest)
 
 	return &endpoint.DestroyRequest{
-		Id: req.DeployId,
+		Id:              req.DeployId,
+		ExpectedVersion: req.ExpectedVersion,
+		IdempotencyKey:  req.IdempotencyKey,
 	}, nil
 }
 
@@ -71,3 +121,275 @@ func encodeListDeploysResponse(_ context.Context, resp interface{}) (interface{}
 		Deploys: deploys,
 	}, nil
 }
+
+func decodeListDeadLettersRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ListDeadLettersRequest)
+
+	return &endpoint.ListDeadLettersRequest{
+		DeployId: req.DeployId,
+	}, nil
+}
+
+func encodeListDeadLettersResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.ListDeadLettersResponse)
+
+	var letters []*pb.DeadLetter
+	for _, letter := range res.DeadLetters {
+		letters = append(letters, coreDeadLetterToTransportDeadLetter(letter))
+	}
+
+	return &pb.ListDeadLettersResponse{
+		DeadLetters: letters,
+	}, nil
+}
+
+func decodeReplayDeadLetterRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ReplayDeadLetterRequest)
+
+	return &endpoint.ReplayDeadLetterRequest{
+		Id: req.DeadLetterId,
+	}, nil
+}
+
+func encodeReplayDeadLetterResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	return &pb.ReplayDeadLetterResponse{}, nil
+}
+
+func decodeAddDomainRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.AddDomainRequest)
+
+	return &endpoint.AddDomainRequest{
+		DeployId: req.DeployId,
+		Domain:   req.Domain,
+	}, nil
+}
+
+func encodeAddDomainResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.AddDomainResponse)
+
+	return &pb.AddDomainResponse{
+		Domain: coreDomainToTransportDomain(res.Domain),
+	}, nil
+}
+
+func decodeRemoveDomainRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.RemoveDomainRequest)
+
+	return &endpoint.RemoveDomainRequest{
+		DeployId: req.DeployId,
+		Domain:   req.Domain,
+	}, nil
+}
+
+func encodeRemoveDomainResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	return &pb.RemoveDomainResponse{}, nil
+}
+
+func decodeListDomainsRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ListDomainsRequest)
+
+	return &endpoint.ListDomainsRequest{
+		DeployId: req.DeployId,
+	}, nil
+}
+
+func encodeListDomainsResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.ListDomainsResponse)
+
+	var domains []*pb.Domain
+	for _, domain := range res.Domains {
+		domains = append(domains, coreDomainToTransportDomain(domain))
+	}
+
+	return &pb.ListDomainsResponse{
+		Domains: domains,
+	}, nil
+}
+
+func decodeCheckPermissionRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.CheckPermissionRequest)
+
+	return &endpoint.CheckPermissionRequest{
+		Permission: req.Permission,
+		ProjectId:  req.ProjectId,
+		DeployId:   req.DeployId,
+		Labels:     req.Labels,
+	}, nil
+}
+
+func encodeCheckPermissionResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.CheckPermissionResponse)
+
+	return &pb.CheckPermissionResponse{
+		Allowed: res.Check.Allowed,
+		Reason:  res.Check.Reason,
+	}, nil
+}
+
+func decodeCreateProjectRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.CreateProjectRequest)
+
+	return &endpoint.CreateProjectRequest{
+		Id:          req.Id,
+		Description: req.Description,
+	}, nil
+}
+
+func encodeCreateProjectResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.CreateProjectResponse)
+
+	return &pb.CreateProjectResponse{
+		Project: coreProjectToTransportProject(res.Project),
+	}, nil
+}
+
+func decodeGetProjectRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetProjectRequest)
+
+	return &endpoint.GetProjectRequest{
+		Id: req.Id,
+	}, nil
+}
+
+func encodeGetProjectResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.GetProjectResponse)
+
+	return &pb.GetProjectResponse{
+		Project: coreProjectToTransportProject(res.Project),
+	}, nil
+}
+
+func decodeListProjectsRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &endpoint.ListProjectsRequest{}, nil
+}
+
+func encodeListProjectsResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.ListProjectsResponse)
+
+	var projects []*pb.Project
+	for _, project := range res.Projects {
+		projects = append(projects, coreProjectToTransportProject(project))
+	}
+
+	return &pb.ListProjectsResponse{
+		Projects: projects,
+	}, nil
+}
+
+func decodeDeleteProjectRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.DeleteProjectRequest)
+
+	return &endpoint.DeleteProjectRequest{
+		Id: req.Id,
+	}, nil
+}
+
+func encodeDeleteProjectResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	return &pb.DeleteProjectResponse{}, nil
+}
+
+func decodeAddProjectMemberRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.AddProjectMemberRequest)
+
+	return &endpoint.AddProjectMemberRequest{
+		ProjectId: req.ProjectId,
+		Subject:   req.Subject,
+	}, nil
+}
+
+func encodeAddProjectMemberResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.AddProjectMemberResponse)
+
+	return &pb.AddProjectMemberResponse{
+		Project: coreProjectToTransportProject(res.Project),
+	}, nil
+}
+
+func decodeRemoveProjectMemberRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.RemoveProjectMemberRequest)
+
+	return &endpoint.RemoveProjectMemberRequest{
+		ProjectId: req.ProjectId,
+		Subject:   req.Subject,
+	}, nil
+}
+
+func encodeRemoveProjectMemberResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.RemoveProjectMemberResponse)
+
+	return &pb.RemoveProjectMemberResponse{
+		Project: coreProjectToTransportProject(res.Project),
+	}, nil
+}
+
+func decodeSetProjectQuotaRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.SetProjectQuotaRequest)
+
+	return &endpoint.SetProjectQuotaRequest{
+		ProjectId: req.ProjectId,
+		Quota:     transportQuotaToCoreQuota(req.Quota),
+	}, nil
+}
+
+func encodeSetProjectQuotaResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.SetProjectQuotaResponse)
+
+	return &pb.SetProjectQuotaResponse{
+		Project: coreProjectToTransportProject(res.Project),
+	}, nil
+}
+
+func decodeGetQuotaUsageRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetQuotaUsageRequest)
+
+	return &endpoint.GetQuotaUsageRequest{
+		ProjectId: req.ProjectId,
+	}, nil
+}
+
+func encodeGetQuotaUsageResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.GetQuotaUsageResponse)
+
+	return &pb.GetQuotaUsageResponse{
+		ProjectId: res.Quota.ProjectId,
+		Quota:     coreQuotaToTransportQuota(&res.Quota.Quota),
+		Usage: &pb.QuotaUsage{
+			Deploys:          int32(res.Quota.Usage.Deploys),
+			ConcurrentBuilds: int32(res.Quota.Usage.ConcurrentBuilds),
+			QueuedBuilds:     int32(res.Quota.Usage.QueuedBuilds),
+			Replicas:         int32(res.Quota.Usage.Replicas),
+			MemoryBytes:      res.Quota.Usage.MemoryBytes,
+		},
+	}, nil
+}
+
+func decodeListAuditEventsRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ListAuditEventsRequest)
+
+	request := &endpoint.ListAuditEventsRequest{
+		DeployId: req.DeployId,
+		Actor:    req.Actor,
+		Limit:    int(req.Limit),
+	}
+	if req.From != nil {
+		request.From = req.From.AsTime()
+	}
+	if req.To != nil {
+		request.To = req.To.AsTime()
+	}
+
+	return request, nil
+}
+
+func encodeListAuditEventsResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	res := resp.(*endpoint.ListAuditEventsResponse)
+
+	var events []*pb.AuditEvent
+	for _, event := range res.Events {
+		events = append(events, coreAuditEventToTransportAuditEvent(event))
+	}
+
+	return &pb.ListAuditEventsResponse{
+		Events: events,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/pkg/errors"
+	"time"
+)
+
+func coreDeployToTransportDeploy(deploy *service.Deploy) *Deploy {
+	steps := []*BuildStep{}
+	for _, step := range deploy.Build.Steps {
+		buildStep := &BuildStep{
+			Step:  step.Step.ToString(),
+			Error: step.Error,
+		}
+		// legacy events carry no occurrence time
+		if !step.OccurredAt.IsZero() {
+			buildStep.OccurredAt = &step.OccurredAt
+		}
+		steps = append(steps, buildStep)
+	}
+
+	build := &Build{
+		JobId:         deploy.Build.JobId,
+		JobName:       deploy.Build.JobName,
+		ImageName:     deploy.Build.ImageName,
+		Status:        deploy.Build.Status.ToString(),
+		Steps:         steps,
+		Priority:      deploy.Build.Priority,
+		QueuePosition: deploy.Build.QueuePosition,
+	}
+	// deploys created before the build queue have never been queued
+	if !deploy.Build.QueuedAt.IsZero() {
+		build.QueuedAt = &deploy.Build.QueuedAt
+	}
+
+	envs := deploy.Workload.Envs
+	if envs == nil {
+		envs = map[string]string{}
+	}
+
+	return &Deploy{
+		Id:          deploy.Id,
+		ProjectId:   deploy.Project(),
+		Name:        deploy.Name,
+		GitRepo:     deploy.GitRepo,
+		Labels:      deploy.Labels,
+		HealthCheck: coreHealthCheckToTransportHealthCheck(deploy.HealthCheck),
+		Build:       build,
+		Workload: &Workload{
+			JobId:     deploy.Workload.JobId,
+			JobName:   deploy.Workload.JobName,
+			Envs:      envs,
+			Url:       deploy.Workload.Url,
+			Status:    deploy.Workload.Status.ToString(),
+			Health:    coreWorkloadHealthToTransportWorkloadHealth(deploy.Workload.Health),
+			Replicas:  deploy.Workload.Replicas,
+			Resources: coreResourcesToTransportResources(deploy.Workload.Resources),
+		},
+		Version: deploy.Version,
+	}
+}
+
+func transportResourcesToCoreResources(resources *Resources) service.Resources {
+	if resources == nil {
+		return service.Resources{}
+	}
+
+	return service.Resources{
+		CpuRequestMillis:   resources.CpuRequestMillis,
+		CpuLimitMillis:     resources.CpuLimitMillis,
+		MemoryRequestBytes: resources.MemoryRequestBytes,
+		MemoryLimitBytes:   resources.MemoryLimitBytes,
+	}
+}
+
+func coreResourcesToTransportResources(resources service.Resources) *Resources {
+	return &Resources{
+		CpuRequestMillis:   resources.CpuRequestMillis,
+		CpuLimitMillis:     resources.CpuLimitMillis,
+		MemoryRequestBytes: resources.MemoryRequestBytes,
+		MemoryLimitBytes:   resources.MemoryLimitBytes,
+	}
+}
+
+func transportHealthCheckToCoreHealthCheck(healthCheck *HealthCheck) (*service.HealthCheck, error) {
+	if healthCheck == nil {
+		return nil, nil
+	}
+
+	var timeout time.Duration
+	if healthCheck.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(healthCheck.Timeout)
+		if err != nil {
+			return nil, errors.Wrap(service.ErrInvalidHealthCheck, "timeout is not a duration, e.g. 5s")
+		}
+	}
+
+	return &service.HealthCheck{
+		Path:           healthCheck.Path,
+		ExpectedStatus: healthCheck.ExpectedStatus,
+		Timeout:        timeout,
+	}, nil
+}
+
+func coreHealthCheckToTransportHealthCheck(healthCheck *service.HealthCheck) *HealthCheck {
+	if healthCheck == nil {
+		return nil
+	}
+
+	return &HealthCheck{
+		Path:           healthCheck.Path,
+		ExpectedStatus: healthCheck.ExpectedStatus,
+		Timeout:        healthCheck.Timeout.String(),
+	}
+}
+
+func coreWorkloadHealthToTransportWorkloadHealth(health service.WorkloadHealth) *WorkloadHealth {
+	if health.CheckedAt.IsZero() {
+		return nil
+	}
+
+	return &WorkloadHealth{
+		State:        health.State.ToString(),
+		RestartCount: health.Restarts,
+		Missing:      health.Missing,
+		Message:      health.Message,
+		CheckedAt:    health.CheckedAt,
+	}
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+var (
+	ErrInvalidBody        = errors.New("invalid JSON body")
+	ErrInvalidVersion     = errors.New("invalid If-Match header, expected the version of the deploy")
+	ErrMissingCredentials = errors.New("missing bearer token in the Authorization header")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// statusOf maps the errors to the status codes, as the gRPC transport maps them to the gRPC codes
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidBody):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrInvalidVersion):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrMissingCredentials):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrInvalidCredentials):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, service.ErrIdempotencyKeyMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrInvalidHealthCheck):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidWorkload):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidDomain):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidProject):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrProjectNotEmpty):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, service.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidPermission):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidAuditFilter):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrRequestInProgress):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrNotWatched):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	code := statusOf(err)
+	message := err.Error()
+	// the causes of the internal errors are only logged
+	if code == http.StatusInternalServerError {
+		message = http.StatusText(code)
+	}
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(&ErrorResponse{
+		Error: message,
+	})
+}
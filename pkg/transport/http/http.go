@@ -0,0 +1,114 @@
+package http
+
+import (
+	"context"
+	_ "embed"
+	"github.com/Scarlet-Fairy/manager/pkg/endpoint"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/transport"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strings"
+)
+
+const (
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "bearer "
+)
+
+// openAPI documents the REST API, it is served on /openapi.yaml
+//
+//go:embed openapi.yaml
+var openAPI []byte
+
+var encodeJSON = httptransport.EncodeJSONResponse
+
+// Authenticator resolves the bearer token of a request to the principal it was issued to,
+// the authenticators of the gRPC transport are Authenticators as well
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*service.Principal, error)
+}
+
+// NewHTTPHandler exposes the deploys as a REST API with JSON bodies. Requests to the API are authenticated
+// by authenticator like the gRPC ones, unless it is nil, while the OpenAPI document is public.
+func NewHTTPHandler(endpoints endpoint.ManagerEndpoint, authenticator Authenticator, logger log.Logger) http.Handler {
+	options := []httptransport.ServerOption{
+		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	router := mux.NewRouter()
+	router.Methods(http.MethodGet).Path("/openapi.yaml").HandlerFunc(serveOpenAPI)
+
+	api := router.NewRoute().Subrouter()
+	if authenticator != nil {
+		api.Use(authMiddleware(authenticator, logger))
+	}
+
+	api.Methods(http.MethodPost).Path("/deploys").Handler(httptransport.NewServer(
+		endpoints.DeployEndpoint,
+		decodeDeployRequest,
+		encodeDeployResponse,
+		options...,
+	))
+	api.Methods(http.MethodGet).Path("/deploys").Handler(httptransport.NewServer(
+		endpoints.ListDeployEndpoint,
+		decodeListDeploysRequest,
+		encodeListDeploysResponse,
+		options...,
+	))
+	api.Methods(http.MethodGet).Path("/deploys/{id}").Handler(httptransport.NewServer(
+		endpoints.GetDeployEndpoint,
+		decodeGetDeployRequest,
+		encodeGetDeployResponse,
+		options...,
+	))
+	api.Methods(http.MethodDelete).Path("/deploys/{id}").Handler(httptransport.NewServer(
+		endpoints.DestroyEndpoint,
+		decodeDestroyRequest,
+		encodeDestroyResponse,
+		options...,
+	))
+
+	return router
+}
+
+func serveOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(openAPI)
+}
+
+// authMiddleware rejects with 401 the requests without a valid bearer token and
+// places the principal of the others in their context
+func authMiddleware(authenticator Authenticator, logger log.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := bearerToken(r)
+			if err != nil {
+				encodeError(r.Context(), err, w)
+				return
+			}
+
+			principal, err := authenticator.Authenticate(r.Context(), token)
+			if err != nil {
+				logger.Log("method", r.Method, "path", r.URL.Path, "err", err)
+				// the cause is only logged, it would help guessing valid credentials
+				encodeError(r.Context(), ErrInvalidCredentials, w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(service.ContextWithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, error) {
+	value := r.Header.Get(authorizationHeader)
+	if len(value) > len(bearerPrefix) && strings.ToLower(value[:len(bearerPrefix)]) == bearerPrefix {
+		return strings.TrimSpace(value[len(bearerPrefix):]), nil
+	}
+
+	return "", ErrMissingCredentials
+}
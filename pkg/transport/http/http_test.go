@@ -0,0 +1,253 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Scarlet-Fairy/manager/pkg/endpoint"
+	"github.com/Scarlet-Fairy/manager/pkg/service"
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const deployId = "6138c6d0c3b5a9d2e1b4f7a1"
+
+// fakeService answers the deploy calls of the gateway, failing with err when set,
+// the methods not needed by the tests are left to the nil Service
+type fakeService struct {
+	service.Service
+
+	err             error
+	idempotencyKey  string
+	expectedVersion int64
+}
+
+func (s *fakeService) Deploy(_ context.Context, _ string, _ string, _ string, _ map[string]string, _ map[string]string, _ *service.HealthCheck, _ int, _ service.Resources, _ int, idempotencyKey string) (string, error) {
+	s.idempotencyKey = idempotencyKey
+	if s.err != nil {
+		return "", s.err
+	}
+
+	return deployId, nil
+}
+
+func (s *fakeService) Destroy(_ context.Context, _ string, expectedVersion int64, idempotencyKey string) error {
+	s.idempotencyKey = idempotencyKey
+	s.expectedVersion = expectedVersion
+
+	return s.err
+}
+
+func (s *fakeService) GetDeploy(_ context.Context, id string) (*service.Deploy, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &service.Deploy{
+		Id:       id,
+		Name:     "api",
+		Build:    &service.Build{},
+		Workload: &service.Workload{},
+		Version:  3,
+	}, nil
+}
+
+func (s *fakeService) ListDeploys(_ context.Context) ([]*service.Deploy, error) {
+	return nil, s.err
+}
+
+// fakeAuthenticator accepts only the token "secret"
+type fakeAuthenticator struct{}
+
+func (fakeAuthenticator) Authenticate(_ context.Context, token string) (*service.Principal, error) {
+	if token != "secret" {
+		return nil, errors.New("unknown token")
+	}
+
+	return &service.Principal{Subject: "alice"}, nil
+}
+
+func serve(t *testing.T, s service.Service, authenticator Authenticator, r *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	handler := NewHTTPHandler(endpoint.NewEndpoint(s, log.NewNopLogger()), authenticator, log.NewNopLogger())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w
+}
+
+func errorOf(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var res ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("decoding error response: %v", err)
+	}
+
+	return res.Error
+}
+
+func TestStatusCodes(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not found", errors.Wrap(service.ErrNotFound, "Retrieving Deploy"), http.StatusNotFound},
+		{"permission denied", service.ErrPermissionDenied, http.StatusForbidden},
+		{"quota exceeded", service.ErrQuotaExceeded, http.StatusTooManyRequests},
+		{"conflict", service.ErrConflict, http.StatusPreconditionFailed},
+		{"request in progress", service.ErrRequestInProgress, http.StatusConflict},
+		{"internal", errors.New("connection refused"), http.StatusInternalServerError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serve(t, &fakeService{err: tc.err}, nil, httptest.NewRequest(http.MethodGet, "/deploys/"+deployId, nil))
+			if w.Code != tc.want {
+				t.Fatalf("status %d, want %d", w.Code, tc.want)
+			}
+
+			message := errorOf(t, w)
+			if tc.want == http.StatusInternalServerError && message != http.StatusText(tc.want) {
+				t.Errorf("internal error exposed as %q", message)
+			}
+		})
+	}
+}
+
+func TestGetDeployReturnsVersionAsETag(t *testing.T) {
+	w := serve(t, &fakeService{}, nil, httptest.NewRequest(http.MethodGet, "/deploys/"+deployId, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+
+	if etag := w.Header().Get("ETag"); etag != `"3"` {
+		t.Errorf("ETag %s, want \"3\"", etag)
+	}
+
+	var deploy Deploy
+	if err := json.NewDecoder(w.Body).Decode(&deploy); err != nil {
+		t.Fatalf("decoding deploy: %v", err)
+	}
+	if deploy.Id != deployId || deploy.Version != 3 {
+		t.Errorf("deploy %s at version %d", deploy.Id, deploy.Version)
+	}
+}
+
+func TestDestroyPassesIfMatchAsExpectedVersion(t *testing.T) {
+	s := &fakeService{}
+	r := httptest.NewRequest(http.MethodDelete, "/deploys/"+deployId, nil)
+	r.Header.Set("If-Match", `"3"`)
+	r.Header.Set("Idempotency-Key", "destroy-1")
+
+	w := serve(t, s, nil, r)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status %d, want 202", w.Code)
+	}
+	if s.expectedVersion != 3 {
+		t.Errorf("expected version %d, want 3", s.expectedVersion)
+	}
+	if s.idempotencyKey != "destroy-1" {
+		t.Errorf("idempotency key %q, want destroy-1", s.idempotencyKey)
+	}
+
+	// without If-Match the destruction is unconditional
+	s = &fakeService{}
+	if w := serve(t, s, nil, httptest.NewRequest(http.MethodDelete, "/deploys/"+deployId, nil)); w.Code != http.StatusAccepted {
+		t.Fatalf("status %d, want 202", w.Code)
+	}
+	if s.expectedVersion != 0 {
+		t.Errorf("expected version %d without If-Match", s.expectedVersion)
+	}
+}
+
+func TestDestroyRejectsInvalidIfMatch(t *testing.T) {
+	for _, value := range []string{`"abc"`, `"0"`, "-1"} {
+		r := httptest.NewRequest(http.MethodDelete, "/deploys/"+deployId, nil)
+		r.Header.Set("If-Match", value)
+
+		if w := serve(t, &fakeService{}, nil, r); w.Code != http.StatusBadRequest {
+			t.Errorf("If-Match %s: status %d, want 400", value, w.Code)
+		}
+	}
+}
+
+func TestDestroyOfChangedDeployFailsPrecondition(t *testing.T) {
+	r := httptest.NewRequest(http.MethodDelete, "/deploys/"+deployId, nil)
+	r.Header.Set("If-Match", `"2"`)
+
+	w := serve(t, &fakeService{err: errors.Wrap(service.ErrConflict, "expected version 2, found 3")}, nil, r)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("status %d, want 412", w.Code)
+	}
+}
+
+func TestDeployPassesIdempotencyKey(t *testing.T) {
+	s := &fakeService{}
+	r := httptest.NewRequest(http.MethodPost, "/deploys", strings.NewReader(`{"gitRepo":"https://github.com/Scarlet-Fairy/cobold.git","name":"api"}`))
+	r.Header.Set("Idempotency-Key", "deploy-1")
+
+	w := serve(t, s, nil, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d, want 201", w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "/deploys/"+deployId {
+		t.Errorf("Location %s", location)
+	}
+	if s.idempotencyKey != "deploy-1" {
+		t.Errorf("idempotency key %q, want deploy-1", s.idempotencyKey)
+	}
+}
+
+func TestDeployRejectsReusedIdempotencyKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/deploys", strings.NewReader(`{"name":"api"}`))
+	r.Header.Set("Idempotency-Key", "deploy-1")
+
+	w := serve(t, &fakeService{err: service.ErrIdempotencyKeyMismatch}, nil, r)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status %d, want 422", w.Code)
+	}
+}
+
+func TestDeployRejectsInvalidBody(t *testing.T) {
+	w := serve(t, &fakeService{}, nil, httptest.NewRequest(http.MethodPost, "/deploys", strings.NewReader(`{"name":`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", w.Code)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"other scheme", "Basic c2VjcmV0", http.StatusUnauthorized},
+		{"invalid token", "Bearer guess", http.StatusUnauthorized},
+		{"valid token", "bearer secret", http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/deploys", nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+
+			w := serve(t, &fakeService{}, fakeAuthenticator{}, r)
+			if w.Code != tc.want {
+				t.Fatalf("status %d, want %d", w.Code, tc.want)
+			}
+			if tc.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate %q", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	// the document of the API is public
+	if w := serve(t, &fakeService{}, fakeAuthenticator{}, httptest.NewRequest(http.MethodGet, "/openapi.yaml", nil)); w.Code != http.StatusOK {
+		t.Errorf("openapi.yaml: status %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,93 @@
+package http
+
+import "time"
+
+// The JSON representation of the resources, which follows the field names of the gRPC API in camel case.
+// Enums are represented by their names, durations by strings such as "5s".
+
+type Deploy struct {
+	Id          string            `json:"id"`
+	ProjectId   string            `json:"projectId"`
+	Name        string            `json:"name"`
+	GitRepo     string            `json:"gitRepo"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	HealthCheck *HealthCheck      `json:"healthCheck,omitempty"`
+	Build       *Build            `json:"build"`
+	Workload    *Workload         `json:"workload"`
+	Version     int64             `json:"version"`
+}
+
+type Build struct {
+	JobId     string       `json:"jobId,omitempty"`
+	JobName   string       `json:"jobName,omitempty"`
+	ImageName string       `json:"imageName,omitempty"`
+	Status    string       `json:"status"`
+	Steps     []*BuildStep `json:"steps"`
+	Priority  int          `json:"priority"`
+	QueuedAt  *time.Time   `json:"queuedAt,omitempty"`
+	// QueuePosition is the 1-based position of a queued build
+	QueuePosition int `json:"queuePosition,omitempty"`
+}
+
+type BuildStep struct {
+	Step       string     `json:"step"`
+	Error      string     `json:"error,omitempty"`
+	OccurredAt *time.Time `json:"occurredAt,omitempty"`
+}
+
+type Workload struct {
+	JobId     string            `json:"jobId,omitempty"`
+	JobName   string            `json:"jobName,omitempty"`
+	Envs      map[string]string `json:"envs"`
+	Url       string            `json:"url,omitempty"`
+	Status    string            `json:"status"`
+	Health    *WorkloadHealth   `json:"health,omitempty"`
+	Replicas  int               `json:"replicas"`
+	Resources *Resources        `json:"resources"`
+}
+
+type WorkloadHealth struct {
+	State        string    `json:"state"`
+	RestartCount int       `json:"restartCount"`
+	Missing      bool      `json:"missing"`
+	Message      string    `json:"message,omitempty"`
+	CheckedAt    time.Time `json:"checkedAt"`
+}
+
+type Resources struct {
+	CpuRequestMillis   int64 `json:"cpuRequestMillis"`
+	CpuLimitMillis     int64 `json:"cpuLimitMillis"`
+	MemoryRequestBytes int64 `json:"memoryRequestBytes"`
+	MemoryLimitBytes   int64 `json:"memoryLimitBytes"`
+}
+
+type HealthCheck struct {
+	Path           string `json:"path"`
+	ExpectedStatus int    `json:"expectedStatus,omitempty"`
+	Timeout        string `json:"timeout,omitempty"`
+}
+
+type DeployRequest struct {
+	ProjectId   string            `json:"projectId"`
+	GitRepo     string            `json:"gitRepo"`
+	Name        string            `json:"name"`
+	Envs        map[string]string `json:"envs"`
+	Labels      map[string]string `json:"labels"`
+	HealthCheck *HealthCheck      `json:"healthCheck"`
+	// Replicas defaults to 1 when 0
+	Replicas  int        `json:"replicas"`
+	Resources *Resources `json:"resources"`
+	Priority  int        `json:"priority"`
+}
+
+type DeployResponse struct {
+	DeployId string `json:"deployId"`
+}
+
+type ListDeploysResponse struct {
+	Deploys []*Deploy `json:"deploys"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
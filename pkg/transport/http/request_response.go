@@ -0,0 +1,163 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Scarlet-Fairy/manager/pkg/endpoint"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	ifMatchHeader        = "If-Match"
+)
+
+// created is the response of a POST, with the url of the resource in the Location header
+type created struct {
+	body     interface{}
+	location string
+}
+
+func (c created) StatusCode() int {
+	return http.StatusCreated
+}
+
+func (c created) Headers() http.Header {
+	return http.Header{"Location": []string{c.location}}
+}
+
+func (c created) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.body)
+}
+
+// accepted is the response of a request whose effects are applied asynchronously
+type accepted struct{}
+
+func (a accepted) StatusCode() int {
+	return http.StatusAccepted
+}
+
+func (a accepted) MarshalJSON() ([]byte, error) {
+	return []byte("{}"), nil
+}
+
+// versioned is a resource along with its version in the ETag header, which is expected back in If-Match
+type versioned struct {
+	body    interface{}
+	version int64
+}
+
+func (v versioned) Headers() http.Header {
+	return http.Header{"ETag": []string{strconv.Quote(strconv.FormatInt(v.version, 10))}}
+}
+
+func (v versioned) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.body)
+}
+
+// expectedVersion parses the If-Match header, which is the ETag of a deploy, 0 when missing
+func expectedVersion(r *http.Request) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get(ifMatchHeader))
+	if value == "" {
+		return 0, nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+	if err != nil || version <= 0 {
+		return 0, ErrInvalidVersion
+	}
+
+	return version, nil
+}
+
+func decodeDeployRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req DeployRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.Wrap(ErrInvalidBody, err.Error())
+	}
+	if req.Envs == nil {
+		req.Envs = map[string]string{}
+	}
+
+	healthCheck, err := transportHealthCheckToCoreHealthCheck(req.HealthCheck)
+	if err != nil {
+		return nil, err
+	}
+
+	return &endpoint.DeployRequest{
+		ProjectId:      req.ProjectId,
+		GitRepo:        req.GitRepo,
+		Name:           req.Name,
+		Envs:           req.Envs,
+		Labels:         req.Labels,
+		HealthCheck:    healthCheck,
+		Replicas:       req.Replicas,
+		Resources:      transportResourcesToCoreResources(req.Resources),
+		Priority:       req.Priority,
+		IdempotencyKey: r.Header.Get(idempotencyKeyHeader),
+	}, nil
+}
+
+func encodeDeployResponse(ctx context.Context, w http.ResponseWriter, resp interface{}) error {
+	res := resp.(*endpoint.DeployResponse)
+
+	return encodeJSON(ctx, w, created{
+		body: &DeployResponse{
+			DeployId: res.DeployId,
+		},
+		location: "/deploys/" + res.DeployId,
+	})
+}
+
+func decodeDestroyRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	version, err := expectedVersion(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &endpoint.DestroyRequest{
+		Id:              mux.Vars(r)["id"],
+		ExpectedVersion: version,
+		IdempotencyKey:  r.Header.Get(idempotencyKeyHeader),
+	}, nil
+}
+
+func encodeDestroyResponse(ctx context.Context, w http.ResponseWriter, resp interface{}) error {
+	return encodeJSON(ctx, w, accepted{})
+}
+
+func decodeGetDeployRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return &endpoint.GetDeployRequest{
+		Id: mux.Vars(r)["id"],
+	}, nil
+}
+
+func encodeGetDeployResponse(ctx context.Context, w http.ResponseWriter, resp interface{}) error {
+	res := resp.(*endpoint.GetDeployResponse)
+
+	return encodeJSON(ctx, w, versioned{
+		body:    coreDeployToTransportDeploy(res.Deploy),
+		version: res.Deploy.Version,
+	})
+}
+
+func decodeListDeploysRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return &endpoint.ListDeploysRequest{}, nil
+}
+
+func encodeListDeploysResponse(ctx context.Context, w http.ResponseWriter, resp interface{}) error {
+	res := resp.(*endpoint.ListDeploysResponse)
+
+	deploys := []*Deploy{}
+	for _, deploy := range res.Deploys {
+		deploys = append(deploys, coreDeployToTransportDeploy(deploy))
+	}
+
+	return encodeJSON(ctx, w, &ListDeploysResponse{
+		Deploys: deploys,
+	})
+}